@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -17,6 +18,7 @@ import (
 	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
 	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
 	"github.com/sobhan-yasami/docs-db-panel/internal/routes"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
 )
 
 var (
@@ -33,6 +35,11 @@ const (
 	defaultPort        = "5000"
 	defaultBodyLimitMB = 50
 	shutdownTimeout    = 10 * time.Second
+
+	defaultSLAScanInterval = 15 * time.Minute
+	defaultEmailInterval   = 30 * time.Second
+	defaultSMTPPort        = "587"
+	defaultWebhookInterval = 10 * time.Second
 )
 
 func init() {
@@ -138,10 +145,81 @@ func main() {
 	routes.SetupContractRoutes(v1, contractHandler, attachmentHandler, jwtSecret)
 	routes.SetupAttachmentRoutes(v1, attachmentHandler, jwtSecret)
 
+	advanceHandler := handlers.NewAdvanceHandler(db)
+	routes.SetupAdvanceRoutes(v1, advanceHandler, jwtSecret)
+
+	retentionHandler := handlers.NewRetentionHandler(db)
+	routes.SetupRetentionRoutes(v1, retentionHandler, jwtSecret)
+
+	fxHandler := handlers.NewFxHandler(db)
+	routes.SetupFxRoutes(v1, fxHandler, jwtSecret)
+
+	escalationHandler := handlers.NewEscalationHandler(db)
+	routes.SetupEscalationRoutes(v1, escalationHandler, jwtSecret)
+
 	statementHandler := handlers.NewStatementHandler(db)
 	reportHandler := handlers.NewReportHandler(db)
 	routes.SetupStatementRoutes(v1, statementHandler, reportHandler, jwtSecret)
 
+	statementImportHandler := handlers.NewStatementImportHandler(db)
+	routes.SetupStatementImportRoutes(v1, statementImportHandler, jwtSecret)
+
+	variationHandler := handlers.NewVariationHandler(db)
+	routes.SetupVariationRoutes(v1, variationHandler, jwtSecret)
+
+	extensionHandler := handlers.NewExtensionHandler(db)
+	routes.SetupExtensionRoutes(v1, extensionHandler, jwtSecret)
+
+	boqHandler := handlers.NewBOQHandler(db)
+	routes.SetupBOQRoutes(v1, boqHandler, jwtSecret)
+
+	priceListHandler := handlers.NewPriceListHandler(db)
+	routes.SetupPriceListRoutes(v1, priceListHandler, jwtSecret)
+
+	ldHandler := handlers.NewLiquidatedDamageHandler(db)
+	routes.SetupLiquidatedDamageRoutes(v1, ldHandler, jwtSecret)
+
+	closeoutHandler := handlers.NewCloseoutHandler(db)
+	routes.SetupCloseoutRoutes(v1, closeoutHandler, jwtSecret)
+
+	paymentHandler := handlers.NewPaymentHandler(db)
+	routes.SetupPaymentRoutes(v1, paymentHandler, jwtSecret)
+
+	revisionHandler := handlers.NewRevisionHandler(db)
+	routes.SetupRevisionRoutes(v1, revisionHandler, jwtSecret)
+
+	workflowHandler := handlers.NewWorkflowHandler(db)
+	routes.SetupWorkflowRoutes(v1, workflowHandler, jwtSecret)
+
+	delegationHandler := handlers.NewDelegationHandler(db)
+	routes.SetupDelegationRoutes(v1, delegationHandler, jwtSecret)
+
+	slaHandler := handlers.NewSLAHandler(db)
+	routes.SetupSLARoutes(v1, slaHandler, jwtSecret)
+
+	notificationHandler := handlers.NewNotificationHandler(db)
+	routes.SetupNotificationRoutes(v1, notificationHandler, jwtSecret)
+
+	smtpConfig := services.SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if smtpConfig.Port == "" {
+		smtpConfig.Port = defaultSMTPPort
+	}
+	emailHandler := handlers.NewEmailHandler(db, smtpConfig)
+	routes.SetupEmailRoutes(v1, emailHandler, jwtSecret)
+
+	webhookHandler := handlers.NewWebhookHandler(db)
+	routes.SetupWebhookRoutes(v1, webhookHandler, jwtSecret)
+
+	liveHub := services.NewLiveHub()
+	liveHandler := handlers.NewLiveHandler(db, liveHub)
+	routes.SetupLiveRoutes(v1, liveHandler, jwtSecret)
+
 	// Signature routes replaced by 5-stage approval via statement transition.
 	_ = handlers.NewSignatureHandler(db)
 
@@ -161,11 +239,56 @@ func main() {
 		}
 	}()
 
+	schedCtx, stopScheduler := context.WithCancel(context.Background())
+
+	// Live updates: every instance LISTENs so events reach all clients.
+	go liveHub.Run(schedCtx, database.NewConfig().DSN())
+
+	// Approval SLA scheduler; SLA_SCAN_INTERVAL=0 disables it.
+	slaInterval := defaultSLAScanInterval
+	if v := os.Getenv("SLA_SCAN_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			slaInterval = d
+		} else {
+			log.Printf("%s⚠️ Invalid SLA_SCAN_INTERVAL %q, using %s%s", colorYellow, v, slaInterval, colorReset)
+		}
+	}
+	if slaInterval > 0 {
+		go services.NewSLAService(db).Run(schedCtx, slaInterval)
+	}
+
+	// Email worker; only runs when SMTP_HOST is set. Emails queue up until then.
+	if smtpConfig.Host != "" {
+		emailInterval := defaultEmailInterval
+		if v := os.Getenv("EMAIL_WORKER_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				emailInterval = d
+			} else {
+				log.Printf("%s⚠️ Invalid EMAIL_WORKER_INTERVAL %q, using %s%s", colorYellow, v, emailInterval, colorReset)
+			}
+		}
+		go services.NewEmailService(db, smtpConfig).Run(schedCtx, emailInterval)
+	}
+
+	// Webhook worker; WEBHOOK_WORKER_INTERVAL=0 disables it.
+	webhookInterval := defaultWebhookInterval
+	if v := os.Getenv("WEBHOOK_WORKER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			webhookInterval = d
+		} else {
+			log.Printf("%s⚠️ Invalid WEBHOOK_WORKER_INTERVAL %q, using %s%s", colorYellow, v, webhookInterval, colorReset)
+		}
+	}
+	if webhookInterval > 0 {
+		go services.NewWebhookService(db).Run(schedCtx, webhookInterval)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Printf("%s🛑 Shutting down server...%s", colorYellow, colorReset)
+	stopScheduler()
 	if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
 		log.Printf("%s⚠️ Error during shutdown:%s %v", colorRed, colorReset, err)
 	}
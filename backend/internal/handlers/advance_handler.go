@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type AdvanceHandler struct {
+	svc *services.AdvanceService
+}
+
+func NewAdvanceHandler(db *gorm.DB) *AdvanceHandler {
+	return &AdvanceHandler{svc: services.NewAdvanceService(db)}
+}
+
+// POST /contracts/:id/advances
+func (h *AdvanceHandler) IssueAdvance(c *fiber.Ctx) error {
+	var req services.IssueAdvanceReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	rec, err := h.svc.Issue(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(rec, "Advance issued"))
+}
+
+// GET /contracts/:id/advances
+func (h *AdvanceHandler) ListAdvances(c *fiber.Ctx) error {
+	ledger, err := h.svc.Ledger(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(ledger))
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"github.com/gofiber/fiber/v2"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
 	"github.com/sobhan-yasami/docs-db-panel/internal/services"
 	"gorm.io/gorm"
 )
@@ -54,6 +55,41 @@ func (h *AttachmentHandler) ListForContract(c *fiber.Ctx) error {
 	return c.JSON(SuccessResponse(atts, "ok"))
 }
 
+// POST /extensions/:id/attachments  (multipart, field "file")
+func (h *AttachmentHandler) UploadForExtension(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "file field required"))
+	}
+
+	att, err := h.svc.UploadForExtension(
+		c.Context(),
+		c.Params("id"),
+		claims.CompanyID,
+		claims.UserID,
+		c.FormValue("document_type"),
+		fh,
+	)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(att, "Document uploaded"))
+}
+
+// GET /extensions/:id/attachments
+func (h *AttachmentHandler) ListForExtension(c *fiber.Ctx) error {
+	atts, err := h.svc.ListByEntity(c.Context(), model.WorkflowExtensionOfTime, c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(atts, "ok"))
+}
+
 // DELETE /attachments/:id
 func (h *AttachmentHandler) Delete(c *fiber.Ctx) error {
 	claims := jwtClaims(c)
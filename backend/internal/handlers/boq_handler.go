@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type BOQHandler struct {
+	svc *services.BOQService
+}
+
+func NewBOQHandler(db *gorm.DB) *BOQHandler {
+	return &BOQHandler{svc: services.NewBOQService(db)}
+}
+
+// POST /contracts/:id/line-items/import  (multipart, field "file")
+// Optional fields: sheet, header_row, mapping (JSON object), boq_version, dry_run.
+func (h *BOQHandler) ImportLineItems(c *fiber.Ctx) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "file field required"))
+	}
+	opts := services.BOQImportOptions{
+		Format:     strings.TrimPrefix(strings.ToLower(filepath.Ext(fh.Filename)), "."),
+		Sheet:      c.FormValue("sheet"),
+		BOQVersion: c.FormValue("boq_version"),
+	}
+	if v := c.FormValue("header_row"); v != "" {
+		if opts.HeaderRow, err = strconv.Atoi(v); err != nil || opts.HeaderRow < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "header_row must be a positive integer"))
+		}
+	}
+	if v := c.FormValue("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &opts.Mapping); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "mapping must be a JSON object of field to column"))
+		}
+	}
+	if v := c.FormValue("dry_run"); v != "" {
+		if opts.DryRun, err = strconv.ParseBool(v); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "dry_run must be true or false"))
+		}
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Cannot read uploaded file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.Import(c.Context(), c.Params("id"), f, opts)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	if opts.DryRun {
+		return c.JSON(SuccessResponse(result, "BOQ preview"))
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(result, "BOQ imported"))
+}
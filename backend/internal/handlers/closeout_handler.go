@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type CloseoutHandler struct {
+	svc *services.CloseoutService
+}
+
+func NewCloseoutHandler(db *gorm.DB) *CloseoutHandler {
+	return &CloseoutHandler{svc: services.NewCloseoutService(db)}
+}
+
+// GET /contracts/:id/final-account
+func (h *CloseoutHandler) GetFinalAccount(c *fiber.Ctx) error {
+	fa, err := h.svc.Preview(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fa))
+}
+
+// POST /contracts/:id/closeout
+func (h *CloseoutHandler) Closeout(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.CloseoutReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	fa, err := h.svc.Closeout(c.Context(), c.Params("id"), req, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(fa, "Contract closed"))
+}
@@ -20,6 +20,9 @@ func jwtClaims(c *fiber.Ctx) *schemas.JWTClaims {
 func serviceErr(c *fiber.Ctx, err error) error {
 	if svcErr, ok := err.(*services.ServiceError); ok {
 		status := codeToResponseStatus(svcErr.Code)
+		if svcErr.Errors != nil {
+			return c.Status(svcErr.Code).JSON(ErrorResponse(status, svcErr.Message, svcErr.Errors))
+		}
 		return c.Status(svcErr.Code).JSON(ErrorResponse(status, svcErr.Message))
 	}
 	return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse(InternalError, "Internal server error"))
@@ -383,6 +386,15 @@ func (h *ContractHandler) ListLineItems(c *fiber.Ctx) error {
 	return c.JSON(SuccessResponse(items))
 }
 
+// GET /contracts/:id/line-items/tree
+func (h *ContractHandler) LineItemTree(c *fiber.Ctx) error {
+	tree, err := h.svc.LineItemTree(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(tree))
+}
+
 // POST /contracts/:id/line-items
 func (h *ContractHandler) CreateLineItem(c *fiber.Ctx) error {
 	var req services.CreateLineItemReq
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type DelegationHandler struct {
+	svc *services.DelegationService
+}
+
+func NewDelegationHandler(db *gorm.DB) *DelegationHandler {
+	return &DelegationHandler{svc: services.NewDelegationService(db)}
+}
+
+// GET /delegations?delegator_id=&delegate_id=&active=true
+func (h *DelegationHandler) ListDelegations(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	out, err := h.svc.List(c.Context(), claims.CompanyID, c.Query("delegator_id"), c.Query("delegate_id"), c.QueryBool("active"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(out))
+}
+
+// POST /delegations
+func (h *DelegationHandler) CreateDelegation(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.CreateDelegationReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	d, err := h.svc.Create(c.Context(), req, claims.CompanyID, actorID, claims.Roles)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(d, "Delegation created"))
+}
+
+// POST /delegations/:id/revoke
+func (h *DelegationHandler) RevokeDelegation(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	d, err := h.svc.Revoke(c.Context(), c.Params("id"), claims.CompanyID, actorID, claims.Roles)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(d, "Delegation revoked"))
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type EmailHandler struct {
+	svc *services.EmailService
+}
+
+func NewEmailHandler(db *gorm.DB, smtp services.SMTPConfig) *EmailHandler {
+	return &EmailHandler{svc: services.NewEmailService(db, smtp)}
+}
+
+// GET /users/me/notification-preferences
+func (h *EmailHandler) GetPreferences(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	uid, _ := uuid.Parse(claims.UserID)
+	p, err := h.svc.GetPreferences(c.Context(), uid)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(p))
+}
+
+// PUT /users/me/notification-preferences
+func (h *EmailHandler) UpdatePreferences(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.UpdateNotificationPreferenceReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	uid, _ := uuid.Parse(claims.UserID)
+	p, err := h.svc.UpdatePreferences(c.Context(), uid, req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(p, "Preferences saved"))
+}
+
+// GET /emails?status=&page=&limit=
+func (h *EmailHandler) ListEmails(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	page, limit := paginationQuery(c)
+	items, total, err := h.svc.ListMessages(c.Context(), claims.CompanyID, c.Query("status"), page, limit)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{
+		"data":  items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}))
+}
+
+// GET /emails/:id/deliveries
+func (h *EmailHandler) ListDeliveries(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	logs, err := h.svc.ListDeliveries(c.Context(), claims.CompanyID, c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(logs))
+}
+
+// POST /emails/:id/retry
+func (h *EmailHandler) RetryEmail(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	m, err := h.svc.Retry(c.Context(), claims.CompanyID, c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(m, "Email re-queued"))
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type EscalationHandler struct {
+	svc *services.EscalationService
+}
+
+func NewEscalationHandler(db *gorm.DB) *EscalationHandler {
+	return &EscalationHandler{svc: services.NewEscalationService(db)}
+}
+
+// GET /price-indices?index_set=...&year=1404&quarter=2
+func (h *EscalationHandler) ListIndices(c *fiber.Ctx) error {
+	page, limit := paginationQuery(c)
+	items, total, err := h.svc.ListIndices(c.Context(), c.Query("index_set"), c.QueryInt("year"), c.QueryInt("quarter"), page, limit)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{
+		"data":  items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}))
+}
+
+// PUT /price-indices
+func (h *EscalationHandler) PutIndex(c *fiber.Ctx) error {
+	var req services.PriceIndexReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	idx, err := h.svc.PutIndex(c.Context(), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(idx, "Price index saved"))
+}
+
+// DELETE /price-indices/:id
+func (h *EscalationHandler) DeleteIndex(c *fiber.Ctx) error {
+	if err := h.svc.DeleteIndex(c.Context(), c.Params("id")); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// POST /price-indices/import  (multipart, field "file")
+func (h *EscalationHandler) ImportIndices(c *fiber.Ctx) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "file field required"))
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Cannot read uploaded file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.ImportIndices(c.Context(), f)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(result, "Price indices imported"))
+}
+
+// GET /statements/:id/escalation
+func (h *EscalationHandler) StatementEscalation(c *fiber.Ctx) error {
+	rows, err := h.svc.StatementEscalations(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(rows))
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type ExtensionHandler struct {
+	svc *services.ExtensionService
+}
+
+func NewExtensionHandler(db *gorm.DB) *ExtensionHandler {
+	return &ExtensionHandler{svc: services.NewExtensionService(db)}
+}
+
+// GET /contracts/:contractId/extensions?status=
+func (h *ExtensionHandler) ListExtensions(c *fiber.Ctx) error {
+	eots, err := h.svc.List(c.Context(), c.Params("contractId"), c.Query("status"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(eots))
+}
+
+// POST /contracts/:contractId/extensions
+func (h *ExtensionHandler) CreateExtension(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.CreateExtensionReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	eot, err := h.svc.Create(c.Context(), c.Params("contractId"), req, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(eot, "Extension of time created"))
+}
+
+// GET /extensions/:id
+func (h *ExtensionHandler) GetExtension(c *fiber.Ctx) error {
+	eot, err := h.svc.Get(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(eot))
+}
+
+// PUT /extensions/:id
+func (h *ExtensionHandler) UpdateExtension(c *fiber.Ctx) error {
+	var req services.UpdateExtensionReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	eot, err := h.svc.Update(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(eot, "Extension of time updated"))
+}
+
+// DELETE /extensions/:id
+func (h *ExtensionHandler) DeleteExtension(c *fiber.Ctx) error {
+	if err := h.svc.Delete(c.Context(), c.Params("id")); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// POST /extensions/:id/transition
+func (h *ExtensionHandler) TransitionExtension(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var body struct {
+		Action      string `json:"action"`
+		Comment     string `json:"comment"`
+		DaysGranted *int   `json:"days_granted"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	if body.Action == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "action is required"))
+	}
+	actorID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	eot, err := h.svc.Transition(c.Context(), c.Params("id"), actorID, claims.Roles, body.Action, body.Comment, body.DaysGranted)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(eot, "Extension of time status updated"))
+}
+
+// GET /extensions/:id/approvals
+func (h *ExtensionHandler) ListExtensionApprovals(c *fiber.Ctx) error {
+	events, err := h.svc.ListApprovals(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(events))
+}
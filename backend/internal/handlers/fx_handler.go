@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type FxHandler struct {
+	svc *services.FxService
+}
+
+func NewFxHandler(db *gorm.DB) *FxHandler {
+	return &FxHandler{svc: services.NewFxService(db)}
+}
+
+// GET /fx-rates?from=USD&to=IRR
+func (h *FxHandler) ListRates(c *fiber.Ctx) error {
+	page, limit := paginationQuery(c)
+	rates, total, err := h.svc.List(c.Context(), c.Query("from"), c.Query("to"), page, limit)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{
+		"data":  rates,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}))
+}
+
+// GET /fx-rates/lookup?from=USD&to=IRR&date=2025-05-01
+func (h *FxHandler) LookupRate(c *fiber.Ctx) error {
+	rate, err := h.svc.Lookup(c.Context(), c.Query("from"), c.Query("to"), c.Query("date"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(rate))
+}
+
+// POST /fx-rates
+func (h *FxHandler) CreateRate(c *fiber.Ctx) error {
+	var req services.FxRateReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	rate, err := h.svc.Create(c.Context(), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(rate, "FX rate created"))
+}
+
+// PUT /fx-rates/:id
+func (h *FxHandler) UpdateRate(c *fiber.Ctx) error {
+	var req services.UpdateFxRateReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	rate, err := h.svc.Update(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(rate, "FX rate updated"))
+}
+
+// DELETE /fx-rates/:id
+func (h *FxHandler) DeleteRate(c *fiber.Ctx) error {
+	if err := h.svc.Delete(c.Context(), c.Params("id")); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// POST /fx-rates/import  (multipart, field "file")
+func (h *FxHandler) ImportRates(c *fiber.Ctx) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "file field required"))
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Cannot read uploaded file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.Import(c.Context(), f)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(result, "FX rates imported"))
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type LiquidatedDamageHandler struct {
+	svc *services.LiquidatedDamageService
+}
+
+func NewLiquidatedDamageHandler(db *gorm.DB) *LiquidatedDamageHandler {
+	return &LiquidatedDamageHandler{svc: services.NewLiquidatedDamageService(db)}
+}
+
+// GET /statements/:id/damages
+func (h *LiquidatedDamageHandler) ListDamages(c *fiber.Ctx) error {
+	items, err := h.svc.List(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(items))
+}
+
+// POST /statements/:id/damages/propose-delay
+func (h *LiquidatedDamageHandler) ProposeDelay(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	ld, err := h.svc.ProposeDelay(c.Context(), c.Params("id"), actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(ld, "Delay damages proposed"))
+}
+
+// POST /statements/:id/damages
+func (h *LiquidatedDamageHandler) CreateDamage(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.CreateLiquidatedDamageReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	ld, err := h.svc.Create(c.Context(), c.Params("id"), req, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(ld, "Damage added"))
+}
+
+// DELETE /statements/:id/damages/:ldId
+func (h *LiquidatedDamageHandler) DeleteDamage(c *fiber.Ctx) error {
+	if err := h.svc.Delete(c.Context(), c.Params("id"), c.Params("ldId")); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// POST /statements/:id/damages/:ldId/waive
+func (h *LiquidatedDamageHandler) WaiveDamage(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.WaiveLiquidatedDamageReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	ld, err := h.svc.Waive(c.Context(), c.Params("id"), c.Params("ldId"), req, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(ld, "Damage waived"))
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+const liveHeartbeat = 25 * time.Second
+
+type LiveHandler struct {
+	hub     *services.LiveHub
+	tickets *services.LiveTicketService
+}
+
+func NewLiveHandler(db *gorm.DB, hub *services.LiveHub) *LiveHandler {
+	return &LiveHandler{hub: hub, tickets: services.NewLiveTicketService(db)}
+}
+
+// POST /live/ticket
+//
+// Issues a single-use ticket for opening the stream, valid for 30 seconds.
+func (h *LiveHandler) Ticket(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	ticket, expiresAt, err := h.tickets.Issue(c.Context(), claims.UserID, claims.CompanyID, claims.Roles)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(fiber.Map{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	}, "Live ticket issued"))
+}
+
+// GET /live?ticket=&entity_type=&entity_id=
+//
+// Server-Sent Events stream of the ticket holder's company. entity_type
+// narrows it to one kind of document; entity_type + entity_id to one document
+// (a contract includes its statements and payments).
+func (h *LiveHandler) Stream(c *fiber.Ctx) error {
+	filter := services.LiveFilter{EntityType: c.Query("entity_type")}
+	switch filter.EntityType {
+	case "", model.WorkflowContract, model.WorkflowInterimStatement, model.WorkflowVariationOrder, model.WorkflowExtensionOfTime:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "entity_type must be contract, interim_statement, variation_order or extension_of_time"))
+	}
+	if v := c.Query("entity_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil || filter.EntityType == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "entity_id needs a valid entity_type and UUID"))
+		}
+		filter.EntityID = &id
+	}
+
+	// Redeem only once the query is valid, so a typo does not burn the ticket.
+	t, err := h.tickets.Redeem(c.Context(), c.Query("ticket"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	filter.CompanyID = t.CompanyID
+	filter.Amounts = services.CanSeeLiveAmounts(t.Roles)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	sub := h.hub.Subscribe(filter)
+	done := h.hub.Done()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.hub.Unsubscribe(sub)
+		heartbeat := time.NewTicker(liveHeartbeat)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 3000\n: connected\n\n")
+		if w.Flush() != nil {
+			return
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case e := <-sub.C:
+				data, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			// A failed flush means the client went away.
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	svc *services.NotificationService
+}
+
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{svc: services.NewNotificationService(db)}
+}
+
+// GET /users/me/notifications?unread=true&page=&limit=
+func (h *NotificationHandler) ListNotifications(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	uid, _ := uuid.Parse(claims.UserID)
+	page, limit := paginationQuery(c)
+	items, total, err := h.svc.List(c.Context(), uid, c.QueryBool("unread"), page, limit)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{
+		"data":  items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}))
+}
+
+// GET /users/me/notifications/unread-count
+func (h *NotificationHandler) UnreadCount(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	uid, _ := uuid.Parse(claims.UserID)
+	n, err := h.svc.UnreadCount(c.Context(), uid)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{"unread": n}))
+}
+
+// POST /users/me/notifications/:id/read
+func (h *NotificationHandler) MarkRead(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	uid, _ := uuid.Parse(claims.UserID)
+	n, err := h.svc.MarkRead(c.Context(), uid, c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(n))
+}
+
+// POST /users/me/notifications/read-all
+func (h *NotificationHandler) MarkAllRead(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	uid, _ := uuid.Parse(claims.UserID)
+	n, err := h.svc.MarkAllRead(c.Context(), uid)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{"marked": n}))
+}
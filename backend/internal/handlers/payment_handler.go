@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type PaymentHandler struct {
+	svc *services.PaymentService
+}
+
+func NewPaymentHandler(db *gorm.DB) *PaymentHandler {
+	return &PaymentHandler{svc: services.NewPaymentService(db)}
+}
+
+// GET /contracts/:id/payments
+func (h *PaymentHandler) ListContractPayments(c *fiber.Ctx) error {
+	out, err := h.svc.ListByContract(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(out))
+}
+
+// GET /statements/:id/payments
+func (h *PaymentHandler) ListStatementPayments(c *fiber.Ctx) error {
+	out, err := h.svc.ListByStatement(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(out))
+}
+
+// POST /statements/:id/payments
+func (h *PaymentHandler) RecordPayment(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.RecordPaymentReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	pmt, err := h.svc.Record(c.Context(), c.Params("id"), req, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(pmt, "Payment recorded"))
+}
+
+// DELETE /payments/:id
+func (h *PaymentHandler) DeletePayment(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	if err := h.svc.Delete(c.Context(), c.Params("id"), actorID); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
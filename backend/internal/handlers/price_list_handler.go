@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type PriceListHandler struct {
+	svc *services.PriceListService
+}
+
+func NewPriceListHandler(db *gorm.DB) *PriceListHandler {
+	return &PriceListHandler{svc: services.NewPriceListService(db)}
+}
+
+// GET /price-lists?discipline=...&year=1404
+func (h *PriceListHandler) ListEditions(c *fiber.Ctx) error {
+	editions, err := h.svc.ListEditions(c.Context(), c.Query("discipline"), c.QueryInt("year"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(editions))
+}
+
+// GET /price-lists/:id
+func (h *PriceListHandler) GetEdition(c *fiber.Ctx) error {
+	ed, err := h.svc.GetEdition(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(ed))
+}
+
+// POST /price-lists
+func (h *PriceListHandler) CreateEdition(c *fiber.Ctx) error {
+	var req services.PriceListEditionReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	ed, err := h.svc.CreateEdition(c.Context(), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(ed, "Price list created"))
+}
+
+// PUT /price-lists/:id
+func (h *PriceListHandler) UpdateEdition(c *fiber.Ctx) error {
+	var req services.PriceListEditionReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	ed, err := h.svc.UpdateEdition(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(ed, "Price list updated"))
+}
+
+// DELETE /price-lists/:id
+func (h *PriceListHandler) DeleteEdition(c *fiber.Ctx) error {
+	if err := h.svc.DeleteEdition(c.Context(), c.Params("id")); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// GET /price-lists/:id/items?q=...&chapter=3
+func (h *PriceListHandler) ListItems(c *fiber.Ctx) error {
+	page, limit := paginationQuery(c)
+	items, total, err := h.svc.ListItems(c.Context(), c.Params("id"), c.Query("q"), c.QueryInt("chapter"), page, limit)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{
+		"data":  items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}))
+}
+
+// POST /price-lists/:id/items/import  (multipart, field "file")
+// Optional fields: sheet, header_row, mapping (JSON object).
+func (h *PriceListHandler) ImportItems(c *fiber.Ctx) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "file field required"))
+	}
+	opts := services.PriceListImportOptions{
+		Format: strings.TrimPrefix(strings.ToLower(filepath.Ext(fh.Filename)), "."),
+		Sheet:  c.FormValue("sheet"),
+	}
+	if v := c.FormValue("header_row"); v != "" {
+		if opts.HeaderRow, err = strconv.Atoi(v); err != nil || opts.HeaderRow < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "header_row must be a positive integer"))
+		}
+	}
+	if v := c.FormValue("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &opts.Mapping); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "mapping must be a JSON object of field to column"))
+		}
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Cannot read uploaded file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.ImportItems(c.Context(), c.Params("id"), f, opts)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(result, "Price list items imported"))
+}
+
+// POST /contracts/:id/line-items/from-price-list
+func (h *PriceListHandler) CreateLineItems(c *fiber.Ctx) error {
+	var req services.CreateCatalogLinesReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	result, err := h.svc.CreateLineItems(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(result, "Line items created"))
+}
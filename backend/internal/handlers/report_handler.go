@@ -16,9 +16,19 @@ func NewReportHandler(db *gorm.DB) *ReportHandler {
 	return &ReportHandler{svc: services.NewReportService(db)}
 }
 
-// GET /statements/:id/report
-// Streams an Excel (.xlsx) statement report as a file download.
+// GET /statements/:id/report?format=xlsx|pdf
+// Streams the statement report as a file download: an Excel (.xlsx)
+// workbook by default, or a PDF when format=pdf.
 func (h *ReportHandler) StatementReport(c *fiber.Ctx) error {
+	switch c.Query("format", "xlsx") {
+	case "pdf":
+		return h.statementPDF(c)
+	case "xlsx":
+	default:
+		return c.Status(fiber.StatusBadRequest).
+			JSON(ErrorResponse(BadRequest, "format must be xlsx or pdf"))
+	}
+
 	f, name, err := h.svc.Build(c.Context(), c.Params("id"))
 	if err != nil {
 		return serviceErr(c, err)
@@ -35,3 +45,15 @@ func (h *ReportHandler) StatementReport(c *fiber.Ctx) error {
 	c.Set("Cache-Control", "no-cache")
 	return c.Send(buf.Bytes())
 }
+
+func (h *ReportHandler) statementPDF(c *fiber.Ctx) error {
+	b, name, err := h.svc.BuildPDF(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Set("Cache-Control", "no-cache")
+	return c.Send(b)
+}
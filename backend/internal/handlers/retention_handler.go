@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type RetentionHandler struct {
+	svc *services.RetentionService
+}
+
+func NewRetentionHandler(db *gorm.DB) *RetentionHandler {
+	return &RetentionHandler{svc: services.NewRetentionService(db)}
+}
+
+// GET /contracts/:id/retention
+func (h *RetentionHandler) GetRetention(c *fiber.Ctx) error {
+	ledger, err := h.svc.Ledger(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(ledger))
+}
+
+// POST /contracts/:id/retention/release
+func (h *RetentionHandler) ReleaseRetention(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.ReleaseRetentionReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	records, err := h.svc.Release(c.Context(), c.Params("id"), req, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(records, "Retention released"))
+}
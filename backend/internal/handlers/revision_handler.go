@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type RevisionHandler struct {
+	svc *services.RevisionService
+}
+
+func NewRevisionHandler(db *gorm.DB) *RevisionHandler {
+	return &RevisionHandler{svc: services.NewRevisionService(db)}
+}
+
+// GET /statements/:id/revisions
+func (h *RevisionHandler) ListRevisions(c *fiber.Ctx) error {
+	revs, err := h.svc.List(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(revs))
+}
+
+// GET /statements/:id/revisions/diff?from=&to=
+func (h *RevisionHandler) DiffRevisions(c *fiber.Ctx) error {
+	diff, err := h.svc.Diff(c.Context(), c.Params("id"), c.QueryInt("from", -1), c.QueryInt("to", -1))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(diff))
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type SLAHandler struct {
+	svc *services.SLAService
+}
+
+func NewSLAHandler(db *gorm.DB) *SLAHandler {
+	return &SLAHandler{svc: services.NewSLAService(db)}
+}
+
+// GET /approvals/slas?entity_type=
+func (h *SLAHandler) ListSLAs(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	slas, err := h.svc.List(c.Context(), claims.CompanyID, c.Query("entity_type"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(slas))
+}
+
+// PUT /approvals/slas
+func (h *SLAHandler) UpsertSLA(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.UpsertSLAReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	sla, err := h.svc.Upsert(c.Context(), req, claims.CompanyID, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(sla, "SLA saved"))
+}
+
+// DELETE /approvals/slas/:id
+func (h *SLAHandler) DeleteSLA(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	if err := h.svc.Delete(c.Context(), c.Params("id"), claims.CompanyID); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// GET /approvals/escalations?open=true
+func (h *SLAHandler) ListEscalations(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	out, err := h.svc.ListEscalations(c.Context(), claims.CompanyID, c.QueryBool("open"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(out))
+}
+
+// POST /approvals/escalations/scan
+func (h *SLAHandler) ScanEscalations(c *fiber.Ctx) error {
+	n, err := h.svc.Scan(c.Context())
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{"escalated": n}, "Scan complete"))
+}
+
+// GET /approvals/stage-durations?entity_type=&from=&to=
+func (h *SLAHandler) StageDurations(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	out, err := h.svc.StageDurations(c.Context(), claims.CompanyID, c.Query("entity_type"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(out))
+}
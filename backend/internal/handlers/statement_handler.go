@@ -32,7 +32,8 @@ func (h *StatementHandler) CreateStatement(c *fiber.Ctx) error {
 func (h *StatementHandler) ListStatements(c *fiber.Ctx) error {
 	page, limit := paginationQuery(c)
 	status := c.Query("status")
-	stmts, total, err := h.svc.ListByContract(c.Context(), c.Params("contractId"), status, page, limit)
+	paymentStatus := c.Query("payment_status")
+	stmts, total, err := h.svc.ListByContract(c.Context(), c.Params("contractId"), status, paymentStatus, page, limit)
 	if err != nil {
 		return serviceErr(c, err)
 	}
@@ -72,11 +73,16 @@ func (h *StatementHandler) SetWorksDone(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
 	}
-	stmt, err := h.svc.SetWorksDone(c.Context(), c.Params("id"), req)
+	stmt, overruns, err := h.svc.SetWorksDone(c.Context(), c.Params("id"), req)
 	if err != nil {
 		return serviceErr(c, err)
 	}
-	return c.JSON(SuccessResponse(stmt, "Works done updated"))
+	resp := SuccessResponse(stmt, "Works done updated")
+	if len(overruns) > 0 {
+		resp.Message = "Works done updated; some lines exceed their contracted quantity"
+		resp.Errors = overruns
+	}
+	return c.JSON(resp)
 }
 
 // POST /statements/:id/extra-works
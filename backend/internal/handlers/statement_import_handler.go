@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type StatementImportHandler struct {
+	svc *services.StatementImportService
+}
+
+func NewStatementImportHandler(db *gorm.DB) *StatementImportHandler {
+	return &StatementImportHandler{svc: services.NewStatementImportService(db)}
+}
+
+// POST /statements/:id/works-done/import  (multipart, field "file")
+// Optional fields: sheet, header_row, mapping (JSON object), dry_run.
+func (h *StatementImportHandler) ImportWorksDone(c *fiber.Ctx) error {
+	fh, opts, err := statementImportForm(c)
+	if err != nil {
+		return err
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Cannot read uploaded file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.ImportWorksDone(c.Context(), c.Params("id"), f, opts)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	if opts.DryRun {
+		return c.JSON(SuccessResponse(result, "Works done preview"))
+	}
+	resp := SuccessResponse(result, "Works done imported")
+	if len(result.Overruns) > 0 {
+		resp.Message = "Works done imported; some lines exceed their contracted quantity"
+	}
+	return c.JSON(resp)
+}
+
+// POST /statements/:id/extra-works/import  (multipart, field "file")
+func (h *StatementImportHandler) ImportExtraWorks(c *fiber.Ctx) error {
+	fh, opts, err := statementImportForm(c)
+	if err != nil {
+		return err
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Cannot read uploaded file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.ImportExtraWorks(c.Context(), c.Params("id"), f, opts)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	if opts.DryRun {
+		return c.JSON(SuccessResponse(result, "Extra works preview"))
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(result, "Extra works imported"))
+}
+
+// POST /statements/:id/deductions/import  (multipart, field "file")
+func (h *StatementImportHandler) ImportDeductions(c *fiber.Ctx) error {
+	fh, opts, err := statementImportForm(c)
+	if err != nil {
+		return err
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Cannot read uploaded file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.ImportDeductions(c.Context(), c.Params("id"), f, opts)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	if opts.DryRun {
+		return c.JSON(SuccessResponse(result, "Deductions preview"))
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(result, "Deductions imported"))
+}
+
+// statementImportForm reads the upload and its options. On a bad request it
+// has already written the response; the caller returns the error as is.
+func statementImportForm(c *fiber.Ctx) (*multipart.FileHeader, services.StatementImportOptions, error) {
+	var opts services.StatementImportOptions
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, opts, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "file field required"))
+	}
+	opts.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fh.Filename)), ".")
+	opts.Sheet = c.FormValue("sheet")
+	if v := c.FormValue("header_row"); v != "" {
+		if opts.HeaderRow, err = strconv.Atoi(v); err != nil || opts.HeaderRow < 1 {
+			return nil, opts, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "header_row must be a positive integer"))
+		}
+	}
+	if v := c.FormValue("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &opts.Mapping); err != nil {
+			return nil, opts, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "mapping must be a JSON object of field to column"))
+		}
+	}
+	if v := c.FormValue("dry_run"); v != "" {
+		if opts.DryRun, err = strconv.ParseBool(v); err != nil {
+			return nil, opts, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "dry_run must be true or false"))
+		}
+	}
+	return fh, opts, nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type VariationHandler struct {
+	svc *services.VariationService
+}
+
+func NewVariationHandler(db *gorm.DB) *VariationHandler {
+	return &VariationHandler{svc: services.NewVariationService(db)}
+}
+
+// GET /contracts/:contractId/variations?status=
+func (h *VariationHandler) ListVariations(c *fiber.Ctx) error {
+	vos, err := h.svc.List(c.Context(), c.Params("contractId"), c.Query("status"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(vos))
+}
+
+// POST /contracts/:contractId/variations
+func (h *VariationHandler) CreateVariation(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.CreateVariationReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	vo, err := h.svc.Create(c.Context(), c.Params("contractId"), req, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(vo, "Variation order created"))
+}
+
+// GET /contracts/:contractId/sum
+func (h *VariationHandler) ContractSum(c *fiber.Ctx) error {
+	sum, err := h.svc.ContractSum(c.Context(), c.Params("contractId"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(sum))
+}
+
+// GET /variations/:id
+func (h *VariationHandler) GetVariation(c *fiber.Ctx) error {
+	vo, err := h.svc.Get(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(vo))
+}
+
+// PUT /variations/:id
+func (h *VariationHandler) UpdateVariation(c *fiber.Ctx) error {
+	var req services.UpdateVariationReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	vo, err := h.svc.Update(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(vo, "Variation order updated"))
+}
+
+// DELETE /variations/:id
+func (h *VariationHandler) DeleteVariation(c *fiber.Ctx) error {
+	if err := h.svc.Delete(c.Context(), c.Params("id")); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// POST /variations/:id/transition
+func (h *VariationHandler) TransitionVariation(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var body struct {
+		Action  string `json:"action"`
+		Comment string `json:"comment"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	if body.Action == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "action is required"))
+	}
+	actorID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	vo, err := h.svc.Transition(c.Context(), c.Params("id"), actorID, claims.Roles, body.Action, body.Comment)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(vo, "Variation order status updated"))
+}
+
+// GET /variations/:id/approvals
+func (h *VariationHandler) ListVariationApprovals(c *fiber.Ctx) error {
+	events, err := h.svc.ListApprovals(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(events))
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	svc *services.WebhookService
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{svc: services.NewWebhookService(db)}
+}
+
+// GET /webhooks
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	subs, err := h.svc.List(c.Context(), claims.CompanyID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(subs))
+}
+
+// GET /webhooks/event-types
+func (h *WebhookHandler) ListEventTypes(c *fiber.Ctx) error {
+	return c.JSON(SuccessResponse(services.EventTypes))
+}
+
+// POST /webhooks
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.CreateWebhookReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	sub, err := h.svc.Create(c.Context(), req, claims.CompanyID, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(sub, "Webhook created"))
+}
+
+// PATCH /webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.UpdateWebhookReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	sub, err := h.svc.Update(c.Context(), claims.CompanyID, c.Params("id"), req)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(sub, "Webhook updated"))
+}
+
+// POST /webhooks/:id/rotate-secret
+func (h *WebhookHandler) RotateSecret(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	sub, err := h.svc.RotateSecret(c.Context(), claims.CompanyID, c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(sub, "Secret rotated"))
+}
+
+// DELETE /webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	if err := h.svc.Delete(c.Context(), claims.CompanyID, c.Params("id")); err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// GET /webhooks/:id/deliveries?status=&page=&limit=
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	page, limit := paginationQuery(c)
+	items, total, err := h.svc.ListDeliveries(c.Context(), claims.CompanyID, c.Params("id"), c.Query("status"), page, limit)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(fiber.Map{
+		"data":  items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}))
+}
+
+// GET /webhooks/deliveries/:deliveryId/attempts
+func (h *WebhookHandler) ListAttempts(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	attempts, err := h.svc.ListAttempts(c.Context(), claims.CompanyID, c.Params("deliveryId"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(attempts))
+}
+
+// POST /webhooks/deliveries/:deliveryId/redeliver
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	d, err := h.svc.Redeliver(c.Context(), claims.CompanyID, c.Params("deliveryId"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(d, "Delivery re-queued"))
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sobhan-yasami/docs-db-panel/internal/services"
+	"gorm.io/gorm"
+)
+
+type WorkflowHandler struct {
+	svc *services.WorkflowService
+}
+
+func NewWorkflowHandler(db *gorm.DB) *WorkflowHandler {
+	return &WorkflowHandler{svc: services.NewWorkflowService(db)}
+}
+
+// GET /workflows?company_id=&entity_type=
+func (h *WorkflowHandler) ListWorkflows(c *fiber.Ctx) error {
+	defs, err := h.svc.List(c.Context(), c.Query("company_id"), c.Query("entity_type"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(defs))
+}
+
+// GET /workflows/builtin/:entityType
+func (h *WorkflowHandler) GetBuiltinWorkflow(c *fiber.Ctx) error {
+	steps, err := h.svc.Builtin(c.Params("entityType"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(steps))
+}
+
+// GET /workflows/:id
+func (h *WorkflowHandler) GetWorkflow(c *fiber.Ctx) error {
+	def, err := h.svc.Get(c.Context(), c.Params("id"))
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(def))
+}
+
+// POST /workflows
+func (h *WorkflowHandler) CreateWorkflow(c *fiber.Ctx) error {
+	claims := jwtClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse(Unauthorized, "Unauthorized"))
+	}
+	var req services.CreateWorkflowReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse(BadRequest, "Invalid request body"))
+	}
+	actorID, _ := uuid.Parse(claims.UserID)
+	def, err := h.svc.Create(c.Context(), req, claims.CompanyID, actorID)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SuccessResponse(def, "Workflow version created"))
+}
+
+// POST /workflows/:id/activate
+func (h *WorkflowHandler) ActivateWorkflow(c *fiber.Ctx) error {
+	def, err := h.svc.SetActive(c.Context(), c.Params("id"), true)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(def, "Workflow activated"))
+}
+
+// POST /workflows/:id/deactivate
+func (h *WorkflowHandler) DeactivateWorkflow(c *fiber.Ctx) error {
+	def, err := h.svc.SetActive(c.Context(), c.Params("id"), false)
+	if err != nil {
+		return serviceErr(c, err)
+	}
+	return c.JSON(SuccessResponse(def, "Workflow deactivated"))
+}
@@ -17,8 +17,16 @@ type ApprovalEvent struct {
 	ActorID    uuid.UUID `gorm:"type:uuid;not null;index"                                  json:"actor_id"`
 	FromStatus string    `gorm:"size:32;not null"                                          json:"from_status"`
 	ToStatus   string    `gorm:"size:32;not null"                                          json:"to_status"`
+	Action     string    `gorm:"size:32"                                                   json:"action,omitempty"` // workflow step action, e.g. approve, reject
 	Comment    string    `gorm:"type:text"                                                 json:"comment,omitempty"`
 	CreatedAt  time.Time `gorm:"not null;default:now();index"                              json:"created_at"`
+
+	// ActorPosition is the position the actor acted in (e.g. "financial_head",
+	// "project_manager"), or the role that let them through when they hold none.
+	ActorPosition string `gorm:"size:32" json:"actor_position,omitempty"`
+	// OnBehalfOfID is the principal whose authority a delegate used; nil when
+	// the actor acted in their own right.
+	OnBehalfOfID *uuid.UUID `gorm:"type:uuid;index" json:"on_behalf_of_id,omitempty"`
 }
 
 func (a *ApprovalEvent) BeforeCreate(_ *gorm.DB) error {
@@ -38,13 +38,32 @@ func AllModels() []any {
 		&Employee{},
 		&Project{},
 		&RefreshToken{},
+		&LiveTicket{},
+		&WorkflowDefinition{},
+		&ApprovalDelegation{},
+		&ApprovalSLA{},
+		&ApprovalEscalation{},
+		&Notification{},
+		&NotificationPreference{},
+		&EmailMessage{},
+		&EmailDeliveryLog{},
+		&WebhookSubscription{},
+		&WebhookEvent{},
+		&WebhookDelivery{},
+		&WebhookDeliveryAttempt{},
 		// contract side
 		&Contractor{},
 		&Consultant{},
 		&Contract{},
 		&ContractLineItem{},
+		&VariationOrder{},
+		&VariationOrderItem{},
+		&ExtensionOfTime{},
 		// financial
 		&FXRate{},
+		&PriceIndex{},
+		&PriceListEdition{},
+		&PriceListItem{},
 		// statement tree
 		&InterimStatement{},
 		&WorkDoneItem{},
@@ -52,6 +71,9 @@ func AllModels() []any {
 		&RetentionRecord{},
 		&AdvancePaymentRecord{},
 		&LiquidatedDamage{},
+		&StatementEscalation{},
+		&StatementRevision{},
+		&Payment{},
 		// audit
 		&ApprovalEvent{},
 		&Attachment{},
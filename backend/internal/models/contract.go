@@ -69,6 +69,9 @@ type Contract struct {
 	Type        ContractType `gorm:"type:varchar(32);not null;default:'lump_sum';check:type IN ('lump_sum','unit_rate','cost_plus','time_material','construction_management','design_bid_build','design_build','labor_only','turnkey','percentage')" json:"type"`
 
 	Status      ContractStatus  `gorm:"type:varchar(32);not null;default:'draft';index;check:status IN ('draft','pending_engineering','pending_finance','pending_legal','pending_ceo','ready_to_print','signed','active','closed','cancelled')" json:"status"`
+	// WorkflowDefinitionID pins the approval workflow version active when the
+	// contract was submitted; nil means the built-in workflow.
+	WorkflowDefinitionID *uuid.UUID `gorm:"type:uuid;index" json:"workflow_definition_id,omitempty"`
 	GrossBudget decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"gross_budget"`
 	Currency    string          `gorm:"size:3;not null;default:'IRR';check:char_length(currency)=3" json:"currency"`
 
@@ -82,9 +85,28 @@ type Contract struct {
 	AdvancePctBps        int `gorm:"not null;default:0;check:advance_pct_bps >= 0 AND advance_pct_bps <= 10000" json:"advance_pct_bps"`
 	SocialSecurityPctBps int `gorm:"not null;default:0;check:social_security_pct_bps >= 0 AND social_security_pct_bps <= 10000" json:"social_security_pct_bps"`
 
+	// Liquidated damages (خسارت تأخیر). LdCapPctBps caps the contract's total
+	// non-waived damages as a share of GrossBudget; 0 means uncapped.
+	LdRatePerDay decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"ld_rate_per_day"`
+	LdCapPctBps  int             `gorm:"not null;default:0;check:ld_cap_pct_bps >= 0 AND ld_cap_pct_bps <= 10000" json:"ld_cap_pct_bps"`
+
+	// CumulativeStatements switches statements to cumulative (تجمعی) mode:
+	// works done are entered as to-date quantities and the period quantity is
+	// derived from the approved statements before it.
+	CumulativeStatements bool `gorm:"not null;default:false" json:"cumulative_statements"`
+
+	// QuantityTolerancePctBps caps the cumulative certified quantity of a BOQ
+	// line as a share of its contracted quantity (General Conditions art. 29).
+	// Lines above it need an approved variation. 12500 bps = 125%.
+	QuantityTolerancePctBps int `gorm:"not null;default:12500;check:quantity_tolerance_pct_bps >= 10000" json:"quantity_tolerance_pct_bps"`
+
 	SignedAt *time.Time `json:"signed_at,omitempty"`
 	StartsOn *time.Time `gorm:"index" json:"starts_on,omitempty"`
 	EndsOn   *time.Time `gorm:"index" json:"ends_on,omitempty"`
+	// ExtensionDays is the total granted by approved extensions of time;
+	// EffectiveEndsOn = EndsOn + ExtensionDays. EndsOn keeps the original date.
+	ExtensionDays   int        `gorm:"not null;default:0;check:extension_days >= 0" json:"extension_days"`
+	EffectiveEndsOn *time.Time `gorm:"index" json:"effective_ends_on,omitempty"`
 
 	ScannedFileURL string `gorm:"size:512" json:"scanned_file_url,omitempty"`
 
@@ -92,11 +114,19 @@ type Contract struct {
 	BOQVersion          string          `gorm:"size:128"                                                                                   json:"boq_version,omitempty"`
 	ContractCoefficient decimal.Decimal `gorm:"type:numeric(8,4);not null;default:1"                                                       json:"contract_coefficient"`
 
+	// Price escalation (تعدیل, Publication 4311). Empty EscalationIndexSet
+	// disables escalation. The base quarter is the Jalali quarter of tender.
+	EscalationIndexSet       string `gorm:"size:64" json:"escalation_index_set,omitempty"`
+	EscalationBaseYear       int    `gorm:"not null;default:0" json:"escalation_base_year"`
+	EscalationBaseQuarter    int    `gorm:"not null;default:0;check:escalation_base_quarter BETWEEN 0 AND 4" json:"escalation_base_quarter"`
+	EscalationCoefficientBps int    `gorm:"not null;default:9500;check:escalation_coefficient_bps >= 0 AND escalation_coefficient_bps <= 10000" json:"escalation_coefficient_bps"`
+
 	// Cost-plus contract fields.
 	ManagementFeePctBps  int    `gorm:"not null;default:0;check:management_fee_pct_bps >= 0 AND management_fee_pct_bps <= 10000" json:"management_fee_pct_bps"`
 	FeeCalculationMethod string `gorm:"size:32"                                                                                json:"fee_calculation_method,omitempty"`
 
 	Company    *Company           `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"    json:"-"`
+	Workflow   *WorkflowDefinition `gorm:"foreignKey:WorkflowDefinitionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
 	Project    *Project           `gorm:"foreignKey:ProjectID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"    json:"-"`
 	Contractor *Contractor        `gorm:"foreignKey:ContractorID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
 	Employer   *Company           `gorm:"foreignKey:EmployerID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"   json:"-"`
@@ -107,22 +137,44 @@ type Contract struct {
 
 func (Contract) TableName() string { return "contracts" }
 
+// CompletionDate is the contractual completion date after approved
+// extensions of time, or nil when the contract has no end date.
+func (c *Contract) CompletionDate() *time.Time {
+	if c.EffectiveEndsOn != nil {
+		return c.EffectiveEndsOn
+	}
+	return c.EndsOn
+}
+
 // ContractLineItem is the Bill-of-Quantities (WBS) line for a contract.
 // ContractorID and ProjectID are denormalized from the parent contract for
-// query convenience.
+// query convenience. Lines nest through ParentID: group lines (chapters and
+// sub-chapters) carry no quantity or rate, and a child's ItemCode extends its
+// parent's.
 type ContractLineItem struct {
 	BaseModel
 	ContractID   uuid.UUID  `gorm:"type:uuid;not null;index"  json:"contract_id"`
 	ContractorID *uuid.UUID `gorm:"type:uuid;index"           json:"contractor_id,omitempty"`
 	ProjectID    *uuid.UUID `gorm:"type:uuid;index"           json:"project_id,omitempty"`
+	ParentID     *uuid.UUID `gorm:"type:uuid;index"           json:"parent_id,omitempty"`
+	IsGroup      bool       `gorm:"not null;default:false"    json:"is_group"` // chapter / sub-chapter heading
 	SortOrder    int             `gorm:"not null;default:0"                                      json:"sort_order"`
+	ItemCode     string          `gorm:"size:64;index"                                           json:"item_code,omitempty"` // شماره ردیف فهرست بها, unique per contract
+	Chapter      int             `gorm:"not null;default:0;index"                                json:"chapter"` // فصل فهرست بها; drives escalation
 	Description  string          `gorm:"type:text;not null"                                      json:"description"`
 	Unit         string          `gorm:"size:32;not null"                                        json:"unit"`
 	Quantity     decimal.Decimal `gorm:"type:numeric(20,4);not null;default:0"                   json:"quantity"`
 	UnitRate     decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0"                   json:"unit_rate"`
 	CurrencyCode string          `gorm:"size:3;not null;default:'IRR';check:char_length(currency_code)=3" json:"currency_code"`
-
-	Contract *Contract `gorm:"foreignKey:ContractID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	// VariationOrderID is the approved variation order that added this line.
+	VariationOrderID *uuid.UUID `gorm:"type:uuid;index" json:"variation_order_id,omitempty"`
+	// PriceListItemID is the catalog row the line was priced from, if any.
+	PriceListItemID *uuid.UUID `gorm:"type:uuid;index" json:"price_list_item_id,omitempty"`
+
+	Contract       *Contract         `gorm:"foreignKey:ContractID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	VariationOrder *VariationOrder   `gorm:"foreignKey:VariationOrderID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Parent         *ContractLineItem `gorm:"foreignKey:ParentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	PriceListItem  *PriceListItem    `gorm:"foreignKey:PriceListItemID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
 }
 
 func (ContractLineItem) TableName() string { return "contract_line_items" }
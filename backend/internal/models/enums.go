@@ -267,6 +267,98 @@ func (s *StatementStatus) Scan(v any) error {
 
 func (s StatementStatus) Value() (driver.Value, error) { return string(s), nil }
 
+// VariationStatus tracks the approval of a VariationOrder.
+type VariationStatus string
+
+const (
+	VariationDraft              VariationStatus = "draft"
+	VariationPendingEngineering VariationStatus = "pending_engineering"
+	VariationPendingFinance     VariationStatus = "pending_finance"
+	VariationPendingCEO         VariationStatus = "pending_ceo"
+	VariationApproved           VariationStatus = "approved"
+	VariationRejected           VariationStatus = "rejected"
+)
+
+func (s VariationStatus) Valid() bool {
+	switch s {
+	case VariationDraft, VariationPendingEngineering, VariationPendingFinance,
+		VariationPendingCEO, VariationApproved, VariationRejected:
+		return true
+	}
+	return false
+}
+
+func (s *VariationStatus) Scan(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return enumScanErr("VariationStatus", v)
+	}
+	cast := VariationStatus(str)
+	if !cast.Valid() {
+		return enumScanErr("VariationStatus", v)
+	}
+	*s = cast
+	return nil
+}
+
+func (s VariationStatus) Value() (driver.Value, error) { return string(s), nil }
+
+// ExtensionStatus tracks the approval of an ExtensionOfTime.
+type ExtensionStatus string
+
+const (
+	ExtensionDraft              ExtensionStatus = "draft"
+	ExtensionPendingEngineering ExtensionStatus = "pending_engineering"
+	ExtensionPendingCEO         ExtensionStatus = "pending_ceo"
+	ExtensionApproved           ExtensionStatus = "approved"
+	ExtensionRejected           ExtensionStatus = "rejected"
+)
+
+func (s ExtensionStatus) Valid() bool {
+	switch s {
+	case ExtensionDraft, ExtensionPendingEngineering, ExtensionPendingCEO,
+		ExtensionApproved, ExtensionRejected:
+		return true
+	}
+	return false
+}
+
+func (s *ExtensionStatus) Scan(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return enumScanErr("ExtensionStatus", v)
+	}
+	cast := ExtensionStatus(str)
+	if !cast.Valid() {
+		return enumScanErr("ExtensionStatus", v)
+	}
+	*s = cast
+	return nil
+}
+
+func (s ExtensionStatus) Value() (driver.Value, error) { return string(s), nil }
+
+// ExtensionCause is the ground an extension of time is claimed on.
+type ExtensionCause string
+
+const (
+	CauseEmployerDelay  ExtensionCause = "employer_delay"
+	CauseForceMajeure   ExtensionCause = "force_majeure"
+	CauseVariation      ExtensionCause = "variation"
+	CauseAdverseWeather ExtensionCause = "adverse_weather"
+	CauseLatePayment    ExtensionCause = "late_payment"
+	CauseOther          ExtensionCause = "other"
+)
+
+func (c ExtensionCause) Valid() bool {
+	switch c {
+	case CauseEmployerDelay, CauseForceMajeure, CauseVariation,
+		CauseAdverseWeather, CauseLatePayment, CauseOther:
+		return true
+	}
+	return false
+}
+
 type RetentionType string
 
 const (
@@ -282,6 +374,23 @@ func (t RetentionType) Valid() bool {
 	return false
 }
 
+// RetentionReleaseStage is the contract milestone at which withheld retention
+// is paid back to the contractor.
+type RetentionReleaseStage string
+
+const (
+	ReleaseProvisionalAcceptance RetentionReleaseStage = "provisional_acceptance" // تحویل موقت
+	ReleaseFinalAcceptance       RetentionReleaseStage = "final_acceptance"       // تحویل قطعی
+)
+
+func (s RetentionReleaseStage) Valid() bool {
+	switch s {
+	case ReleaseProvisionalAcceptance, ReleaseFinalAcceptance:
+		return true
+	}
+	return false
+}
+
 type AdvanceRecordType string
 
 const (
@@ -312,3 +421,70 @@ func (t LDType) Valid() bool {
 	}
 	return false
 }
+
+type PaymentMethod string
+
+const (
+	PaymentTransfer     PaymentMethod = "transfer"      // حواله / انتقال بانکی
+	PaymentCheque       PaymentMethod = "cheque"        // چک
+	PaymentTreasuryBond PaymentMethod = "treasury_bond" // اسناد خزانه
+)
+
+func (m PaymentMethod) Valid() bool {
+	switch m {
+	case PaymentTransfer, PaymentCheque, PaymentTreasuryBond:
+		return true
+	}
+	return false
+}
+
+// PaymentStatus is how much of an approved statement's net amount has been paid.
+type PaymentStatus string
+
+const (
+	PaymentUnpaid        PaymentStatus = "unpaid"
+	PaymentPartiallyPaid PaymentStatus = "partially_paid"
+	PaymentPaid          PaymentStatus = "paid"
+)
+
+// ApproverPosition is an approval seat resolved from the company and project
+// records (Company.ManagerID, the department heads, Project.ManagerID) rather
+// than from JWT roles.
+type ApproverPosition string
+
+const (
+	PositionManager         ApproverPosition = "manager"
+	PositionFinancialHead   ApproverPosition = "financial_head"
+	PositionEngineeringHead ApproverPosition = "engineering_head"
+	PositionJuridicalHead   ApproverPosition = "juridical_head"
+	PositionSecurityHead    ApproverPosition = "security_head"
+	PositionProjectManager  ApproverPosition = "project_manager"
+)
+
+func (p ApproverPosition) Valid() bool {
+	switch p {
+	case PositionManager, PositionFinancialHead, PositionEngineeringHead, PositionJuridicalHead,
+		PositionSecurityHead, PositionProjectManager:
+		return true
+	}
+	return false
+}
+
+// EscalationAction is what the SLA scheduler does with an overdue approval.
+// Each action includes the previous ones: notify also flags, delegate also
+// notifies.
+type EscalationAction string
+
+const (
+	EscalationFlag     EscalationAction = "flag"
+	EscalationNotify   EscalationAction = "notify"
+	EscalationDelegate EscalationAction = "delegate"
+)
+
+func (a EscalationAction) Valid() bool {
+	switch a {
+	case EscalationFlag, EscalationNotify, EscalationDelegate:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PriceIndex is one published price index (شاخص تعدیل) for a chapter of an
+// index set in a Jalali quarter. Chapter 0 holds the set's overall index and
+// is used for chapters that have no index of their own.
+type PriceIndex struct {
+	BaseModel
+	IndexSet string          `gorm:"size:64;not null;uniqueIndex:idx_price_index_key" json:"index_set"`
+	Chapter  int             `gorm:"not null;default:0;uniqueIndex:idx_price_index_key;check:chapter >= 0" json:"chapter"`
+	Year     int             `gorm:"not null;uniqueIndex:idx_price_index_key" json:"year"`
+	Quarter  int             `gorm:"not null;uniqueIndex:idx_price_index_key;check:quarter BETWEEN 1 AND 4" json:"quarter"`
+	Value    decimal.Decimal `gorm:"type:numeric(12,4);not null" json:"value"`
+	Source   string          `gorm:"size:128" json:"source,omitempty"`
+}
+
+func (PriceIndex) TableName() string { return "price_indices" }
+
+// StatementEscalation is the escalation (تعدیل) of one BOQ chapter on an
+// InterimStatement: Coefficient × (CurrentIndex − BaseIndex) / BaseIndex ×
+// WorkAmount. Rows are rebuilt whenever the statement aggregates are.
+type StatementEscalation struct {
+	BaseModel
+	StatementID    uuid.UUID       `gorm:"type:uuid;not null;index" json:"statement_id"`
+	Chapter        int             `gorm:"not null;default:0" json:"chapter"`
+	WorkAmount     decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"work_amount"`
+	Year           int             `gorm:"not null" json:"year"`
+	Quarter        int             `gorm:"not null" json:"quarter"`
+	BaseIndex      decimal.Decimal `gorm:"type:numeric(12,4);not null;default:0" json:"base_index"`
+	CurrentIndex   decimal.Decimal `gorm:"type:numeric(12,4);not null;default:0" json:"current_index"`
+	CoefficientBps int             `gorm:"not null;default:0" json:"coefficient_bps"`
+	Amount         decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"amount"`
+	// IndexMissing is set when the base or current index is not published yet;
+	// Amount is then zero until the index is imported and the statement recomputed.
+	IndexMissing bool `gorm:"not null;default:false" json:"index_missing"`
+
+	Statement *InterimStatement `gorm:"foreignKey:StatementID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (StatementEscalation) TableName() string { return "statement_escalations" }
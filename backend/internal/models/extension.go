@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExtensionOfTime (تمدید مدت) is a request to move a contract's completion
+// date. It runs its own approval chain; on approval DaysGranted is added to
+// Contract.ExtensionDays and Contract.EffectiveEndsOn moves, while
+// Contract.EndsOn keeps the original date.
+type ExtensionOfTime struct {
+	BaseModel
+	CompanyID     uuid.UUID       `gorm:"type:uuid;not null;index" json:"company_id"`
+	ContractID    uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_eot_contract_number" json:"contract_id"`
+	ProjectID     uuid.UUID       `gorm:"type:uuid;not null;index" json:"project_id"`
+	Number        int             `gorm:"not null;uniqueIndex:idx_eot_contract_number;check:number > 0" json:"number"`
+	Reference     string          `gorm:"size:64;not null" json:"reference"` // EOT-<number>
+	Cause         ExtensionCause  `gorm:"type:varchar(32);not null;check:cause IN ('employer_delay','force_majeure','variation','adverse_weather','late_payment','other')" json:"cause"`
+	Description   string          `gorm:"type:text" json:"description,omitempty"`
+	DaysRequested int             `gorm:"not null;check:days_requested > 0" json:"days_requested"`
+	DaysGranted   int             `gorm:"not null;default:0;check:days_granted >= 0" json:"days_granted"` // set on approval
+	Status        ExtensionStatus `gorm:"type:varchar(32);not null;default:'draft';index;check:status IN ('draft','pending_engineering','pending_ceo','approved','rejected')" json:"status"`
+	// WorkflowDefinitionID pins the approval workflow version on submission;
+	// nil follows the built-in chain.
+	WorkflowDefinitionID *uuid.UUID `gorm:"type:uuid;index" json:"workflow_definition_id,omitempty"`
+	// VariationOrderID is the variation order the delay stems from, if any.
+	VariationOrderID *uuid.UUID `gorm:"type:uuid;index" json:"variation_order_id,omitempty"`
+	// PrevEndsOn and NewEndsOn are the effective completion dates before and
+	// after this extension, recorded on approval.
+	PrevEndsOn  *time.Time `json:"prev_ends_on,omitempty"`
+	NewEndsOn   *time.Time `json:"new_ends_on,omitempty"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
+
+	Company        *Company        `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Contract       *Contract       `gorm:"foreignKey:ContractID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	VariationOrder *VariationOrder `gorm:"foreignKey:VariationOrderID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+}
+
+func (ExtensionOfTime) TableName() string { return "extensions_of_time" }
@@ -19,6 +19,7 @@ type RetentionRecord struct {
 	ReleasedAmount     decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"released_amount"`
 	CurrencyCode       string          `gorm:"size:3;not null" json:"currency_code"`
 	ReleaseDate        *time.Time      `json:"release_date,omitempty"`
+	ReleaseStage       RetentionReleaseStage `gorm:"size:32" json:"release_stage,omitempty"`
 	Notes              string          `gorm:"type:text" json:"notes,omitempty"`
 
 	InterimStatement *InterimStatement `gorm:"foreignKey:InterimStatementID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
@@ -70,3 +71,23 @@ type LiquidatedDamage struct {
 }
 
 func (LiquidatedDamage) TableName() string { return "liquidated_damages" }
+
+// Payment is money actually disbursed against an approved InterimStatement.
+// A statement may be settled by several partial payments.
+type Payment struct {
+	BaseModel
+	InterimStatementID uuid.UUID       `gorm:"type:uuid;not null;index" json:"interim_statement_id"`
+	ContractID         uuid.UUID       `gorm:"type:uuid;not null;index" json:"contract_id"`
+	Amount             decimal.Decimal `gorm:"type:numeric(20,8);not null;check:amount > 0" json:"amount"`
+	CurrencyCode       string          `gorm:"size:3;not null" json:"currency_code"`
+	PaidOn             time.Time       `gorm:"not null;index" json:"paid_on"`
+	Method             PaymentMethod   `gorm:"size:16;not null;check:method IN ('transfer','cheque','treasury_bond')" json:"method"`
+	Reference          string          `gorm:"size:128" json:"reference,omitempty"` // transfer tracking no., cheque no. or bond serial
+	Notes              string          `gorm:"type:text" json:"notes,omitempty"`
+	CreatedByID        uuid.UUID       `gorm:"type:uuid;not null;index" json:"created_by_id"`
+
+	InterimStatement *InterimStatement `gorm:"foreignKey:InterimStatementID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Contract         *Contract         `gorm:"foreignKey:ContractID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"         json:"-"`
+}
+
+func (Payment) TableName() string { return "payments" }
@@ -17,17 +17,36 @@ func AutoMigrate(db *gorm.DB) error {
 		// No FKs or only self-referential.
 		&Currency{},
 		&Company{},
+		&PriceListEdition{},
+		&PriceListItem{},
 		// Depends on Company.
 		&Employee{},
 		&Project{},
 		&RefreshToken{},
+		&LiveTicket{},
+		&WorkflowDefinition{},
+		&ApprovalDelegation{},
+		&ApprovalSLA{},
+		&ApprovalEscalation{},
+		&Notification{},
+		&NotificationPreference{},
+		&EmailMessage{},
+		&EmailDeliveryLog{},
+		&WebhookSubscription{},
+		&WebhookEvent{},
+		&WebhookDelivery{},
+		&WebhookDeliveryAttempt{},
 		// Depends on Company + Project.
 		&Contractor{},
 		&Consultant{},
 		&Contract{},
 		&ContractLineItem{},
+		&VariationOrder{},
+		&VariationOrderItem{},
+		&ExtensionOfTime{},
 		// Depends on Contract.
 		&FXRate{},
+		&PriceIndex{},
 		&InterimStatement{},
 		&WorkDoneItem{},
 		&ExtraWorkItem{},
@@ -35,6 +54,9 @@ func AutoMigrate(db *gorm.DB) error {
 		&RetentionRecord{},
 		&AdvancePaymentRecord{},
 		&LiquidatedDamage{},
+		&StatementEscalation{},
+		&StatementRevision{},
+		&Payment{},
 		// Audit — entity-polymorphic, no hard FKs.
 		&ApprovalEvent{},
 		&Attachment{},
@@ -243,6 +265,34 @@ END$$`,
 			status IN ('draft','pending_engineering','pending_finance','pending_legal',
 			           'pending_ceo','ready_to_print','signed','active','closed','cancelled')
 		)`,
+
+		// Variation orders and extensions of time joined the workflow entity types.
+		`ALTER TABLE workflow_definitions DROP CONSTRAINT IF EXISTS chk_workflow_definitions_entity_type`,
+		`ALTER TABLE workflow_definitions ADD CONSTRAINT chk_workflow_definitions_entity_type CHECK (
+			entity_type IN ('interim_statement','contract','variation_order','extension_of_time')
+		)`,
+		`ALTER TABLE approval_delegations DROP CONSTRAINT IF EXISTS chk_approval_delegations_entity_type`,
+		`ALTER TABLE approval_delegations ADD CONSTRAINT chk_approval_delegations_entity_type CHECK (
+			entity_type IN ('','interim_statement','contract','variation_order','extension_of_time')
+		)`,
+		`ALTER TABLE approval_slas DROP CONSTRAINT IF EXISTS chk_approval_slas_entity_type`,
+		`ALTER TABLE approval_slas ADD CONSTRAINT chk_approval_slas_entity_type CHECK (
+			entity_type IN ('interim_statement','contract','variation_order','extension_of_time')
+		)`,
+
+		// BOQ item codes are unique per contract; lines without a code are exempt.
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_line_items_contract_code
+		 ON contract_line_items (contract_id, item_code)
+		 WHERE item_code <> '' AND deleted_at IS NULL`,
+
+		// Work done used to copy the line item's UUID as its BOQ item code.
+		`UPDATE work_done_items w SET boq_item_code = li.item_code
+		 FROM contract_line_items li
+		 WHERE w.line_item_id = li.id AND w.boq_item_code = li.id::text`,
+
+		// Contracts created before extensions of time complete on ends_on.
+		`UPDATE contracts SET effective_ends_on = ends_on + make_interval(days => extension_days)
+		 WHERE effective_ends_on IS NULL AND ends_on IS NOT NULL`,
 	}
 	for _, s := range stmts {
 		if err := db.Exec(s).Error; err != nil {
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Notification is an in-app inbox entry for one employee, written by the
+// domain-event hook when a document needs their attention.
+type Notification struct {
+	BaseModel
+	CompanyID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"company_id"`
+	RecipientID uuid.UUID  `gorm:"type:uuid;not null;index:idx_notifications_recipient_read" json:"recipient_id"`
+	Type        string     `gorm:"size:64;not null;index" json:"type"`
+	Title       string     `gorm:"size:255;not null" json:"title"`
+	Body        string     `gorm:"type:text" json:"body,omitempty"`
+	EntityType  string     `gorm:"size:64;not null" json:"entity_type"`
+	EntityID    uuid.UUID  `gorm:"type:uuid;not null" json:"entity_id"`
+	ActorID     *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"`
+	ReadAt      *time.Time `gorm:"index:idx_notifications_recipient_read" json:"read_at,omitempty"`
+
+	Company   *Company  `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Recipient *Employee `gorm:"foreignKey:RecipientID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (Notification) TableName() string { return "notifications" }
+
+// NotificationPreference holds an employee's email opt-in. Email is off until
+// the employee enables it; Kinds limits it to some notice kinds (empty = all).
+type NotificationPreference struct {
+	BaseModel
+	EmployeeID   uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex" json:"employee_id"`
+	EmailEnabled bool           `gorm:"not null;default:false" json:"email_enabled"`
+	Language     string         `gorm:"size:2;not null;default:'fa';check:language IN ('fa','en')" json:"language"`
+	Kinds        pq.StringArray `gorm:"type:text[];not null;default:'{}'" json:"kinds"`
+
+	Employee *Employee `gorm:"foreignKey:EmployeeID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (NotificationPreference) TableName() string { return "notification_preferences" }
+
+// EmailStatus is the delivery state of a queued email.
+type EmailStatus string
+
+const (
+	EmailPending EmailStatus = "pending"
+	EmailSent    EmailStatus = "sent"
+	EmailFailed  EmailStatus = "failed" // gave up after the last retry
+)
+
+// EmailMessage is one queued email. The mail worker sends pending messages
+// whose NextAttemptAt has passed and backs off exponentially on failure.
+type EmailMessage struct {
+	BaseModel
+	CompanyID     uuid.UUID   `gorm:"type:uuid;not null;index" json:"company_id"`
+	RecipientID   uuid.UUID   `gorm:"type:uuid;not null;index" json:"recipient_id"`
+	ToAddress     string      `gorm:"size:320;not null" json:"to_address"`
+	Subject       string      `gorm:"size:255;not null" json:"subject"`
+	TextBody      string      `gorm:"type:text;not null" json:"-"`
+	HTMLBody      string      `gorm:"type:text;not null" json:"-"`
+	EventType     string      `gorm:"size:64;not null" json:"event_type"`
+	EntityType    string      `gorm:"size:64;not null" json:"entity_type"`
+	EntityID      uuid.UUID   `gorm:"type:uuid;not null" json:"entity_id"`
+	Status        EmailStatus `gorm:"type:varchar(16);not null;default:'pending';index:idx_email_messages_due;check:status IN ('pending','sent','failed')" json:"status"`
+	Attempts      int         `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time   `gorm:"not null;default:now();index:idx_email_messages_due" json:"next_attempt_at"`
+	LastError     string      `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt        *time.Time  `json:"sent_at,omitempty"`
+
+	Recipient *Employee `gorm:"foreignKey:RecipientID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (EmailMessage) TableName() string { return "email_messages" }
+
+// EmailDeliveryLog records every delivery attempt of an EmailMessage.
+type EmailDeliveryLog struct {
+	BaseModel
+	MessageID uuid.UUID `gorm:"type:uuid;not null;index" json:"message_id"`
+	Attempt   int       `gorm:"not null" json:"attempt"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Error     string    `gorm:"type:text" json:"error,omitempty"`
+
+	Message *EmailMessage `gorm:"foreignKey:MessageID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (EmailDeliveryLog) TableName() string { return "email_delivery_logs" }
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PriceListEdition is one published edition of an official price list
+// (فهرست بها), e.g. the 1404 building works list. Like PriceIndex it is
+// global reference data shared by every company.
+type PriceListEdition struct {
+	BaseModel
+	Discipline string `gorm:"size:64;not null;uniqueIndex:idx_price_list_edition" json:"discipline"` // ابنیه، راه و باند، تاسیسات برقی …
+	Year       int    `gorm:"not null;uniqueIndex:idx_price_list_edition;check:year BETWEEN 1300 AND 1500" json:"year"`
+	Title      string `gorm:"size:128;not null" json:"title"` // also recorded as Contract.BOQVersion
+	Source     string `gorm:"size:128" json:"source,omitempty"`
+	ItemCount  int64  `gorm:"-" json:"item_count"`
+}
+
+func (PriceListEdition) TableName() string { return "price_list_editions" }
+
+// PriceListItem is one row of an edition. BaseRate is the published price
+// before any contract coefficient.
+type PriceListItem struct {
+	BaseModel
+	EditionID   uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_price_list_item_code" json:"edition_id"`
+	Code        string          `gorm:"size:64;not null;uniqueIndex:idx_price_list_item_code" json:"code"`
+	Chapter     int             `gorm:"not null;default:0;index;check:chapter >= 0" json:"chapter"`
+	Description string          `gorm:"type:text;not null" json:"description"`
+	Unit        string          `gorm:"size:32;not null" json:"unit"`
+	BaseRate    decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0;check:base_rate >= 0" json:"base_rate"`
+
+	Edition *PriceListEdition `gorm:"foreignKey:EditionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (PriceListItem) TableName() string { return "price_list_items" }
@@ -37,6 +37,10 @@ type Project struct {
 	BudgetActual   decimal.Decimal `gorm:"type:numeric(20,2);not null;default:0" json:"budget_actual"`
 	Currency       string          `gorm:"size:3;not null;default:'USD';check:char_length(currency)=3" json:"currency"`
 
+	// ManagerID is the employee assigned as project manager; they approve
+	// statements of the project's contracts in the project_manager position.
+	ManagerID *uuid.UUID `gorm:"type:uuid;index" json:"manager_id,omitempty"`
+
 	Phase string         `gorm:"size:64;index"                    json:"phase,omitempty"`
 	Tags  pq.StringArray `gorm:"type:text[];not null;default:'{}'" json:"tags"`
 
@@ -44,6 +48,7 @@ type Project struct {
 	// surprises into contracts and status statements; force the caller to
 	// archive children explicitly.
 	Company   *Company   `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Manager   *Employee  `gorm:"foreignKey:ManagerID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
 	Contracts []Contract `gorm:"foreignKey:ProjectID"                                                              json:"-"`
 }
 
@@ -22,6 +22,18 @@ type InterimStatement struct {
 
 	Status   StatementStatus `gorm:"type:varchar(20);not null;default:'draft';index;check:status IN ('draft','submitted','finance_review','pm_review','director_review','approved','rejected')" json:"status"`
 	Currency string          `gorm:"size:3;not null;check:char_length(currency)=3" json:"currency"`
+	// WorkflowDefinitionID pins the approval workflow version active when the
+	// statement was submitted; nil means the built-in workflow.
+	WorkflowDefinitionID *uuid.UUID `gorm:"type:uuid;index" json:"workflow_definition_id,omitempty"`
+
+	// IsFinal marks the final account (صورت وضعیت قطعی) written at contract
+	// closeout. It carries no work of its own: it settles the outstanding
+	// advance and releases the remaining retention.
+	IsFinal bool `gorm:"not null;default:false" json:"is_final"`
+
+	// Revision counts how often a rejected statement was reopened for
+	// revision. Each submission is snapshotted as a StatementRevision.
+	Revision int `gorm:"not null;default:0" json:"revision"`
 
 	// Cached aggregates — recomputed by Recompute() before save.
 	GrossAmount          decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"gross_amount"`
@@ -32,8 +44,13 @@ type InterimStatement struct {
 	VatAmount            decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"vat_amount"`
 	SocialSecurityAmount decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"social_security_amount"`
 	LdAmount             decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"ld_amount"`
+	EscalationAmount     decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"escalation_amount"`
 	NetAmount            decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"net_amount"`
 
+	// Payments booked against the approved statement (see Payment).
+	PaidAmount    decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"paid_amount"`
+	PaymentStatus PaymentStatus   `gorm:"size:16;not null;default:'unpaid';check:payment_status IN ('unpaid','partially_paid','paid')" json:"payment_status"`
+
 	PrevProgressPct *decimal.Decimal `gorm:"type:numeric(7,4)" json:"prev_progress_pct,omitempty"`
 	ProgressPct     *decimal.Decimal `gorm:"type:numeric(7,4)" json:"progress_pct,omitempty"`
 
@@ -45,6 +62,7 @@ type InterimStatement struct {
 
 	Company  *Company  `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"  json:"-"`
 	Contract *Contract `gorm:"foreignKey:ContractID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Workflow *WorkflowDefinition `gorm:"foreignKey:WorkflowDefinitionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
 
 	WorkDoneItems    []WorkDoneItem           `gorm:"foreignKey:StatementID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"work_done_items,omitempty"`
 	ExtraWorkItems   []ExtraWorkItem          `gorm:"foreignKey:StatementID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"extra_work_items,omitempty"`
@@ -57,6 +75,7 @@ func (InterimStatement) TableName() string { return "interim_statements" }
 // grossBudget is the parent contract's gross_budget (used for progress %).
 // managementFeeBps is non-zero only for cost_plus contracts: gross_amount becomes
 // the management fee (ExtraWorkItems hold actual costs in that mode).
+// LdAmount and EscalationAmount must be set by the caller beforehand.
 func (s *InterimStatement) Recompute(retentionBps, advanceBps, vatBps, socialSecBps, managementFeeBps int, advanceOutstanding, grossBudget decimal.Decimal) {
 	gross := decimal.Zero
 	for i := range s.WorkDoneItems {
@@ -89,7 +108,8 @@ func (s *InterimStatement) Recompute(retentionBps, advanceBps, vatBps, socialSec
 	advance := decimal.Min(advanceRate, advanceOutstanding)
 	vat := grossTotal.Sub(retention).Mul(decimal.NewFromInt(int64(vatBps))).Div(bpsDivisor)
 	socialSec := grossTotal.Mul(decimal.NewFromInt(int64(socialSecBps))).Div(bpsDivisor)
-	net := grossTotal.Sub(retention).Sub(advance).Add(vat).Sub(socialSec).Sub(s.LdAmount).Sub(customDeductions)
+	net := grossTotal.Sub(retention).Sub(advance).Add(vat).Sub(socialSec).Sub(s.LdAmount).Sub(customDeductions).
+		Add(s.EscalationAmount)
 
 	s.ExtraAmount = extra
 	s.DeductionAmount = customDeductions
@@ -115,9 +135,18 @@ type WorkDoneItem struct {
 	BoQItemCode string          `gorm:"size:64;index" json:"boq_item_code,omitempty"`
 	Description string          `gorm:"type:text;not null" json:"description"`
 	UnitCode    string          `gorm:"size:32" json:"unit_code,omitempty"`
+	// Quantity and Amount are always for this period. The cumulative columns
+	// carry the to-date quantity before and after this statement.
 	Quantity    decimal.Decimal `gorm:"type:numeric(20,4);not null;default:0" json:"quantity"`
 	UnitPrice   decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"unit_price"`
 	Amount      decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"amount"`
+	PrevCumulativeQuantity decimal.Decimal `gorm:"type:numeric(20,4);not null;default:0" json:"prev_cumulative_quantity"`
+	CumulativeQuantity     decimal.Decimal `gorm:"type:numeric(20,4);not null;default:0" json:"cumulative_quantity"`
+	// CorrectionReason justifies a cumulative quantity lower than the previous one.
+	CorrectionReason string `gorm:"type:text" json:"correction_reason,omitempty"`
+	// VariationRef links an approved variation that authorises quantities above
+	// the contract's quantity tolerance.
+	VariationRef string `gorm:"size:128" json:"variation_ref,omitempty"`
 
 	Statement *InterimStatement `gorm:"foreignKey:StatementID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
 }
@@ -140,8 +169,11 @@ type ExtraWorkItem struct {
 	VariationRef     string `gorm:"size:128" json:"variation_ref,omitempty"`
 	ApprovedByClient bool   `gorm:"not null;default:false" json:"approved_by_client"`
 	ApprovalRef      string `gorm:"size:128" json:"approval_ref,omitempty"`
+	// VariationOrderID links the item to the approved variation order covering it.
+	VariationOrderID *uuid.UUID `gorm:"type:uuid;index" json:"variation_order_id,omitempty"`
 
-	Statement *InterimStatement `gorm:"foreignKey:StatementID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Statement      *InterimStatement `gorm:"foreignKey:StatementID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	VariationOrder *VariationOrder   `gorm:"foreignKey:VariationOrderID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
 }
 
 func (ExtraWorkItem) TableName() string { return "extra_work_items" }
@@ -161,3 +193,19 @@ type StatementDeductionItem struct {
 }
 
 func (StatementDeductionItem) TableName() string { return "statement_deduction_items" }
+
+// StatementRevision is the snapshot of a statement's line items and
+// aggregates taken each time it is submitted. Revision 0 is the original
+// submission; a rejected statement reopened for revision submits 1, 2, ...
+type StatementRevision struct {
+	BaseModel
+	StatementID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_statement_revisions_stmt_rev" json:"statement_id"`
+	Revision      int       `gorm:"not null;uniqueIndex:idx_statement_revisions_stmt_rev;check:revision >= 0" json:"revision"`
+	SubmittedByID uuid.UUID `gorm:"type:uuid;not null" json:"submitted_by_id"`
+	SubmittedAt   time.Time `gorm:"not null" json:"submitted_at"`
+	Snapshot      string    `gorm:"type:jsonb;not null;default:'{}'" json:"-"` // JSON-encoded services.RevisionSnapshot
+
+	Statement *InterimStatement `gorm:"foreignKey:StatementID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (StatementRevision) TableName() string { return "statement_revisions" }
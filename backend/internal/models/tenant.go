@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // RefreshToken stores the hash of an issued refresh token. The actual token
@@ -19,3 +20,19 @@ type RefreshToken struct {
 }
 
 func (RefreshToken) TableName() string { return "refresh_tokens" }
+
+// LiveTicket is a short-lived, single-use credential for opening the live
+// event stream, which browsers open without an Authorization header. Like
+// RefreshToken only the SHA-256 hash (hex-encoded) of the ticket is stored.
+type LiveTicket struct {
+	BaseModel
+	UserID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	CompanyID uuid.UUID      `gorm:"type:uuid;not null" json:"company_id"`
+	Roles     pq.StringArray `gorm:"type:text[];not null;default:'{}'" json:"roles"`
+	TokenHash string         `gorm:"size:128;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time      `gorm:"not null;index" json:"expires_at"`
+
+	User *Employee `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (LiveTicket) TableName() string { return "live_tickets" }
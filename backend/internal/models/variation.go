@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// VariationOrder (دستور تغییر) amends a signed contract: it adds line items
+// or changes the quantity or rate of existing ones. It runs its own approval
+// chain; on approval its items are applied to the contract's line items and
+// BudgetDelta is added to Contract.GrossBudget.
+type VariationOrder struct {
+	BaseModel
+	CompanyID   uuid.UUID       `gorm:"type:uuid;not null;index" json:"company_id"`
+	ContractID  uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_variation_contract_number" json:"contract_id"`
+	ProjectID   uuid.UUID       `gorm:"type:uuid;not null;index" json:"project_id"`
+	Number      int             `gorm:"not null;uniqueIndex:idx_variation_contract_number;check:number > 0" json:"number"`
+	Reference   string          `gorm:"size:64;not null" json:"reference"` // VO-<number>
+	Title       string          `gorm:"size:255;not null" json:"title"`
+	Description string          `gorm:"type:text" json:"description,omitempty"`
+	Reason      string          `gorm:"type:text" json:"reason,omitempty"`
+	Status      VariationStatus `gorm:"type:varchar(32);not null;default:'draft';index;check:status IN ('draft','pending_engineering','pending_finance','pending_ceo','approved','rejected')" json:"status"`
+	// WorkflowDefinitionID pins the approval workflow version on submission;
+	// nil follows the built-in chain.
+	WorkflowDefinitionID *uuid.UUID `gorm:"type:uuid;index" json:"workflow_definition_id,omitempty"`
+	// BudgetDelta is the sum of the items' AmountDelta at the contract rate
+	// (see Contract.ContractCoefficient), fixed on approval.
+	BudgetDelta decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"budget_delta"`
+	ApprovedAt  *time.Time      `json:"approved_at,omitempty"`
+	CreatedByID uuid.UUID       `gorm:"type:uuid;not null" json:"created_by_id"`
+
+	Items []VariationOrderItem `gorm:"foreignKey:VariationOrderID" json:"items,omitempty"`
+
+	Company  *Company  `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Contract *Contract `gorm:"foreignKey:ContractID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+}
+
+func (VariationOrder) TableName() string { return "variation_orders" }
+
+// VariationOrderItem is one change of a VariationOrder. With LineItemID set it
+// revises that line's quantity and unit rate; without it, it adds a new line.
+// PrevQuantity and PrevUnitRate snapshot the line when the order is approved.
+type VariationOrderItem struct {
+	BaseModel
+	VariationOrderID uuid.UUID       `gorm:"type:uuid;not null;index" json:"variation_order_id"`
+	LineItemID       *uuid.UUID      `gorm:"type:uuid;index" json:"line_item_id,omitempty"`
+	SortOrder        int             `gorm:"not null;default:0" json:"sort_order"`
+	Chapter          int             `gorm:"not null;default:0" json:"chapter"`
+	Description      string          `gorm:"type:text;not null" json:"description"`
+	Unit             string          `gorm:"size:32;not null" json:"unit"`
+	Quantity         decimal.Decimal `gorm:"type:numeric(20,4);not null;default:0" json:"quantity"`
+	UnitRate         decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"unit_rate"`
+	PrevQuantity     decimal.Decimal `gorm:"type:numeric(20,4);not null;default:0" json:"prev_quantity"`
+	PrevUnitRate     decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"prev_unit_rate"`
+	AmountDelta      decimal.Decimal `gorm:"type:numeric(20,8);not null;default:0" json:"amount_delta"`
+	// AppliedLineItemID is the line item created or revised on approval.
+	AppliedLineItemID *uuid.UUID `gorm:"type:uuid" json:"applied_line_item_id,omitempty"`
+
+	VariationOrder *VariationOrder   `gorm:"foreignKey:VariationOrderID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	LineItem       *ContractLineItem `gorm:"foreignKey:LineItemID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+}
+
+func (VariationOrderItem) TableName() string { return "variation_order_items" }
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebhookSubscription posts a company's domain events to an external URL.
+// Payloads are signed with HMAC-SHA256 using Secret.
+type WebhookSubscription struct {
+	BaseModel
+	CompanyID   uuid.UUID      `gorm:"type:uuid;not null;index" json:"company_id"`
+	URL         string         `gorm:"size:2048;not null" json:"url"`
+	Secret      string         `gorm:"size:128;not null" json:"secret,omitempty"` // only returned on create
+	EventTypes  pq.StringArray `gorm:"type:text[];not null" json:"event_types"`
+	Description string         `gorm:"size:255" json:"description,omitempty"`
+	Active      bool           `gorm:"not null;default:true" json:"active"`
+	CreatedByID uuid.UUID      `gorm:"type:uuid;not null" json:"created_by_id"`
+
+	Company *Company `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (WebhookSubscription) TableName() string { return "webhook_subscriptions" }
+
+// WebhookEvent is the transactional outbox: one row per domain event that at
+// least one subscription listens to, written in the transaction that caused
+// the event. Payload is the exact JSON body that is signed and posted.
+type WebhookEvent struct {
+	BaseModel
+	CompanyID  uuid.UUID `gorm:"type:uuid;not null;index" json:"company_id"`
+	Type       string    `gorm:"size:64;not null" json:"type"`
+	EntityType string    `gorm:"size:64;not null" json:"entity_type"`
+	EntityID   uuid.UUID `gorm:"type:uuid;not null" json:"entity_id"`
+	Payload    string    `gorm:"type:jsonb;not null" json:"-"`
+	OccurredAt time.Time `gorm:"not null" json:"occurred_at"`
+}
+
+func (WebhookEvent) TableName() string { return "webhook_events" }
+
+// WebhookDeliveryStatus is the state of one event's delivery to one
+// subscription.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"
+	WebhookDelivered WebhookDeliveryStatus = "delivered"
+	WebhookFailed    WebhookDeliveryStatus = "failed" // gave up after the last retry
+)
+
+// WebhookDelivery tracks one event to one subscription. The webhook worker
+// posts pending deliveries whose NextAttemptAt has passed.
+type WebhookDelivery struct {
+	BaseModel
+	SubscriptionID uuid.UUID             `gorm:"type:uuid;not null;uniqueIndex:uq_webhook_delivery" json:"subscription_id"`
+	EventID        uuid.UUID             `gorm:"type:uuid;not null;uniqueIndex:uq_webhook_delivery" json:"event_id"`
+	EventType      string                `gorm:"size:64;not null" json:"event_type"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(16);not null;default:'pending';index:idx_webhook_deliveries_due;check:status IN ('pending','delivered','failed')" json:"status"`
+	Attempts       int                   `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time             `gorm:"not null;default:now();index:idx_webhook_deliveries_due" json:"next_attempt_at"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `gorm:"type:text" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+
+	Subscription *WebhookSubscription `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Event        *WebhookEvent        `gorm:"foreignKey:EventID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (WebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+// WebhookDeliveryAttempt logs every POST of a WebhookDelivery.
+type WebhookDeliveryAttempt struct {
+	BaseModel
+	DeliveryID   uuid.UUID `gorm:"type:uuid;not null;index" json:"delivery_id"`
+	Attempt      int       `gorm:"not null" json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Success      bool      `gorm:"not null" json:"success"`
+	Error        string    `gorm:"type:text" json:"error,omitempty"`
+	ResponseBody string    `gorm:"type:text" json:"response_body,omitempty"` // first 2 KiB
+	DurationMs   int64     `gorm:"not null" json:"duration_ms"`
+
+	Delivery *WebhookDelivery `gorm:"foreignKey:DeliveryID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (WebhookDeliveryAttempt) TableName() string { return "webhook_delivery_attempts" }
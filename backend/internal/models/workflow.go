@@ -0,0 +1,148 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entity types that run through an approval workflow.
+const (
+	WorkflowInterimStatement = "interim_statement"
+	WorkflowContract         = "contract"
+	WorkflowVariationOrder   = "variation_order"
+	WorkflowExtensionOfTime  = "extension_of_time"
+)
+
+// WorkflowStep is one allowed transition of a workflow: from a status, the
+// named action moves the document to To. It may be performed by whoever holds
+// one of Positions for the document, or by a caller with one of Roles.
+type WorkflowStep struct {
+	From            string             `json:"from"`
+	Action          string             `json:"action"`
+	To              string             `json:"to"`
+	Positions       []ApproverPosition `json:"positions,omitempty"`
+	Roles           []string           `json:"roles,omitempty"`
+	CommentRequired bool               `json:"comment_required"`
+}
+
+// WorkflowSteps is stored as a jsonb array.
+type WorkflowSteps []WorkflowStep
+
+func (s WorkflowSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *WorkflowSteps) Scan(v any) error {
+	switch x := v.(type) {
+	case []byte:
+		return json.Unmarshal(x, s)
+	case string:
+		return json.Unmarshal([]byte(x), s)
+	case nil:
+		*s = nil
+		return nil
+	}
+	return fmt.Errorf("WorkflowSteps: unsupported type %T", v)
+}
+
+// WorkflowDefinition is one version of a company's approval chain for an
+// entity type. Versions are immutable; editing creates the next version, and
+// at most one version per (company, entity type) is active. Documents pin the
+// version that was active when they were submitted.
+type WorkflowDefinition struct {
+	BaseModel
+	CompanyID   uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_company_entity_version" json:"company_id"`
+	EntityType  string        `gorm:"size:64;not null;uniqueIndex:idx_workflow_company_entity_version;check:entity_type IN ('interim_statement','contract','variation_order','extension_of_time')" json:"entity_type"`
+	Version     int           `gorm:"not null;uniqueIndex:idx_workflow_company_entity_version;check:version > 0" json:"version"`
+	Active      bool          `gorm:"not null;default:false;index" json:"active"`
+	Steps       WorkflowSteps `gorm:"type:jsonb;not null;default:'[]'" json:"steps"`
+	Notes       string        `gorm:"type:text" json:"notes,omitempty"`
+	CreatedByID uuid.UUID     `gorm:"type:uuid;not null" json:"created_by_id"`
+
+	Company *Company `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+}
+
+func (WorkflowDefinition) TableName() string { return "workflow_definitions" }
+
+// ApprovalDelegation lets Delegate act with Delegator's approval authority
+// (their positions and roles) between StartsOn and EndsOn inclusive, e.g.
+// while a finance head is on leave. EntityType, ProjectID and EntityID narrow
+// the scope; empty/nil means any.
+type ApprovalDelegation struct {
+	BaseModel
+	CompanyID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"company_id"`
+	DelegatorID uuid.UUID  `gorm:"type:uuid;not null;index" json:"delegator_id"`
+	DelegateID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"delegate_id"`
+	EntityType  string     `gorm:"size:64;not null;default:'';check:entity_type IN ('','interim_statement','contract','variation_order','extension_of_time')" json:"entity_type,omitempty"`
+	ProjectID   *uuid.UUID `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	// EntityID narrows the delegation to one document (used by SLA escalation).
+	EntityID    *uuid.UUID `gorm:"type:uuid;index" json:"entity_id,omitempty"`
+	StartsOn    time.Time  `gorm:"type:date;not null;index" json:"starts_on"`
+	EndsOn      time.Time  `gorm:"type:date;not null;index;check:ends_on >= starts_on" json:"ends_on"`
+	Reason      string     `gorm:"type:text" json:"reason,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
+
+	Company   *Company  `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Delegator *Employee `gorm:"foreignKey:DelegatorID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Delegate  *Employee `gorm:"foreignKey:DelegateID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Project   *Project  `gorm:"foreignKey:ProjectID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (ApprovalDelegation) TableName() string { return "approval_delegations" }
+
+// ApprovalSLA is the time a document may spend in one workflow stage before
+// the SLA scheduler escalates it.
+type ApprovalSLA struct {
+	BaseModel
+	CompanyID     uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_sla_company_entity_stage" json:"company_id"`
+	EntityType    string           `gorm:"size:64;not null;uniqueIndex:idx_sla_company_entity_stage;check:entity_type IN ('interim_statement','contract','variation_order','extension_of_time')" json:"entity_type"`
+	Stage         string           `gorm:"size:32;not null;uniqueIndex:idx_sla_company_entity_stage" json:"stage"`
+	DurationHours int              `gorm:"not null;check:duration_hours > 0" json:"duration_hours"`
+	Action        EscalationAction `gorm:"type:varchar(16);not null;default:'flag';check:action IN ('flag','notify','delegate')" json:"action"`
+	// EscalateTo is the next-level head notified when the SLA is missed.
+	EscalateTo ApproverPosition `gorm:"size:32;not null;default:'manager'" json:"escalate_to"`
+	// DelegateID receives the stage approver's authority over the overdue
+	// document when Action is delegate.
+	DelegateID  *uuid.UUID `gorm:"type:uuid" json:"delegate_id,omitempty"`
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
+
+	Company  *Company  `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Delegate *Employee `gorm:"foreignKey:DelegateID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+}
+
+func (ApprovalSLA) TableName() string { return "approval_slas" }
+
+// ApprovalEscalation flags a document that overstayed a stage. EnteredAt is
+// the ApprovalEvent that moved it into the stage, so re-entering the stage
+// later can be escalated again. ResolvedAt is set once it moves on.
+type ApprovalEscalation struct {
+	BaseModel
+	CompanyID  uuid.UUID        `gorm:"type:uuid;not null;index" json:"company_id"`
+	EntityType string           `gorm:"size:64;not null;uniqueIndex:idx_escalation_entity_stage" json:"entity_type"`
+	EntityID   uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_escalation_entity_stage" json:"entity_id"`
+	Stage      string           `gorm:"size:32;not null;uniqueIndex:idx_escalation_entity_stage" json:"stage"`
+	EnteredAt  time.Time        `gorm:"not null;uniqueIndex:idx_escalation_entity_stage" json:"entered_at"`
+	DueAt      time.Time        `gorm:"not null" json:"due_at"`
+	Action     EscalationAction `gorm:"type:varchar(16);not null" json:"action"`
+	// EscalatedToID is the next-level head notified (notify / delegate).
+	EscalatedToID *uuid.UUID `gorm:"type:uuid" json:"escalated_to_id,omitempty"`
+	// DelegationID is the delegation created when auto-routing.
+	DelegationID *uuid.UUID `gorm:"type:uuid" json:"delegation_id,omitempty"`
+	ResolvedAt   *time.Time `gorm:"index" json:"resolved_at,omitempty"`
+
+	Company *Company `gorm:"foreignKey:CompanyID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (ApprovalEscalation) TableName() string { return "approval_escalations" }
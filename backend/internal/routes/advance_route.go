@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupAdvanceRoutes mounts the advance-payment ledger under /contracts/:id/advances.
+// Reads: head roles. Issuing an advance: manager + finance_head (+ admin/sudoer).
+func SetupAdvanceRoutes(router fiber.Router, h *handlers.AdvanceHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
+	canIssue := middlewares.RequireAnyRole("manager", "finance_head")
+
+	contracts := router.Group("/contracts", auth, headOnly)
+	contracts.Get("/:id/advances", h.ListAdvances)
+	contracts.Post("/:id/advances", canIssue, h.IssueAdvance)
+}
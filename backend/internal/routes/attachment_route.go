@@ -6,11 +6,16 @@ import (
 	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
 )
 
-// SetupAttachmentRoutes mounts the DELETE /attachments/:id route.
-// List and Upload are mounted under /contracts/:id/attachments in SetupContractRoutes.
+// SetupAttachmentRoutes mounts the DELETE /attachments/:id route and the
+// supporting documents of extensions of time under /extensions/:id/attachments.
+// Contract List and Upload are mounted under /contracts/:id/attachments in SetupContractRoutes.
 func SetupAttachmentRoutes(router fiber.Router, h *handlers.AttachmentHandler, jwtSecret string) {
 	auth := middlewares.Authenticate(jwtSecret)
 	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
 
 	router.Group("/attachments", auth, headOnly).Delete("/:id", h.Delete)
+
+	eots := router.Group("/extensions", auth)
+	eots.Get("/:id/attachments", h.ListForExtension)
+	eots.Post("/:id/attachments", h.UploadForExtension)
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupBOQRoutes mounts the BOQ import beside the contract line items, with
+// the same head-role requirement.
+func SetupBOQRoutes(router fiber.Router, h *handlers.BOQHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
+
+	contracts := router.Group("/contracts", auth, headOnly)
+	contracts.Post("/:id/line-items/import", h.ImportLineItems)
+}
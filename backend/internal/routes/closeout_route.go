@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupCloseoutRoutes mounts the final account under /contracts/:id.
+// Preview: head roles. Closeout: manager (+ admin/sudoer).
+func SetupCloseoutRoutes(router fiber.Router, h *handlers.CloseoutHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
+	managerOnly := middlewares.RequireAnyRole("manager")
+
+	contracts := router.Group("/contracts", auth, headOnly)
+	contracts.Get("/:id/final-account", h.GetFinalAccount)
+	contracts.Post("/:id/closeout", managerOnly, h.Closeout)
+}
@@ -44,6 +44,7 @@ func SetupContractRoutes(router fiber.Router, h *handlers.ContractHandler, ah *h
 	contracts.Delete("/:id", h.DeleteContract)
 
 	contracts.Get("/:id/line-items", h.ListLineItems)
+	contracts.Get("/:id/line-items/tree", h.LineItemTree)
 	contracts.Post("/:id/line-items", h.CreateLineItem)
 	contracts.Put("/:id/line-items/:itemId", h.UpdateLineItem)
 	contracts.Delete("/:id/line-items/:itemId", h.DeleteLineItem)
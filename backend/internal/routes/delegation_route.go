@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupDelegationRoutes mounts approval delegations under /delegations.
+// Any employee may delegate their own authority; the service checks who may
+// manage other employees' delegations.
+func SetupDelegationRoutes(router fiber.Router, h *handlers.DelegationHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+
+	delegations := router.Group("/delegations", auth)
+	delegations.Get("/", h.ListDelegations)
+	delegations.Post("/", h.CreateDelegation)
+	delegations.Post("/:id/revoke", h.RevokeDelegation)
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupEmailRoutes mounts the caller's notification preferences (any
+// authenticated user) and the outgoing mail queue under /emails (admin).
+func SetupEmailRoutes(router fiber.Router, h *handlers.EmailHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	adminOnly := middlewares.RequireAnyRole()
+
+	prefs := router.Group("/users/me/notification-preferences", auth)
+	prefs.Get("/", h.GetPreferences)
+	prefs.Put("/", h.UpdatePreferences)
+
+	emails := router.Group("/emails", auth, adminOnly)
+	emails.Get("/", h.ListEmails)
+	emails.Get("/:id/deliveries", h.ListDeliveries)
+	emails.Post("/:id/retry", h.RetryEmail)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupEscalationRoutes mounts the price index tables under /price-indices and
+// the per-chapter escalation of a statement under /statements/:id/escalation.
+// Reads: any authenticated. Index writes: manager + finance_head (+ admin/sudoer).
+func SetupEscalationRoutes(router fiber.Router, h *handlers.EscalationHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	canWrite := middlewares.RequireAnyRole("manager", "finance_head")
+
+	indices := router.Group("/price-indices", auth)
+	indices.Get("/", h.ListIndices)
+	indices.Put("/", canWrite, h.PutIndex)
+	indices.Post("/import", canWrite, h.ImportIndices)
+	indices.Delete("/:id", canWrite, h.DeleteIndex)
+
+	stmts := router.Group("/statements", auth)
+	stmts.Get("/:id/escalation", h.StatementEscalation)
+}
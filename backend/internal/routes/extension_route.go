@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupExtensionRoutes mounts extensions of time. Transitions are authorized
+// by the extension of time workflow. Supporting documents are mounted under
+// /extensions/:id/attachments in SetupAttachmentRoutes.
+func SetupExtensionRoutes(router fiber.Router, h *handlers.ExtensionHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+
+	contracts := router.Group("/contracts", auth)
+	contracts.Get("/:contractId/extensions", h.ListExtensions)
+	contracts.Post("/:contractId/extensions", h.CreateExtension)
+
+	eots := router.Group("/extensions", auth)
+	eots.Get("/:id", h.GetExtension)
+	eots.Put("/:id", h.UpdateExtension)
+	eots.Delete("/:id", h.DeleteExtension)
+	eots.Post("/:id/transition", h.TransitionExtension)
+	eots.Get("/:id/approvals", h.ListExtensionApprovals)
+}
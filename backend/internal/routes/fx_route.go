@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupFxRoutes mounts the FX rate table under /fx-rates.
+// Reads: any authenticated. Writes: manager + finance_head (+ admin/sudoer).
+func SetupFxRoutes(router fiber.Router, h *handlers.FxHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	canWrite := middlewares.RequireAnyRole("manager", "finance_head")
+
+	fx := router.Group("/fx-rates", auth)
+	fx.Get("/", h.ListRates)
+	fx.Get("/lookup", h.LookupRate)
+	fx.Post("/", canWrite, h.CreateRate)
+	fx.Post("/import", canWrite, h.ImportRates)
+	fx.Put("/:id", canWrite, h.UpdateRate)
+	fx.Delete("/:id", canWrite, h.DeleteRate)
+}
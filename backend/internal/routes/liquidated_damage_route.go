@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupLiquidatedDamageRoutes mounts statement damages under /statements/:id/damages.
+// Editing follows the statement (any authenticated, draft only). Waivers: manager (+ admin/sudoer).
+func SetupLiquidatedDamageRoutes(router fiber.Router, h *handlers.LiquidatedDamageHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	canWaive := middlewares.RequireAnyRole("manager")
+
+	stmts := router.Group("/statements", auth)
+	stmts.Get("/:id/damages", h.ListDamages)
+	stmts.Post("/:id/damages", h.CreateDamage)
+	stmts.Post("/:id/damages/propose-delay", h.ProposeDelay)
+	stmts.Delete("/:id/damages/:ldId", h.DeleteDamage)
+	stmts.Post("/:id/damages/:ldId/waive", canWaive, h.WaiveDamage)
+}
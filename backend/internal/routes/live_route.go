@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupLiveRoutes mounts the Server-Sent Events stream at /live. Any
+// authenticated user. Browsers' EventSource cannot set headers, so the stream
+// is opened with a single-use ?ticket= from POST /live/ticket instead of the
+// bearer token.
+func SetupLiveRoutes(router fiber.Router, h *handlers.LiveHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+
+	router.Post("/live/ticket", auth, h.Ticket)
+	router.Get("/live", h.Stream)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupNotificationRoutes mounts the caller's in-app inbox under
+// /users/me/notifications. Any authenticated user.
+func SetupNotificationRoutes(router fiber.Router, h *handlers.NotificationHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+
+	inbox := router.Group("/users/me/notifications", auth)
+	inbox.Get("/", h.ListNotifications)
+	inbox.Get("/unread-count", h.UnreadCount)
+	inbox.Post("/read-all", h.MarkAllRead)
+	inbox.Post("/:id/read", h.MarkRead)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupPaymentRoutes mounts statement payments and the per-contract balance.
+// Reads: head roles. Recording / voiding payments: manager + finance_head (+ admin/sudoer).
+func SetupPaymentRoutes(router fiber.Router, h *handlers.PaymentHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
+	canPay := middlewares.RequireAnyRole("manager", "finance_head")
+
+	contracts := router.Group("/contracts", auth, headOnly)
+	contracts.Get("/:id/payments", h.ListContractPayments)
+
+	stmts := router.Group("/statements", auth)
+	stmts.Get("/:id/payments", headOnly, h.ListStatementPayments)
+	stmts.Post("/:id/payments", canPay, h.RecordPayment)
+
+	payments := router.Group("/payments", auth, canPay)
+	payments.Delete("/:id", h.DeletePayment)
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupPriceListRoutes mounts the price list catalog under /price-lists and
+// line creation from it beside the contract line items.
+// Reads: any authenticated. Catalog writes: manager + engineering_head.
+// Line creation: the line item head roles.
+func SetupPriceListRoutes(router fiber.Router, h *handlers.PriceListHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	canWrite := middlewares.RequireAnyRole("manager", "engineering_head")
+	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
+
+	lists := router.Group("/price-lists", auth)
+	lists.Get("/", h.ListEditions)
+	lists.Post("/", canWrite, h.CreateEdition)
+	lists.Get("/:id", h.GetEdition)
+	lists.Put("/:id", canWrite, h.UpdateEdition)
+	lists.Delete("/:id", canWrite, h.DeleteEdition)
+	lists.Get("/:id/items", h.ListItems)
+	lists.Post("/:id/items/import", canWrite, h.ImportItems)
+
+	contracts := router.Group("/contracts", auth, headOnly)
+	contracts.Post("/:id/line-items/from-price-list", h.CreateLineItems)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupRetentionRoutes mounts the retention ledger under /contracts/:id/retention.
+// Reads: head roles. Releasing retention: manager + finance_head (+ admin/sudoer).
+func SetupRetentionRoutes(router fiber.Router, h *handlers.RetentionHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
+	canRelease := middlewares.RequireAnyRole("manager", "finance_head")
+
+	contracts := router.Group("/contracts", auth, headOnly)
+	contracts.Get("/:id/retention", h.GetRetention)
+	contracts.Post("/:id/retention/release", canRelease, h.ReleaseRetention)
+}
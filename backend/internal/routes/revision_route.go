@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupRevisionRoutes mounts the submission history of a statement under
+// /statements/:id/revisions. Any authenticated user, like the statement itself.
+func SetupRevisionRoutes(router fiber.Router, h *handlers.RevisionHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+
+	stmts := router.Group("/statements", auth)
+	stmts.Get("/:id/revisions", h.ListRevisions)
+	stmts.Get("/:id/revisions/diff", h.DiffRevisions)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupSLARoutes mounts approval SLAs, escalations and stage statistics under
+// /approvals. Reads: head roles. SLA changes: manager. Manual scan: admin.
+func SetupSLARoutes(router fiber.Router, h *handlers.SLAHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	headOnly := middlewares.RequireAnyRole("manager", "engineering_head", "finance_head", "juridical_head")
+	managerOnly := middlewares.RequireAnyRole("manager")
+	adminOnly := middlewares.RequireAnyRole()
+
+	approvals := router.Group("/approvals", auth, headOnly)
+	approvals.Get("/slas", h.ListSLAs)
+	approvals.Put("/slas", managerOnly, h.UpsertSLA)
+	approvals.Delete("/slas/:id", managerOnly, h.DeleteSLA)
+	approvals.Get("/escalations", h.ListEscalations)
+	approvals.Post("/escalations/scan", adminOnly, h.ScanEscalations)
+	approvals.Get("/stage-durations", h.StageDurations)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupStatementImportRoutes mounts the spreadsheet imports beside the
+// statement lines they fill, with the same access as the manual endpoints.
+func SetupStatementImportRoutes(router fiber.Router, h *handlers.StatementImportHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+
+	stmts := router.Group("/statements", auth)
+	stmts.Post("/:id/works-done/import", h.ImportWorksDone)
+	stmts.Post("/:id/extra-works/import", h.ImportExtraWorks)
+	stmts.Post("/:id/deductions/import", h.ImportDeductions)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupVariationRoutes mounts variation orders. Transitions are authorized by
+// the variation order workflow.
+func SetupVariationRoutes(router fiber.Router, h *handlers.VariationHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+
+	contracts := router.Group("/contracts", auth)
+	contracts.Get("/:contractId/variations", h.ListVariations)
+	contracts.Post("/:contractId/variations", h.CreateVariation)
+	contracts.Get("/:contractId/sum", h.ContractSum)
+
+	vos := router.Group("/variations", auth)
+	vos.Get("/:id", h.GetVariation)
+	vos.Put("/:id", h.UpdateVariation)
+	vos.Delete("/:id", h.DeleteVariation)
+	vos.Post("/:id/transition", h.TransitionVariation)
+	vos.Get("/:id/approvals", h.ListVariationApprovals)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupWebhookRoutes mounts the company's outbound webhook subscriptions and
+// their delivery log under /webhooks. Admin only.
+func SetupWebhookRoutes(router fiber.Router, h *handlers.WebhookHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	adminOnly := middlewares.RequireAnyRole()
+
+	webhooks := router.Group("/webhooks", auth, adminOnly)
+	webhooks.Get("/", h.ListWebhooks)
+	webhooks.Post("/", h.CreateWebhook)
+	webhooks.Get("/event-types", h.ListEventTypes)
+	webhooks.Get("/deliveries/:deliveryId/attempts", h.ListAttempts)
+	webhooks.Post("/deliveries/:deliveryId/redeliver", h.Redeliver)
+	webhooks.Patch("/:id", h.UpdateWebhook)
+	webhooks.Delete("/:id", h.DeleteWebhook)
+	webhooks.Post("/:id/rotate-secret", h.RotateSecret)
+	webhooks.Get("/:id/deliveries", h.ListDeliveries)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sobhan-yasami/docs-db-panel/internal/handlers"
+	"github.com/sobhan-yasami/docs-db-panel/internal/middlewares"
+)
+
+// SetupWorkflowRoutes mounts the approval workflow definitions under /workflows.
+// Admin/sudoer only.
+func SetupWorkflowRoutes(router fiber.Router, h *handlers.WorkflowHandler, jwtSecret string) {
+	auth := middlewares.Authenticate(jwtSecret)
+	adminOnly := middlewares.RequireAnyRole()
+
+	workflows := router.Group("/workflows", auth, adminOnly)
+	workflows.Get("/", h.ListWorkflows)
+	workflows.Get("/builtin/:entityType", h.GetBuiltinWorkflow)
+	workflows.Get("/:id", h.GetWorkflow)
+	workflows.Post("/", h.CreateWorkflow)
+	workflows.Post("/:id/activate", h.ActivateWorkflow)
+	workflows.Post("/:id/deactivate", h.DeactivateWorkflow)
+}
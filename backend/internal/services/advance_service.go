@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdvanceService manages the advance-payment ledger (پیش‌پرداخت) of a contract.
+// Advances are issued manually; recoveries are booked automatically when an
+// InterimStatement is approved (see recordAdvanceRecovery).
+type AdvanceService struct{ db *gorm.DB }
+
+func NewAdvanceService(db *gorm.DB) *AdvanceService { return &AdvanceService{db: db} }
+
+type IssueAdvanceReq struct {
+	Amount  string `json:"amount"`
+	TxnDate string `json:"txn_date"` // "2006-01-02"; defaults to today
+	Notes   string `json:"notes"`
+}
+
+// AdvanceLedger is the full advance history of a contract plus running totals.
+type AdvanceLedger struct {
+	Records     []model.AdvancePaymentRecord `json:"records"`
+	Issued      decimal.Decimal              `json:"issued"`
+	Recovered   decimal.Decimal              `json:"recovered"`
+	Outstanding decimal.Decimal              `json:"outstanding"`
+}
+
+func (s *AdvanceService) Issue(ctx context.Context, contractID string, req IssueAdvanceReq) (*model.AdvancePaymentRecord, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		return nil, &ServiceError{Message: "amount must be a positive number", Code: 400}
+	}
+	txnDate := time.Now()
+	if req.TxnDate != "" {
+		t := parseDate(req.TxnDate)
+		if t == nil {
+			return nil, &ServiceError{Message: "Invalid txn_date (expected YYYY-MM-DD)", Code: 400}
+		}
+		txnDate = *t
+	}
+
+	var rec model.AdvancePaymentRecord
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ct model.Contract
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if ct.Status == model.ContractCancelled || ct.Status == model.ContractClosed {
+			return &ServiceError{Message: "Cannot issue an advance on a closed/cancelled contract", Code: 409}
+		}
+
+		issued, recovered := advanceTotals(tx, cid)
+		rec = model.AdvancePaymentRecord{
+			ContractID:          cid,
+			RecordType:          model.AdvancePayment,
+			Amount:              amount,
+			CurrencyCode:        ct.Currency,
+			RecoveryPctBps:      ct.AdvancePctBps,
+			CumulativeRecovered: recovered,
+			OutstandingBalance:  issued.Add(amount).Sub(recovered),
+			TxnDate:             txnDate,
+			Notes:               req.Notes,
+		}
+		if err := tx.Create(&rec).Error; err != nil {
+			return dbErr(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &rec, nil
+}
+
+func (s *AdvanceService) Ledger(ctx context.Context, contractID string) (*AdvanceLedger, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	var records []model.AdvancePaymentRecord
+	if err := db.Where("contract_id = ?", cid).
+		Order("txn_date ASC, created_at ASC").
+		Find(&records).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	issued, recovered := advanceTotals(db, cid)
+	return &AdvanceLedger{
+		Records:     records,
+		Issued:      issued,
+		Recovered:   recovered,
+		Outstanding: decimal.Max(issued.Sub(recovered), decimal.Zero),
+	}, nil
+}
+
+// advanceTotals sums issued advances and booked recoveries for a contract.
+func advanceTotals(tx *gorm.DB, contractID uuid.UUID) (issued, recovered decimal.Decimal) {
+	var row struct {
+		Issued    decimal.Decimal
+		Recovered decimal.Decimal
+	}
+	tx.Model(&model.AdvancePaymentRecord{}).
+		Where("contract_id = ?", contractID).
+		Select(`COALESCE(SUM(CASE WHEN record_type = 'advance'  THEN amount ELSE 0 END), 0) AS issued,
+		        COALESCE(SUM(CASE WHEN record_type = 'recovery' THEN amount ELSE 0 END), 0) AS recovered`).
+		Scan(&row)
+	return row.Issued, row.Recovered
+}
+
+// advanceOutstanding is the unrecovered advance balance fed into Recompute.
+func advanceOutstanding(tx *gorm.DB, contractID uuid.UUID) decimal.Decimal {
+	issued, recovered := advanceTotals(tx, contractID)
+	return decimal.Max(issued.Sub(recovered), decimal.Zero)
+}
+
+// recordAdvanceRecovery writes the recovery row for an approved statement,
+// carrying the contract's running cumulative-recovered and outstanding totals.
+func recordAdvanceRecovery(tx *gorm.DB, stmt *model.InterimStatement, ct *model.Contract) error {
+	if !stmt.AdvanceRecovered.IsPositive() {
+		return nil
+	}
+	issued, recovered := advanceTotals(tx, ct.ID)
+	cum := recovered.Add(stmt.AdvanceRecovered)
+	rec := model.AdvancePaymentRecord{
+		ContractID:          ct.ID,
+		RecordType:          model.AdvanceRecovery,
+		InterimStatementID:  &stmt.ID,
+		Amount:              stmt.AdvanceRecovered,
+		CurrencyCode:        stmt.Currency,
+		RecoveryPctBps:      ct.AdvancePctBps,
+		CumulativeRecovered: cum,
+		OutstandingBalance:  decimal.Max(issued.Sub(cum), decimal.Zero),
+		TxnDate:             time.Now(),
+	}
+	if err := tx.Create(&rec).Error; err != nil {
+		return dbErr(err)
+	}
+	return nil
+}
@@ -16,8 +16,9 @@ import (
 )
 
 const (
-	maxAttachmentsPerContract = 3
-	maxFileSizeBytes          = 25 << 20 // 25 MB
+	maxAttachmentsPerContract  = 3
+	maxAttachmentsPerExtension = 10
+	maxFileSizeBytes           = 25 << 20 // 25 MB
 )
 
 var allowedMIMEs = map[string]struct{}{
@@ -78,6 +79,49 @@ func (s *AttachmentService) Upload(
 		return nil, &ServiceError{Code: 422, Message: "maximum 3 documents per contract"}
 	}
 
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Code: 400, Message: "invalid contract id"}
+	}
+	return s.store("contract", cid, "contracts/"+contractID, companyID, uploaderID, documentType, fh)
+}
+
+// UploadForExtension stores a supporting document of an extension of time.
+func (s *AttachmentService) UploadForExtension(
+	_ context.Context,
+	extensionID, companyID, uploaderID, documentType string,
+	fh *multipart.FileHeader,
+) (*model.Attachment, error) {
+	eid, err := uuid.Parse(extensionID)
+	if err != nil {
+		return nil, &ServiceError{Code: 400, Message: "invalid extension id"}
+	}
+	var eot model.ExtensionOfTime
+	if err := s.db.Select("id").First(&eot, "id = ? AND company_id = ?", eid, companyID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, &ServiceError{Code: 404, Message: "extension of time not found"}
+		}
+		return nil, &ServiceError{Code: 500, Message: "database error"}
+	}
+	var count int64
+	if err := s.db.Model(&model.Attachment{}).
+		Where("entity_type = ? AND entity_id = ? AND deleted_at IS NULL", model.WorkflowExtensionOfTime, eid).
+		Count(&count).Error; err != nil {
+		return nil, &ServiceError{Code: 500, Message: "database error"}
+	}
+	if count >= maxAttachmentsPerExtension {
+		return nil, &ServiceError{Code: 422, Message: fmt.Sprintf("maximum %d documents per extension of time", maxAttachmentsPerExtension)}
+	}
+	return s.store(model.WorkflowExtensionOfTime, eid, "extensions/"+extensionID, companyID, uploaderID, documentType, fh)
+}
+
+// store checks and writes an upload under dir and records it against the
+// entity, publishing attachment.created.
+func (s *AttachmentService) store(
+	entityType string, entityID uuid.UUID, dir string,
+	companyID, uploaderID, documentType string,
+	fh *multipart.FileHeader,
+) (*model.Attachment, error) {
 	if fh.Size > maxFileSizeBytes {
 		return nil, &ServiceError{Code: 422, Message: "file exceeds 25 MB limit"}
 	}
@@ -105,10 +149,6 @@ func (s *AttachmentService) Upload(
 		return nil, &ServiceError{Code: 500, Message: "cannot seek upload"}
 	}
 
-	cid, err := uuid.Parse(contractID)
-	if err != nil {
-		return nil, &ServiceError{Code: 400, Message: "invalid contract id"}
-	}
 	compID, err := uuid.Parse(companyID)
 	if err != nil {
 		return nil, &ServiceError{Code: 400, Message: "invalid company id"}
@@ -124,7 +164,7 @@ func (s *AttachmentService) Upload(
 	}
 
 	safeName := sanitizeFilename(fh.Filename)
-	storageKey := fmt.Sprintf("contracts/%s/%s_%s", contractID, fileID.String(), safeName)
+	storageKey := fmt.Sprintf("%s/%s_%s", dir, fileID.String(), safeName)
 	diskPath := filepath.Join(s.storageRoot, storageKey)
 
 	if err := os.MkdirAll(filepath.Dir(diskPath), 0o755); err != nil {
@@ -143,8 +183,8 @@ func (s *AttachmentService) Upload(
 
 	att := &model.Attachment{
 		CompanyID:    compID,
-		EntityType:   "contract",
-		EntityID:     cid,
+		EntityType:   entityType,
+		EntityID:     entityID,
 		DocumentType: documentType,
 		FileName:     safeName,
 		StorageKey:   storageKey,
@@ -152,9 +192,21 @@ func (s *AttachmentService) Upload(
 		SizeBytes:    fh.Size,
 		UploadedByID: uplID,
 	}
-	if err := s.db.Create(att).Error; err != nil {
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(att).Error; err != nil {
+			return &ServiceError{Code: 500, Message: "database insert failed"}
+		}
+		return publish(tx, &DomainEvent{
+			Type:       EventAttachmentCreated,
+			CompanyID:  compID,
+			EntityType: att.EntityType,
+			EntityID:   att.EntityID,
+			ActorID:    uplID,
+			Attachment: att,
+		})
+	}); err != nil {
 		os.Remove(diskPath)
-		return nil, &ServiceError{Code: 500, Message: "database insert failed"}
+		return nil, err
 	}
 
 	att.URL = s.attachmentURL(storageKey)
@@ -175,6 +227,21 @@ func (s *AttachmentService) ListByContract(_ context.Context, contractID string)
 	return atts, nil
 }
 
+// ListByEntity returns the attachments of any document, e.g. an extension of time.
+func (s *AttachmentService) ListByEntity(_ context.Context, entityType, entityID string) ([]model.Attachment, error) {
+	var atts []model.Attachment
+	if err := s.db.
+		Where("entity_type = ? AND entity_id = ? AND deleted_at IS NULL", entityType, entityID).
+		Order("created_at ASC").
+		Find(&atts).Error; err != nil {
+		return nil, &ServiceError{Code: 500, Message: "database error"}
+	}
+	for i := range atts {
+		atts[i].URL = s.attachmentURL(atts[i].StorageKey)
+	}
+	return atts, nil
+}
+
 func (s *AttachmentService) Delete(_ context.Context, id, companyID string) error {
 	var att model.Attachment
 	if err := s.db.First(&att, "id = ? AND company_id = ? AND deleted_at IS NULL", id, companyID).Error; err != nil {
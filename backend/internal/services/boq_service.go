@@ -0,0 +1,464 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BOQService imports Bills of Quantities (فهرست مقادیر) into a contract's
+// line items.
+type BOQService struct{ db *gorm.DB }
+
+func NewBOQService(db *gorm.DB) *BOQService { return &BOQService{db: db} }
+
+// importField is a column a spreadsheet import reads, with the header texts
+// recognised when the caller does not map it explicitly.
+type importField struct {
+	name     string
+	required bool
+	aliases  []string
+}
+
+// boqFields are the line item attributes a BOQ column can map to.
+var boqFields = []importField{
+	{"item_code", false, []string{"item_code", "item code", "code", "کد", "کد فهرست بها", "شماره ردیف", "شماره آیتم"}},
+	{"chapter", false, []string{"chapter", "فصل"}},
+	{"description", true, []string{"description", "شرح", "شرح عملیات", "شرح ردیف", "شرح آیتم"}},
+	{"unit", true, []string{"unit", "واحد"}},
+	{"quantity", true, []string{"quantity", "qty", "مقدار"}},
+	{"unit_rate", true, []string{"unit_rate", "unit rate", "rate", "بهای واحد", "فی", "قیمت واحد"}},
+}
+
+// BOQImportOptions controls how a BOQ file is read.
+type BOQImportOptions struct {
+	Format     string            // "xlsx" or "csv"
+	Sheet      string            // xlsx only; defaults to the first sheet
+	HeaderRow  int               // 1-based; defaults to 1
+	Mapping    map[string]string // field → column letter ("C") or header text
+	BOQVersion string            // sets Contract.BOQVersion when not empty
+	DryRun     bool
+}
+
+// BOQImportResult previews (DryRun) or reports an import. Columns shows the
+// column letter each field was read from.
+type BOQImportResult struct {
+	DryRun     bool                     `json:"dry_run"`
+	Columns    map[string]string        `json:"columns"`
+	Rows       int                      `json:"rows"`
+	Skipped    int                      `json:"skipped"`
+	Imported   int                      `json:"imported"`
+	Total      decimal.Decimal          `json:"total"`
+	BOQVersion string                   `json:"boq_version,omitempty"`
+	Errors     []ImportRowError         `json:"errors,omitempty"`
+	Items      []model.ContractLineItem `json:"items"`
+}
+
+// Import reads a BOQ and appends its rows to the contract's line items in one
+// transaction. Rows with a description but no unit, quantity or rate are
+// headings: with an item code they become group lines, otherwise they are
+// skipped. Each row is nested under the group whose code is the longest
+// prefix of its own, from the file or the contract. Like the other imports
+// it is all-or-nothing;
+// a dry run validates the same way and returns the preview and every error
+// without writing. Errors on line 0 concern the whole file (e.g. the budget).
+func (s *BOQService) Import(ctx context.Context, contractID string, r io.Reader, opts BOQImportOptions) (*BOQImportResult, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	sheet, err := readImportSheet(r, opts.Format, opts.Sheet, opts.HeaderRow, opts.Mapping, boqFields)
+	if err != nil {
+		return nil, err
+	}
+	result := &BOQImportResult{DryRun: opts.DryRun, Columns: sheet.columns, Total: decimal.Zero, BOQVersion: strings.TrimSpace(opts.BOQVersion)}
+
+	// Group lines by code, seeded with the contract's; file groups get their
+	// IDs up front so later rows can point at them.
+	type group struct {
+		id      uuid.UUID
+		chapter int
+	}
+	groups := make(map[string]group)
+	var existingGroups []model.ContractLineItem
+	if err := s.db.WithContext(ctx).Select("id, item_code, chapter").
+		Where("contract_id = ? AND is_group AND item_code <> ''", cid).
+		Find(&existingGroups).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	for _, g := range existingGroups {
+		groups[g.ItemCode] = group{g.ID, g.Chapter}
+	}
+
+	codes := make(map[string]int) // item_code → line it first appeared on
+	var items []model.ContractLineItem
+	for i := range sheet.rows {
+		line := sheet.line(i)
+		cell := func(field string) string { return sheet.cell(i, field) }
+		code, desc, unit, qtyRaw, rateRaw := normalizeDigits(cell("item_code")), cell("description"), cell("unit"), cell("quantity"), cell("unit_rate")
+		if code == "" && desc == "" && unit == "" && qtyRaw == "" && rateRaw == "" {
+			continue
+		}
+		result.Rows++
+		isGroup := unit == "" && qtyRaw == "" && rateRaw == ""
+		if isGroup && code == "" {
+			result.Skipped++
+			continue
+		}
+
+		var msgs []string
+		if desc == "" {
+			msgs = append(msgs, "description is required")
+		}
+		qty, rate := decimal.Zero, decimal.Zero
+		if !isGroup {
+			if unit == "" {
+				msgs = append(msgs, "unit is required")
+			} else if len(unit) > 32 {
+				msgs = append(msgs, "unit is longer than 32 characters")
+			}
+			if qty, err = parseImportDecimal(qtyRaw); err != nil || qty.IsNegative() {
+				msgs = append(msgs, fmt.Sprintf("invalid quantity %q", qtyRaw))
+			}
+			if rate, err = parseImportDecimal(rateRaw); err != nil || rate.IsNegative() {
+				msgs = append(msgs, fmt.Sprintf("invalid unit_rate %q", rateRaw))
+			}
+		}
+		chapter, err := boqChapter(normalizeDigits(cell("chapter")), code)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+		var parentID *uuid.UUID
+		for l := len(code) - 1; l > 0; l-- {
+			if g, ok := groups[code[:l]]; ok {
+				parentID = &g.id
+				if chapter == 0 {
+					chapter = g.chapter
+				}
+				break
+			}
+		}
+		if code != "" {
+			if first, dup := codes[code]; dup {
+				msgs = append(msgs, fmt.Sprintf("item_code %s repeats line %d", code, first))
+			} else {
+				codes[code] = line
+			}
+		}
+		if len(msgs) > 0 {
+			result.Errors = append(result.Errors, ImportRowError{Line: line, Message: strings.Join(msgs, "; ")})
+			continue
+		}
+		li := model.ContractLineItem{
+			ContractID:  cid,
+			ParentID:    parentID,
+			IsGroup:     isGroup,
+			ItemCode:    code,
+			Chapter:     chapter,
+			Description: desc,
+			Unit:        unit,
+			Quantity:    qty,
+			UnitRate:    rate,
+		}
+		if isGroup {
+			if li.ID, err = uuid.NewV7(); err != nil {
+				return nil, &ServiceError{Message: "uuid generation failed", Code: 500}
+			}
+			groups[code] = group{li.ID, chapter}
+		}
+		items = append(items, li)
+		result.Total = result.Total.Add(qty.Mul(rate))
+	}
+
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Select("id, contractor_id, project_id, type, currency, gross_budget, contract_coefficient")
+		if !opts.DryRun {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var ct model.Contract
+		if err := q.First(&ct, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+
+		if len(codes) > 0 {
+			list := make([]string, 0, len(codes))
+			for c := range codes {
+				list = append(list, c)
+			}
+			var taken []string
+			if err := tx.Model(&model.ContractLineItem{}).
+				Where("contract_id = ? AND item_code IN ?", cid, list).
+				Pluck("item_code", &taken).Error; err != nil {
+				return &ServiceError{Message: "Query failed", Code: 500}
+			}
+			for _, c := range taken {
+				result.Errors = append(result.Errors, ImportRowError{Line: codes[c], Message: fmt.Sprintf("item_code %s already exists on the contract", c)})
+			}
+		}
+
+		var existing struct {
+			Total   decimal.Decimal
+			MaxSort int
+		}
+		if err := tx.Model(&model.ContractLineItem{}).
+			Select("COALESCE(SUM(quantity * unit_rate), 0) AS total, COALESCE(MAX(sort_order), 0) AS max_sort").
+			Where("contract_id = ?", cid).
+			Scan(&existing).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+		// Same guard as CreateLineItem: the WBS may not exceed gross_budget.
+		if projected := contractRate(&ct, existing.Total.Add(result.Total)); ct.GrossBudget.IsPositive() && projected.GreaterThan(ct.GrossBudget) {
+			result.Errors = append(result.Errors, ImportRowError{Message: fmt.Sprintf(
+				"جمع آیتم‌های WBS (%.0f) از مبلغ قرارداد (%.0f) بیشتر می‌شود",
+				projected.InexactFloat64(), ct.GrossBudget.InexactFloat64(),
+			)})
+		}
+
+		currency := ct.Currency
+		if len(currency) != 3 {
+			currency = "IRR"
+		}
+		for i := range items {
+			items[i].ContractorID = &ct.ContractorID
+			items[i].ProjectID = &ct.ProjectID
+			items[i].SortOrder = existing.MaxSort + i + 1
+			items[i].CurrencyCode = currency
+		}
+		result.Items = items
+		if opts.DryRun {
+			return nil
+		}
+		if len(result.Errors) > 0 {
+			return &ServiceError{
+				Message: fmt.Sprintf("%d error(s); nothing imported", len(result.Errors)),
+				Code:    422,
+				Errors:  result.Errors,
+			}
+		}
+		if len(items) == 0 {
+			return &ServiceError{Message: "The file contains no BOQ rows", Code: 400}
+		}
+		if err := tx.CreateInBatches(&items, 500).Error; err != nil {
+			return dbErr(err)
+		}
+		if result.BOQVersion != "" {
+			if err := tx.Model(&ct).Update("boq_version", result.BOQVersion).Error; err != nil {
+				return &ServiceError{Message: "Failed to set boq_version", Code: 500}
+			}
+		}
+		result.Imported = len(items)
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	if result.Items == nil {
+		result.Items = []model.ContractLineItem{}
+	}
+	return result, nil
+}
+
+// importSheet is a spreadsheet read for an import: the data rows below the
+// header and the column each field was resolved to.
+type importSheet struct {
+	rows      [][]string
+	headerRow int
+	cols      map[string]int
+	columns   map[string]string // field → column letter, echoed in results
+}
+
+// readImportSheet reads the file, takes headers from headerRow (1-based,
+// default 1) and resolves fields against them.
+func readImportSheet(r io.Reader, format, sheetName string, headerRow int, mapping map[string]string, fields []importField) (*importSheet, error) {
+	rows, err := readSheetRows(r, format, sheetName)
+	if err != nil {
+		return nil, err
+	}
+	if headerRow <= 0 {
+		headerRow = 1
+	}
+	if headerRow > len(rows) {
+		return nil, &ServiceError{Message: fmt.Sprintf("The file has no row %d to read headers from", headerRow), Code: 400}
+	}
+	cols, err := resolveColumns(rows[headerRow-1], mapping, fields)
+	if err != nil {
+		return nil, err
+	}
+	sheet := &importSheet{rows: rows[headerRow:], headerRow: headerRow, cols: cols, columns: make(map[string]string, len(cols))}
+	for field, idx := range cols {
+		sheet.columns[field], _ = excelize.ColumnNumberToName(idx + 1)
+	}
+	return sheet, nil
+}
+
+// line is the 1-based file line of data row i.
+func (s *importSheet) line(i int) int { return s.headerRow + i + 1 }
+
+// cell is the trimmed text of field in data row i; "" when the field has no
+// column or the row is short.
+func (s *importSheet) cell(i int, field string) string {
+	idx, ok := s.cols[field]
+	if !ok || idx >= len(s.rows[i]) {
+		return ""
+	}
+	return strings.TrimSpace(s.rows[i][idx])
+}
+
+// blank reports whether every mapped cell of data row i is empty.
+func (s *importSheet) blank(i int) bool {
+	for field := range s.cols {
+		if s.cell(i, field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readSheetRows returns the cell text of every row of an .xlsx sheet (raw
+// values, so number formats do not leak in) or a CSV file.
+func readSheetRows(r io.Reader, format, sheet string) ([][]string, error) {
+	switch format {
+	case "xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, &ServiceError{Message: "Cannot read the workbook", Code: 400}
+		}
+		defer f.Close()
+		if sheet == "" {
+			sheet = f.GetSheetName(0)
+		}
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, &ServiceError{Message: fmt.Sprintf("Sheet %q not found", sheet), Code: 400}
+		}
+		return rows, nil
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid CSV: " + err.Error(), Code: 400}
+		}
+		if len(rows) > 0 && len(rows[0]) > 0 {
+			rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+		}
+		return rows, nil
+	}
+	return nil, &ServiceError{Message: "The file must be .xlsx or .csv", Code: 400}
+}
+
+// resolveColumns maps each field to a 0-based column index. An explicit
+// mapping may name a header or a column letter; unmapped fields are looked up
+// among the header aliases.
+func resolveColumns(header []string, mapping map[string]string, fields []importField) (map[string]int, error) {
+	byHeader := make(map[string]int, len(header))
+	for i, h := range header {
+		if key := normalizeHeader(h); key != "" {
+			if _, seen := byHeader[key]; !seen {
+				byHeader[key] = i
+			}
+		}
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.name] = true
+	}
+	var errs []string
+	for field := range mapping {
+		if !known[field] {
+			errs = append(errs, fmt.Sprintf("unknown field %q", field))
+		}
+	}
+
+	cols := make(map[string]int)
+	for _, f := range fields {
+		if want := strings.TrimSpace(mapping[f.name]); want != "" {
+			if idx, ok := byHeader[normalizeHeader(want)]; ok {
+				cols[f.name] = idx
+			} else if n, err := excelize.ColumnNameToNumber(want); err == nil && len(want) <= 3 {
+				cols[f.name] = n - 1
+			} else {
+				errs = append(errs, fmt.Sprintf("%s: no column or header %q", f.name, want))
+			}
+			continue
+		}
+		for _, alias := range f.aliases {
+			if idx, ok := byHeader[normalizeHeader(alias)]; ok {
+				cols[f.name] = idx
+				break
+			}
+		}
+		if _, ok := cols[f.name]; !ok && f.required {
+			errs = append(errs, fmt.Sprintf("%s: no matching header; map it explicitly", f.name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ServiceError{Message: "Invalid column mapping", Code: 400, Errors: errs}
+	}
+	return cols, nil
+}
+
+// boqChapter returns the explicit chapter, or the first two digits of an MPO
+// chapter, sub-chapter or item code (03, 0301, 030102 → 3).
+func boqChapter(raw, code string) (int, error) {
+	if raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid chapter %q", raw)
+		}
+		return n, nil
+	}
+	if len(code) == 2 || len(code) == 4 || len(code) == 6 {
+		if _, err := strconv.Atoi(code); err == nil {
+			n, _ := strconv.Atoi(code[:2])
+			return n, nil
+		}
+	}
+	return 0, nil
+}
+
+// normalizeHeader folds case, whitespace and the Arabic forms of ی and ک so
+// headers typed on different keyboards compare equal.
+func normalizeHeader(s string) string {
+	s = strings.NewReplacer("ي", "ی", "ك", "ک", "\u200c", " ", "_", " ").Replace(strings.ToLower(s))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// normalizeDigits converts Persian and Arabic-Indic digits to ASCII.
+func normalizeDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '۰' && r <= '۹':
+			return '0' + (r - '۰')
+		case r >= '٠' && r <= '٩':
+			return '0' + (r - '٠')
+		}
+		return r
+	}, s)
+}
+
+// parseImportDecimal parses a spreadsheet number, accepting Persian digits
+// and thousands separators.
+func parseImportDecimal(s string) (decimal.Decimal, error) {
+	s = strings.NewReplacer(",", "", "٬", "", "٫", ".", " ", "").Replace(normalizeDigits(s))
+	if s == "" {
+		return decimal.Zero, errors.New("empty")
+	}
+	return decimal.NewFromString(s)
+}
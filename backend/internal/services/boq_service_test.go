@@ -0,0 +1,74 @@
+package services
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestResolveColumnsByAlias(t *testing.T) {
+	// Arabic yeh and kaf, a ZWNJ and stray spaces still match the aliases.
+	header := []string{"ردیف", " كد فهرست بها ", "شرح‌عملیات", "واحد", "مقدار", "بهاي واحد"}
+	cols, err := resolveColumns(header, nil, boqFields)
+	if err != nil {
+		t.Fatalf("resolveColumns: %v", err)
+	}
+	want := map[string]int{"item_code": 1, "description": 2, "unit": 3, "quantity": 4, "unit_rate": 5}
+	if !maps.Equal(cols, want) {
+		t.Fatalf("cols = %v, want %v", cols, want)
+	}
+}
+
+func TestResolveColumnsExplicitMapping(t *testing.T) {
+	header := []string{"A1", "Desc", "U", "Q", "Price"}
+	mapping := map[string]string{"description": "desc", "unit": "C", "quantity": "Q", "unit_rate": "price"}
+	cols, err := resolveColumns(header, mapping, boqFields)
+	if err != nil {
+		t.Fatalf("resolveColumns: %v", err)
+	}
+	// "Q" matches a header before it is read as a column letter.
+	want := map[string]int{"description": 1, "unit": 2, "quantity": 3, "unit_rate": 4}
+	if !maps.Equal(cols, want) {
+		t.Fatalf("cols = %v, want %v", cols, want)
+	}
+}
+
+func TestResolveColumnsErrors(t *testing.T) {
+	header := []string{"شرح", "واحد"}
+	mapping := map[string]string{"colour": "A", "unit_rate": "no such header"}
+	_, err := resolveColumns(header, mapping, boqFields)
+	svcErr, ok := err.(*ServiceError)
+	if !ok || svcErr.Code != 400 {
+		t.Fatalf("err = %v, want a 400 ServiceError", err)
+	}
+	// The unknown field, the bad mapping and the unmatched quantity.
+	if errs, _ := svcErr.Errors.([]string); len(errs) != 3 {
+		t.Fatalf("errors = %v, want 3", svcErr.Errors)
+	}
+}
+
+func TestParseImportDecimal(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"1234.5", "1234.5"},
+		{"۱۲۳۴", "1234"},
+		{"۱٬۲۳۴٫۵", "1234.5"},
+		{"٣٤٥", "345"},
+		{"1,250,000", "1250000"},
+		{" ۱۲ ۵۰۰ ", "12500"},
+		{"-۲٫۵", "-2.5"},
+	}
+	for _, c := range cases {
+		got, err := parseImportDecimal(c.in)
+		if err != nil || !got.Equal(decimal.RequireFromString(c.want)) {
+			t.Errorf("parseImportDecimal(%q) = %s, %v; want %s", c.in, got, err, c.want)
+		}
+	}
+	for _, in := range []string{"", " ", "۱۲ تومان", "abc"} {
+		if _, err := parseImportDecimal(in); err == nil {
+			t.Errorf("parseImportDecimal(%q) succeeded, want an error", in)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CloseoutService closes a contract with its final account (صورت وضعیت قطعی).
+// The final account reconciles every approved InterimStatement, recovers the
+// advance still outstanding and releases the remaining retention; the contract
+// then moves active → closed.
+type CloseoutService struct{ db *gorm.DB }
+
+func NewCloseoutService(db *gorm.DB) *CloseoutService { return &CloseoutService{db: db} }
+
+type CloseoutReq struct {
+	IssuedOn string `json:"issued_on"` // "2006-01-02"; defaults to today
+	Comment  string `json:"comment"`
+}
+
+// StatementBlocker is a statement that has not finished its workflow.
+type StatementBlocker struct {
+	ID         uuid.UUID             `json:"id"`
+	SequenceNo int                   `json:"sequence_no"`
+	Status     model.StatementStatus `json:"status"`
+}
+
+// FinalAccount is the reconciliation of a contract's approved statements and
+// the settlement booked by the final statement. Before closeout it is a
+// preview and Statement is nil.
+type FinalAccount struct {
+	ContractID uuid.UUID `json:"contract_id"`
+	Currency   string    `json:"currency"`
+
+	// Totals of the approved interim statements.
+	Statements           int             `json:"statements"`
+	GrossAmount          decimal.Decimal `json:"gross_amount"`
+	ExtraAmount          decimal.Decimal `json:"extra_amount"`
+	DeductionAmount      decimal.Decimal `json:"deduction_amount"`
+	RetentionAmount      decimal.Decimal `json:"retention_amount"`
+	AdvanceRecovered     decimal.Decimal `json:"advance_recovered"`
+	VatAmount            decimal.Decimal `json:"vat_amount"`
+	SocialSecurityAmount decimal.Decimal `json:"social_security_amount"`
+	LdAmount             decimal.Decimal `json:"ld_amount"`
+	EscalationAmount     decimal.Decimal `json:"escalation_amount"`
+	NetAmount            decimal.Decimal `json:"net_amount"`
+
+	// Settlement.
+	AdvanceIssued      decimal.Decimal    `json:"advance_issued"`
+	AdvanceOutstanding decimal.Decimal    `json:"advance_outstanding"`
+	Retention          []RetentionBalance `json:"retention"`
+	RetentionToRelease decimal.Decimal    `json:"retention_to_release"`
+	FinalBalance       decimal.Decimal    `json:"final_balance"` // retention released − advance recovered
+
+	Blockers  []StatementBlocker      `json:"blockers,omitempty"`
+	Statement *model.InterimStatement `json:"statement,omitempty"`
+}
+
+// statementTotals is the scan target for the approved-statement sums.
+type statementTotals struct {
+	Statements           int
+	GrossAmount          decimal.Decimal
+	ExtraAmount          decimal.Decimal
+	DeductionAmount      decimal.Decimal
+	RetentionAmount      decimal.Decimal
+	AdvanceRecovered     decimal.Decimal
+	VatAmount            decimal.Decimal
+	SocialSecurityAmount decimal.Decimal
+	LdAmount             decimal.Decimal
+	EscalationAmount     decimal.Decimal
+	NetAmount            decimal.Decimal
+}
+
+// Preview returns the final account as it would be booked now, including the
+// statements that still block closeout.
+func (s *CloseoutService) Preview(ctx context.Context, contractID string) (*FinalAccount, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	var ct model.Contract
+	if err := db.First(&ct, "id = ?", cid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Contract not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return finalAccount(db, &ct)
+}
+
+// Closeout writes the final statement, books its settlement and closes the
+// contract. Only active contracts whose statements are all approved or
+// rejected can be closed.
+func (s *CloseoutService) Closeout(ctx context.Context, contractID string, req CloseoutReq, actorID uuid.UUID) (*FinalAccount, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	issuedOn := time.Now().Truncate(24 * time.Hour)
+	if req.IssuedOn != "" {
+		t := parseDate(req.IssuedOn)
+		if t == nil {
+			return nil, &ServiceError{Message: "Invalid issued_on (expected YYYY-MM-DD)", Code: 400}
+		}
+		issuedOn = *t
+	}
+
+	var fa *FinalAccount
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ct model.Contract
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if ct.Status != model.ContractActive {
+			return &ServiceError{Message: fmt.Sprintf("Only active contracts can be closed (status %q)", ct.Status), Code: 409}
+		}
+
+		var err error
+		fa, err = finalAccount(tx, &ct)
+		if err != nil {
+			return err
+		}
+		if len(fa.Blockers) > 0 {
+			seqs := make([]string, len(fa.Blockers))
+			for i, b := range fa.Blockers {
+				seqs[i] = fmt.Sprintf("#%d (%s)", b.SequenceNo, b.Status)
+			}
+			return &ServiceError{
+				Message: "Statements still in progress: " + strings.Join(seqs, ", "),
+				Code:    409,
+				Errors:  fa.Blockers,
+			}
+		}
+
+		stmt, err := writeFinalStatement(tx, &ct, fa, issuedOn, req.Comment, actorID)
+		if err != nil {
+			return err
+		}
+		fa.Statement = stmt
+
+		if err := tx.Model(&ct).Update("status", model.ContractClosed).Error; err != nil {
+			return &ServiceError{Message: "Failed to close contract", Code: 500}
+		}
+		evt := model.ApprovalEvent{
+			EntityType: "contract",
+			EntityID:   ct.ID,
+			ActorID:    actorID,
+			FromStatus: string(model.ContractActive),
+			ToStatus:   string(model.ContractClosed),
+			Comment:    req.Comment,
+			CreatedAt:  time.Now(),
+		}
+		return recordApproval(tx, ct.CompanyID, &evt)
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return fa, nil
+}
+
+// finalAccount reconciles the approved statements of ct against its advance
+// and retention ledgers. Statements neither approved nor rejected are listed
+// as blockers.
+func finalAccount(tx *gorm.DB, ct *model.Contract) (*FinalAccount, error) {
+	var blockers []StatementBlocker
+	if err := tx.Model(&model.InterimStatement{}).
+		Select("id, sequence_no, status").
+		Where("contract_id = ? AND status NOT IN ?", ct.ID, []model.StatementStatus{model.StatementApproved, model.StatementRejected}).
+		Order("sequence_no ASC").
+		Scan(&blockers).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+
+	var t statementTotals
+	if err := tx.Model(&model.InterimStatement{}).
+		Select(`COUNT(*) AS statements,
+			COALESCE(SUM(gross_amount), 0) AS gross_amount,
+			COALESCE(SUM(extra_amount), 0) AS extra_amount,
+			COALESCE(SUM(deduction_amount), 0) AS deduction_amount,
+			COALESCE(SUM(retention_amount), 0) AS retention_amount,
+			COALESCE(SUM(advance_recovered), 0) AS advance_recovered,
+			COALESCE(SUM(vat_amount), 0) AS vat_amount,
+			COALESCE(SUM(social_security_amount), 0) AS social_security_amount,
+			COALESCE(SUM(ld_amount), 0) AS ld_amount,
+			COALESCE(SUM(escalation_amount), 0) AS escalation_amount,
+			COALESCE(SUM(net_amount), 0) AS net_amount`).
+		Where("contract_id = ? AND status = ? AND NOT is_final", ct.ID, model.StatementApproved).
+		Scan(&t).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+
+	ledger, err := retentionLedger(tx, ct.ID)
+	if err != nil {
+		return nil, err
+	}
+	issued, _ := advanceTotals(tx, ct.ID)
+	outstanding := advanceOutstanding(tx, ct.ID)
+
+	return &FinalAccount{
+		ContractID:           ct.ID,
+		Currency:             ct.Currency,
+		Statements:           t.Statements,
+		GrossAmount:          t.GrossAmount,
+		ExtraAmount:          t.ExtraAmount,
+		DeductionAmount:      t.DeductionAmount,
+		RetentionAmount:      t.RetentionAmount,
+		AdvanceRecovered:     t.AdvanceRecovered,
+		VatAmount:            t.VatAmount,
+		SocialSecurityAmount: t.SocialSecurityAmount,
+		LdAmount:             t.LdAmount,
+		EscalationAmount:     t.EscalationAmount,
+		NetAmount:            t.NetAmount,
+		AdvanceIssued:        issued,
+		AdvanceOutstanding:   outstanding,
+		Retention:            ledger.Balances,
+		RetentionToRelease:   ledger.Balance,
+		FinalBalance:         ledger.Balance.Sub(outstanding),
+		Blockers:             blockers,
+	}, nil
+}
+
+// writeFinalStatement creates the approved final statement. Its only amounts
+// are the settlement: the outstanding advance is recovered and the retention
+// balance is paid back (a negative withholding), so
+// NetAmount = retention released − advance recovered.
+func writeFinalStatement(tx *gorm.DB, ct *model.Contract, fa *FinalAccount, issuedOn time.Time, comment string, actorID uuid.UUID) (*model.InterimStatement, error) {
+	var span struct {
+		MaxSeq      int
+		PeriodStart *time.Time
+		PeriodEnd   *time.Time
+	}
+	if err := tx.Model(&model.InterimStatement{}).
+		Select("COALESCE(MAX(sequence_no), 0) AS max_seq, MIN(period_start) AS period_start, MAX(period_end) AS period_end").
+		Where("contract_id = ?", ct.ID).
+		Scan(&span).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	periodStart, periodEnd := issuedOn, issuedOn
+	if span.PeriodStart != nil {
+		periodStart = *span.PeriodStart
+	} else if ct.StartsOn != nil {
+		periodStart = *ct.StartsOn
+	}
+	if span.PeriodEnd != nil {
+		periodEnd = *span.PeriodEnd
+	}
+
+	stmt := &model.InterimStatement{
+		CompanyID:        ct.CompanyID,
+		ContractID:       ct.ID,
+		SequenceNo:       span.MaxSeq + 1,
+		PeriodStart:      periodStart,
+		PeriodEnd:        periodEnd,
+		IssuedOn:         issuedOn,
+		Status:           model.StatementApproved,
+		Currency:         ct.Currency,
+		IsFinal:          true,
+		RetentionAmount:  fa.RetentionToRelease.Neg(),
+		AdvanceRecovered: fa.AdvanceOutstanding,
+		NetAmount:        fa.FinalBalance,
+		Notes:            comment,
+	}
+	if err := tx.Create(stmt).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	if err := lockFxRate(tx, stmt); err != nil {
+		return nil, err
+	}
+	if err := recordAdvanceRecovery(tx, stmt, ct); err != nil {
+		return nil, err
+	}
+	if err := syncPaidAmount(tx, stmt); err != nil {
+		return nil, err
+	}
+	for _, b := range fa.Retention {
+		if !b.Balance.IsPositive() {
+			continue
+		}
+		records, err := heldRetention(tx, ct.ID, b.RetentionType)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := releaseRetention(tx, records, b.Balance, model.ReleaseFinalAcceptance, issuedOn, "final account", actorID); err != nil {
+			return nil, err
+		}
+	}
+
+	evt := model.ApprovalEvent{
+		EntityType: "interim_statement",
+		EntityID:   stmt.ID,
+		ActorID:    actorID,
+		FromStatus: string(model.StatementDraft),
+		ToStatus:   string(model.StatementApproved),
+		Comment:    "final account",
+		CreatedAt:  time.Now(),
+	}
+	if err := recordApproval(tx, stmt.CompanyID, &evt); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -35,6 +36,7 @@ type CreateProjectReq struct {
 	BudgetEstimate string   `json:"budget_estimate"`
 	Currency       string   `json:"currency"`
 	Tags           []string `json:"tags"`
+	ManagerID      string   `json:"manager_id"` // employee approving statements as project manager
 }
 
 type UpdateProjectReq struct {
@@ -50,6 +52,7 @@ type UpdateProjectReq struct {
 	BudgetActual   *string  `json:"budget_actual"`
 	Currency       *string  `json:"currency"`
 	Tags           []string `json:"tags"`
+	ManagerID      *string  `json:"manager_id"` // "" clears the assignment
 }
 
 // ProjectListItem embeds Project and adds the live contracts count.
@@ -89,8 +92,18 @@ func (s *ProjectService) Create(ctx context.Context, companyID string, req Creat
 		priority = model.PriorityMedium
 	}
 
+	var managerID *uuid.UUID
+	if req.ManagerID != "" {
+		mid, err := uuid.Parse(req.ManagerID)
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid manager_id", Code: 400}
+		}
+		managerID = &mid
+	}
+
 	p := model.Project{
 		CompanyID:      cid,
+		ManagerID:      managerID,
 		Code:           req.Code,
 		Name:           req.Name,
 		Description:    req.Description,
@@ -203,6 +216,15 @@ func (s *ProjectService) Update(ctx context.Context, id string, req UpdateProjec
 	if req.Tags != nil {
 		updates["tags"] = pq.StringArray(req.Tags)
 	}
+	if req.ManagerID != nil {
+		if *req.ManagerID == "" {
+			updates["manager_id"] = nil
+		} else if mid, err := uuid.Parse(*req.ManagerID); err == nil {
+			updates["manager_id"] = mid
+		} else {
+			return nil, &ServiceError{Message: "Invalid manager_id", Code: 400}
+		}
+	}
 	if req.BudgetEstimate != nil {
 		if v, err := decimal.NewFromString(*req.BudgetEstimate); err == nil {
 			updates["budget_estimate"] = v
@@ -537,43 +559,63 @@ type CreateContractReq struct {
 	RetentionPctBps       int     `json:"retention_pct_bps"`
 	AdvancePctBps         int     `json:"advance_pct_bps"`
 	SocialSecurityPctBps  int     `json:"social_security_pct_bps"`
+	LdRatePerDay          string  `json:"ld_rate_per_day"`
+	LdCapPctBps           int     `json:"ld_cap_pct_bps"`
 	ScannedFileURL        string  `json:"scanned_file_url"`
 	// Unit-rate fields.
 	BOQVersion          string `json:"boq_version"`
 	ContractCoefficient string `json:"contract_coefficient"`
+	// Statement mode.
+	CumulativeStatements    bool `json:"cumulative_statements"`
+	QuantityTolerancePctBps int  `json:"quantity_tolerance_pct_bps"` // 0 = default 12500 (125%)
+	// Price escalation fields.
+	EscalationIndexSet       string `json:"escalation_index_set"`
+	EscalationBaseYear       int    `json:"escalation_base_year"`
+	EscalationBaseQuarter    int    `json:"escalation_base_quarter"`
+	EscalationCoefficientBps *int   `json:"escalation_coefficient_bps"` // nil = default 9500 (0.95)
 	// Cost-plus fields.
 	ManagementFeePctBps  int    `json:"management_fee_pct_bps"`
 	FeeCalculationMethod string `json:"fee_calculation_method"`
 }
 
 type UpdateContractReq struct {
-	Title                 *string `json:"title"`
-	Description           *string `json:"description"`
-	Status                *string `json:"status"`
-	GrossBudget           *string `json:"gross_budget"`
-	Currency              *string `json:"currency"`
-	StartsOn              *string `json:"starts_on"`
-	EndsOn                *string `json:"ends_on"`
-	EmployerID            *string `json:"employer_id"`
-	ConsultantID          *string `json:"consultant_id"`
-	PerformanceBondPctBps *int    `json:"performance_bond_pct_bps"`
-	InsuranceRatePctBps   *int    `json:"insurance_rate_pct_bps"`
-	VatPctBps             *int    `json:"vat_pct_bps"`
-	RetentionPctBps       *int    `json:"retention_pct_bps"`
-	AdvancePctBps         *int    `json:"advance_pct_bps"`
-	SocialSecurityPctBps  *int    `json:"social_security_pct_bps"`
-	ScannedFileURL        *string `json:"scanned_file_url"`
-	BOQVersion            *string `json:"boq_version"`
-	ContractCoefficient   *string `json:"contract_coefficient"`
-	ManagementFeePctBps   *int    `json:"management_fee_pct_bps"`
-	FeeCalculationMethod  *string `json:"fee_calculation_method"`
+	Title                    *string `json:"title"`
+	Description              *string `json:"description"`
+	Status                   *string `json:"status"`
+	GrossBudget              *string `json:"gross_budget"`
+	Currency                 *string `json:"currency"`
+	StartsOn                 *string `json:"starts_on"`
+	EndsOn                   *string `json:"ends_on"`
+	EmployerID               *string `json:"employer_id"`
+	ConsultantID             *string `json:"consultant_id"`
+	PerformanceBondPctBps    *int    `json:"performance_bond_pct_bps"`
+	InsuranceRatePctBps      *int    `json:"insurance_rate_pct_bps"`
+	VatPctBps                *int    `json:"vat_pct_bps"`
+	RetentionPctBps          *int    `json:"retention_pct_bps"`
+	AdvancePctBps            *int    `json:"advance_pct_bps"`
+	SocialSecurityPctBps     *int    `json:"social_security_pct_bps"`
+	LdRatePerDay             *string `json:"ld_rate_per_day"`
+	LdCapPctBps              *int    `json:"ld_cap_pct_bps"`
+	ScannedFileURL           *string `json:"scanned_file_url"`
+	BOQVersion               *string `json:"boq_version"`
+	ContractCoefficient      *string `json:"contract_coefficient"`
+	CumulativeStatements     *bool   `json:"cumulative_statements"`
+	QuantityTolerancePctBps  *int    `json:"quantity_tolerance_pct_bps"`
+	EscalationIndexSet       *string `json:"escalation_index_set"`
+	EscalationBaseYear       *int    `json:"escalation_base_year"`
+	EscalationBaseQuarter    *int    `json:"escalation_base_quarter"`
+	EscalationCoefficientBps *int    `json:"escalation_coefficient_bps"`
+	ManagementFeePctBps      *int    `json:"management_fee_pct_bps"`
+	FeeCalculationMethod     *string `json:"fee_calculation_method"`
 }
 
 // ContractListItem embeds Contract and adds denormalized display fields.
+// Expired marks an active contract past its effective completion date.
 type ContractListItem struct {
 	model.Contract
 	ContractorName string `gorm:"column:contractor_name" json:"contractor_name"`
 	ProjectName    string `gorm:"column:project_name"    json:"project_name"`
+	Expired        bool   `gorm:"column:expired"         json:"expired"`
 }
 
 func parseDate(s string) *time.Time {
@@ -678,8 +720,14 @@ func (s *ContractSvc) Create(ctx context.Context, callerCompanyID string, req Cr
 		RetentionPctBps:       req.RetentionPctBps,
 		AdvancePctBps:         req.AdvancePctBps,
 		SocialSecurityPctBps:  req.SocialSecurityPctBps,
+		LdCapPctBps:           req.LdCapPctBps,
 		ScannedFileURL:        req.ScannedFileURL,
 	}
+	if req.LdRatePerDay != "" {
+		if v, err := decimal.NewFromString(req.LdRatePerDay); err == nil {
+			ct.LdRatePerDay = v
+		}
+	}
 	if req.EmployerID != "" {
 		if eid, err := uuid.Parse(req.EmployerID); err == nil {
 			ct.EmployerID = &eid
@@ -691,6 +739,21 @@ func (s *ContractSvc) Create(ctx context.Context, callerCompanyID string, req Cr
 		}
 	}
 	ct.BOQVersion = req.BOQVersion
+	ct.CumulativeStatements = req.CumulativeStatements
+	ct.QuantityTolerancePctBps = req.QuantityTolerancePctBps
+	if ct.QuantityTolerancePctBps == 0 {
+		ct.QuantityTolerancePctBps = defaultQuantityTolerancePctBps
+	}
+	if ct.QuantityTolerancePctBps < 10000 {
+		return nil, &ServiceError{Message: errQuantityTolerance, Code: 400}
+	}
+	ct.EscalationIndexSet = req.EscalationIndexSet
+	ct.EscalationBaseYear = req.EscalationBaseYear
+	ct.EscalationBaseQuarter = req.EscalationBaseQuarter
+	ct.EscalationCoefficientBps = defaultEscalationCoefficientBps
+	if req.EscalationCoefficientBps != nil {
+		ct.EscalationCoefficientBps = *req.EscalationCoefficientBps
+	}
 	ct.FeeCalculationMethod = req.FeeCalculationMethod
 	ct.ManagementFeePctBps = req.ManagementFeePctBps
 	if req.ContractCoefficient != "" {
@@ -705,6 +768,7 @@ func (s *ContractSvc) Create(ctx context.Context, callerCompanyID string, req Cr
 	}
 	if req.EndsOn != nil && *req.EndsOn != "" {
 		ct.EndsOn = parseDate(*req.EndsOn)
+		ct.EffectiveEndsOn = ct.EndsOn
 	}
 
 	if err := s.db.WithContext(ctx).Create(&ct).Error; err != nil {
@@ -733,7 +797,8 @@ func (s *ContractSvc) List(ctx context.Context, companyID, projectID, search str
 		Table("contracts").
 		Select(`contracts.*,
 			COALESCE(contractors.display_name, '') AS contractor_name,
-			COALESCE(projects.name, '')             AS project_name`).
+			COALESCE(projects.name, '')             AS project_name,
+			(contracts.status = 'active' AND COALESCE(contracts.effective_ends_on, contracts.ends_on) < CURRENT_DATE) AS expired`).
 		Joins("LEFT JOIN contractors ON contractors.id = contracts.contractor_id AND contractors.deleted_at IS NULL").
 		Joins("LEFT JOIN projects ON projects.id = contracts.project_id AND projects.deleted_at IS NULL").
 		Where("contracts.deleted_at IS NULL")
@@ -827,8 +892,20 @@ func (s *ContractSvc) Update(ctx context.Context, id string, req UpdateContractR
 	if req.SocialSecurityPctBps != nil {
 		updates["social_security_pct_bps"] = *req.SocialSecurityPctBps
 	}
+	if req.LdRatePerDay != nil {
+		if v, err := decimal.NewFromString(*req.LdRatePerDay); err == nil {
+			updates["ld_rate_per_day"] = v
+		}
+	}
+	if req.LdCapPctBps != nil {
+		updates["ld_cap_pct_bps"] = *req.LdCapPctBps
+	}
 	if req.GrossBudget != nil {
-		if v, err := decimal.NewFromString(*req.GrossBudget); err == nil {
+		if v, err := decimal.NewFromString(*req.GrossBudget); err == nil && !v.Equal(ct.GrossBudget) {
+			// Past draft the budget only moves through approved variation orders.
+			if ct.Status != model.ContractDraft {
+				return nil, &ServiceError{Message: "gross_budget can only be changed on a draft contract; use a variation order", Code: 409}
+			}
 			updates["gross_budget"] = v
 		}
 	}
@@ -836,7 +913,19 @@ func (s *ContractSvc) Update(ctx context.Context, id string, req UpdateContractR
 		updates["starts_on"] = parseDate(*req.StartsOn)
 	}
 	if req.EndsOn != nil {
-		updates["ends_on"] = parseDate(*req.EndsOn)
+		endsOn := parseDate(*req.EndsOn)
+		changed := (endsOn == nil) != (ct.EndsOn == nil) || (endsOn != nil && !endsOn.Equal(*ct.EndsOn))
+		if changed {
+			// Past draft the completion date only moves through extensions of time.
+			if ct.Status != model.ContractDraft {
+				return nil, &ServiceError{Message: "ends_on can only be changed on a draft contract; use an extension of time", Code: 409}
+			}
+			updates["ends_on"] = endsOn
+			updates["effective_ends_on"] = endsOn
+			if endsOn != nil && ct.ExtensionDays > 0 {
+				updates["effective_ends_on"] = endsOn.AddDate(0, 0, ct.ExtensionDays)
+			}
+		}
 	}
 	if req.BOQVersion != nil {
 		updates["boq_version"] = *req.BOQVersion
@@ -846,6 +935,27 @@ func (s *ContractSvc) Update(ctx context.Context, id string, req UpdateContractR
 			updates["contract_coefficient"] = v
 		}
 	}
+	if req.CumulativeStatements != nil {
+		updates["cumulative_statements"] = *req.CumulativeStatements
+	}
+	if req.QuantityTolerancePctBps != nil {
+		if *req.QuantityTolerancePctBps < 10000 {
+			return nil, &ServiceError{Message: errQuantityTolerance, Code: 400}
+		}
+		updates["quantity_tolerance_pct_bps"] = *req.QuantityTolerancePctBps
+	}
+	if req.EscalationIndexSet != nil {
+		updates["escalation_index_set"] = *req.EscalationIndexSet
+	}
+	if req.EscalationBaseYear != nil {
+		updates["escalation_base_year"] = *req.EscalationBaseYear
+	}
+	if req.EscalationBaseQuarter != nil {
+		updates["escalation_base_quarter"] = *req.EscalationBaseQuarter
+	}
+	if req.EscalationCoefficientBps != nil {
+		updates["escalation_coefficient_bps"] = *req.EscalationCoefficientBps
+	}
 	if req.ManagementFeePctBps != nil {
 		updates["management_fee_pct_bps"] = *req.ManagementFeePctBps
 	}
@@ -880,39 +990,6 @@ func (s *ContractSvc) Delete(ctx context.Context, id string) error {
 // CONTRACT APPROVAL WORKFLOW
 // ============================================================
 
-type contractTransitionRule struct {
-	next     model.ContractStatus
-	required model.Role // empty = any head role passes
-}
-
-var contractStateMachine = map[model.ContractStatus]map[string]contractTransitionRule{
-	model.ContractDraft: {
-		"submit": {next: model.ContractPendingEngineering, required: ""},
-	},
-	model.ContractPendingEngineering: {
-		"approve": {next: model.ContractPendingFinance, required: model.RoleEngineeringHead},
-		"reject":  {next: model.ContractDraft, required: model.RoleEngineeringHead},
-	},
-	model.ContractPendingFinance: {
-		"approve": {next: model.ContractPendingLegal, required: model.RoleFinanceHead},
-		"reject":  {next: model.ContractDraft, required: model.RoleFinanceHead},
-	},
-	model.ContractPendingLegal: {
-		"approve": {next: model.ContractPendingCEO, required: model.RoleJuridicalHead},
-		"reject":  {next: model.ContractDraft, required: model.RoleJuridicalHead},
-	},
-	model.ContractPendingCEO: {
-		"approve": {next: model.ContractReadyToPrint, required: model.RoleManager},
-		"reject":  {next: model.ContractDraft, required: model.RoleManager},
-	},
-	model.ContractReadyToPrint: {
-		"sign": {next: model.ContractSigned, required: model.RoleManager},
-	},
-	model.ContractSigned: {
-		"activate": {next: model.ContractActive, required: model.RoleManager},
-	},
-}
-
 func hasRole(roles []string, role model.Role) bool {
 	for _, r := range roles {
 		if r == string(role) {
@@ -922,15 +999,8 @@ func hasRole(roles []string, role model.Role) bool {
 	return false
 }
 
-func hasAnyHeadRole(roles []string) bool {
-	for _, r := range roles {
-		if model.IsHeadRole(model.Role(r)) {
-			return true
-		}
-	}
-	return false
-}
-
+// Transition applies action following the company's workflow definition (see
+// workflowFor). cancel and closeout are built in.
 func (s *ContractSvc) Transition(ctx context.Context, contractID, actorID string, actorRoles []string, action, comment string) (*model.Contract, error) {
 	cid, err := uuid.Parse(contractID)
 	if err != nil {
@@ -957,43 +1027,60 @@ func (s *ContractSvc) Transition(ctx context.Context, contractID, actorID string
 		if !hasRole(actorRoles, model.RoleManager) {
 			return nil, &ServiceError{Message: "Only manager can cancel a contract", Code: 403}
 		}
-		return s.applyTransition(ctx, &ct, aid, model.ContractCancelled, comment)
+		return s.applyTransition(ctx, &ct, aid, &approver{Position: string(model.RoleManager)}, "cancel", model.ContractCancelled, comment)
+	}
+	// Closing books the final account, so it has its own endpoint.
+	if action == "close" {
+		return nil, &ServiceError{Message: "Use POST /contracts/:id/closeout to close a contract", Code: 409}
 	}
 
-	actions, ok := contractStateMachine[ct.Status]
-	if !ok {
+	def, err := workflowFor(s.db.WithContext(ctx), ct.CompanyID, model.WorkflowContract, string(ct.Status), ct.WorkflowDefinitionID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasStepsFrom(def.Steps, string(ct.Status)) {
 		return nil, &ServiceError{Message: fmt.Sprintf("No transitions available from status %q", ct.Status), Code: 409}
 	}
-	rule, ok := actions[action]
-	if !ok {
+	step := findStep(def.Steps, string(ct.Status), func(st *model.WorkflowStep) bool { return st.Action == action })
+	if step == nil {
 		return nil, &ServiceError{Message: fmt.Sprintf("Action %q is not valid for status %q", action, ct.Status), Code: 409}
 	}
-
-	if rule.required == "" {
-		if !hasAnyHeadRole(actorRoles) {
-			return nil, &ServiceError{Message: "Requires a head role to perform this action", Code: 403}
-		}
-	} else {
-		if !hasRole(actorRoles, rule.required) {
-			return nil, &ServiceError{Message: fmt.Sprintf("Action %q requires role %q", action, rule.required), Code: 403}
-		}
+	scope := approvalScope{EntityType: model.WorkflowContract, EntityID: ct.ID, CompanyID: ct.CompanyID, ProjectID: &ct.ProjectID}
+	actor, err := resolveApprover(s.db.WithContext(ctx), step, scope, aid, actorRoles)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil {
+		return nil, &ServiceError{Message: fmt.Sprintf("Action %q requires one of: %s", action, stepRequirement(step)), Code: 403}
+	}
+	if step.CommentRequired && comment == "" {
+		return nil, &ServiceError{Message: "Comment is required for this action", Code: 400}
+	}
+	if ct.Status == model.ContractDraft {
+		ct.WorkflowDefinitionID = workflowPin(def)
 	}
 
-	return s.applyTransition(ctx, &ct, aid, rule.next, comment)
+	return s.applyTransition(ctx, &ct, aid, actor, step.Action, model.ContractStatus(step.To), comment)
 }
 
-func (s *ContractSvc) applyTransition(ctx context.Context, ct *model.Contract, actorID uuid.UUID, next model.ContractStatus, comment string) (*model.Contract, error) {
+func (s *ContractSvc) applyTransition(ctx context.Context, ct *model.Contract, actorID uuid.UUID, actor *approver, action string, next model.ContractStatus, comment string) (*model.Contract, error) {
 	prev := ct.Status
 	event := model.ApprovalEvent{
-		EntityType: "contract",
-		EntityID:   ct.ID,
-		ActorID:    actorID,
-		FromStatus: string(prev),
-		ToStatus:   string(next),
-		Comment:    comment,
+		EntityType:    "contract",
+		EntityID:      ct.ID,
+		ActorID:       actorID,
+		FromStatus:    string(prev),
+		ToStatus:      string(next),
+		Action:        action,
+		Comment:       comment,
+		ActorPosition: actor.Position,
+		OnBehalfOfID:  actor.OnBehalfOf,
 	}
 	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		cols := map[string]any{"status": next}
+		if prev == model.ContractDraft {
+			cols["workflow_definition_id"] = ct.WorkflowDefinitionID
+		}
 		if next == model.ContractSigned {
 			now := time.Now()
 			cols["signed_at"] = now
@@ -1002,7 +1089,7 @@ func (s *ContractSvc) applyTransition(ctx context.Context, ct *model.Contract, a
 		if err := tx.Model(ct).Updates(cols).Error; err != nil {
 			return err
 		}
-		return tx.Create(&event).Error
+		return recordApproval(tx, ct.CompanyID, &event)
 	}); err != nil {
 		return nil, &ServiceError{Message: "Transition failed", Code: 500}
 	}
@@ -1031,6 +1118,10 @@ func (s *ContractSvc) ListApprovals(ctx context.Context, contractID string) ([]m
 
 type CreateLineItemReq struct {
 	SortOrder    int    `json:"sort_order"`
+	ParentID     string `json:"parent_id"` // group line to nest under
+	IsGroup      bool   `json:"is_group"`  // chapter / sub-chapter heading
+	ItemCode     string `json:"item_code"` // generated under a coded parent when empty
+	Chapter      int    `json:"chapter"`
 	Description  string `json:"description"`
 	Unit         string `json:"unit"`
 	Quantity     string `json:"quantity"`
@@ -1040,6 +1131,9 @@ type CreateLineItemReq struct {
 
 type UpdateLineItemReq struct {
 	SortOrder    *int    `json:"sort_order"`
+	ParentID     *string `json:"parent_id"` // "" moves the line to the top level
+	ItemCode     *string `json:"item_code"`
+	Chapter      *int    `json:"chapter"`
 	Description  *string `json:"description"`
 	Unit         *string `json:"unit"`
 	Quantity     *string `json:"quantity"`
@@ -1064,7 +1158,7 @@ func (s *ContractSvc) CreateLineItem(ctx context.Context, contractID string, req
 	if err != nil {
 		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
 	}
-	if req.Unit == "" {
+	if req.Unit == "" && !req.IsGroup {
 		return nil, &ServiceError{Message: "unit is required", Code: 400}
 	}
 	qty := decimal.Zero
@@ -1081,14 +1175,30 @@ func (s *ContractSvc) CreateLineItem(ctx context.Context, contractID string, req
 			return nil, &ServiceError{Message: "Invalid unit_rate", Code: 400}
 		}
 	}
+	if req.IsGroup && (!qty.IsZero() || !rate.IsZero()) {
+		return nil, &ServiceError{Message: "Group lines carry no quantity or unit_rate", Code: 400}
+	}
 	currency := req.CurrencyCode
 	if len(currency) != 3 {
 		currency = "IRR"
 	}
 
+	parent, err := lineItemParent(s.db.WithContext(ctx), cid, req.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	code, err := nestedItemCode(s.db.WithContext(ctx), parent, strings.TrimSpace(req.ItemCode))
+	if err != nil {
+		return nil, err
+	}
+	chapter := req.Chapter
+	if chapter == 0 && parent != nil {
+		chapter = parent.Chapter
+	}
+
 	// Load contract for denormalization and budget enforcement.
 	var ct model.Contract
-	if err := s.db.WithContext(ctx).Select("contractor_id, project_id, gross_budget").First(&ct, "id = ?", cid).Error; err != nil {
+	if err := s.db.WithContext(ctx).Select("contractor_id, project_id, type, gross_budget, contract_coefficient").First(&ct, "id = ?", cid).Error; err != nil {
 		return nil, &ServiceError{Message: "Contract not found", Code: 404}
 	}
 
@@ -1098,11 +1208,11 @@ func (s *ContractSvc) CreateLineItem(ctx context.Context, contractID string, req
 		s.db.WithContext(ctx).Raw(
 			"SELECT COALESCE(SUM(quantity * unit_rate), 0) FROM contract_line_items WHERE contract_id = ?", cid,
 		).Scan(&existingSum)
-		if existingSum.Add(qty.Mul(rate)).GreaterThan(ct.GrossBudget) {
+		if projected := contractRate(&ct, existingSum.Add(qty.Mul(rate))); projected.GreaterThan(ct.GrossBudget) {
 			return nil, &ServiceError{
 				Message: fmt.Sprintf(
 					"جمع آیتم‌های WBS (%.0f) از مبلغ قرارداد (%.0f) بیشتر می‌شود",
-					existingSum.Add(qty.Mul(rate)).InexactFloat64(), ct.GrossBudget.InexactFloat64(),
+					projected.InexactFloat64(), ct.GrossBudget.InexactFloat64(),
 				),
 				Code: 422,
 			}
@@ -1113,7 +1223,11 @@ func (s *ContractSvc) CreateLineItem(ctx context.Context, contractID string, req
 		ContractID:   cid,
 		ContractorID: &ct.ContractorID,
 		ProjectID:    &ct.ProjectID,
+		ParentID:     lineItemID(parent),
+		IsGroup:      req.IsGroup,
 		SortOrder:    req.SortOrder,
+		ItemCode:     code,
+		Chapter:      chapter,
 		Description:  req.Description,
 		Unit:         req.Unit,
 		Quantity:     qty,
@@ -1141,8 +1255,16 @@ func (s *ContractSvc) UpdateLineItem(ctx context.Context, itemID string, req Upd
 
 	// Track final qty/rate for budget check (default to existing values).
 	newQty, newRate := item.Quantity, item.UnitRate
+	if item.IsGroup && (req.Quantity != nil || req.UnitRate != nil) {
+		return nil, &ServiceError{Message: "Group lines carry no quantity or unit_rate", Code: 400}
+	}
 
 	updates := make(map[string]any)
+	if req.ParentID != nil || req.ItemCode != nil {
+		if err := s.renestLineItem(ctx, &item, req.ParentID, req.ItemCode, updates); err != nil {
+			return nil, err
+		}
+	}
 	if req.Description != nil {
 		updates["description"] = *req.Description
 	}
@@ -1152,6 +1274,9 @@ func (s *ContractSvc) UpdateLineItem(ctx context.Context, itemID string, req Upd
 	if req.SortOrder != nil {
 		updates["sort_order"] = *req.SortOrder
 	}
+	if req.Chapter != nil {
+		updates["chapter"] = *req.Chapter
+	}
 	if req.CurrencyCode != nil {
 		c := *req.CurrencyCode
 		if len(c) == 3 {
@@ -1178,14 +1303,14 @@ func (s *ContractSvc) UpdateLineItem(ctx context.Context, itemID string, req Upd
 	// Budget guard: only fires when qty or rate changes.
 	if req.Quantity != nil || req.UnitRate != nil {
 		var ct model.Contract
-		if err := s.db.WithContext(ctx).Select("gross_budget").First(&ct, "id = ?", item.ContractID).Error; err == nil && ct.GrossBudget.IsPositive() {
+		if err := s.db.WithContext(ctx).Select("type, gross_budget, contract_coefficient").First(&ct, "id = ?", item.ContractID).Error; err == nil && ct.GrossBudget.IsPositive() {
 			var totalSum decimal.Decimal
 			s.db.WithContext(ctx).Raw(
 				"SELECT COALESCE(SUM(quantity * unit_rate), 0) FROM contract_line_items WHERE contract_id = ?",
 				item.ContractID,
 			).Scan(&totalSum)
 			// Subtract old item contribution, add new.
-			projected := totalSum.Sub(item.Quantity.Mul(item.UnitRate)).Add(newQty.Mul(newRate))
+			projected := contractRate(&ct, totalSum.Sub(item.Quantity.Mul(item.UnitRate)).Add(newQty.Mul(newRate)))
 			if projected.GreaterThan(ct.GrossBudget) {
 				return nil, &ServiceError{
 					Message: fmt.Sprintf(
@@ -1200,7 +1325,7 @@ func (s *ContractSvc) UpdateLineItem(ctx context.Context, itemID string, req Upd
 
 	if len(updates) > 0 {
 		if err := s.db.WithContext(ctx).Model(&item).Updates(updates).Error; err != nil {
-			return nil, &ServiceError{Message: "Update failed", Code: 500}
+			return nil, dbErr(err)
 		}
 	}
 	return &item, nil
@@ -1211,6 +1336,13 @@ func (s *ContractSvc) DeleteLineItem(ctx context.Context, itemID string) error {
 	if err != nil {
 		return &ServiceError{Message: "Invalid line item ID", Code: 400}
 	}
+	var children int64
+	if err := s.db.WithContext(ctx).Model(&model.ContractLineItem{}).Where("parent_id = ?", uid).Count(&children).Error; err != nil {
+		return &ServiceError{Message: "Database error", Code: 500}
+	}
+	if children > 0 {
+		return &ServiceError{Message: "Delete or move the line's children first", Code: 409}
+	}
 	result := s.db.WithContext(ctx).Where("id = ?", uid).Delete(&model.ContractLineItem{})
 	if result.Error != nil {
 		return &ServiceError{Message: "Delete failed", Code: 500}
@@ -1220,3 +1352,227 @@ func (s *ContractSvc) DeleteLineItem(ctx context.Context, itemID string) error {
 	}
 	return nil
 }
+
+// renestLineItem validates a change of parent and/or item code and adds it to
+// updates. The new parent must be a group line of the same contract that is
+// not the line itself or one of its descendants, and the resulting code must
+// extend the parent's. A group's code is fixed once it has children.
+func (s *ContractSvc) renestLineItem(ctx context.Context, item *model.ContractLineItem, parentID, itemCode *string, updates map[string]any) error {
+	db := s.db.WithContext(ctx)
+	var parent *model.ContractLineItem
+	var err error
+	if parentID != nil {
+		if parent, err = lineItemParent(db, item.ContractID, *parentID); err != nil {
+			return err
+		}
+		// Walk up from the new parent; meeting the item means a cycle.
+		for p := parent; p != nil; {
+			if p.ID == item.ID {
+				return &ServiceError{Message: "A line cannot be nested under itself or its descendants", Code: 422}
+			}
+			if p.ParentID == nil {
+				break
+			}
+			var up model.ContractLineItem
+			if err := db.Select("id, parent_id").First(&up, "id = ?", *p.ParentID).Error; err != nil {
+				break
+			}
+			p = &up
+		}
+		updates["parent_id"] = lineItemID(parent)
+	} else if item.ParentID != nil {
+		var p model.ContractLineItem
+		if err := db.First(&p, "id = ?", *item.ParentID).Error; err == nil {
+			parent = &p
+		}
+	}
+
+	code := item.ItemCode
+	if itemCode != nil {
+		code = strings.TrimSpace(*itemCode)
+		if code != item.ItemCode && item.IsGroup {
+			var children int64
+			if err := db.Model(&model.ContractLineItem{}).Where("parent_id = ?", item.ID).Count(&children).Error; err != nil {
+				return &ServiceError{Message: "Database error", Code: 500}
+			}
+			if children > 0 {
+				return &ServiceError{Message: "The code of a group line with children cannot change", Code: 409}
+			}
+		}
+	}
+	if code != "" || itemCode != nil {
+		if code, err = nestedItemCode(db, parent, code); err != nil {
+			return err
+		}
+		updates["item_code"] = code
+	}
+	return nil
+}
+
+// lineItemParent loads the group line raw names, or nil when raw is empty.
+func lineItemParent(db *gorm.DB, contractID uuid.UUID, raw string) (*model.ContractLineItem, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	pid, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid parent_id", Code: 400}
+	}
+	var parent model.ContractLineItem
+	if err := db.First(&parent, "id = ? AND contract_id = ?", pid, contractID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Parent line not found on this contract", Code: 422}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if !parent.IsGroup {
+		return nil, &ServiceError{Message: "Lines can only be nested under a group line", Code: 422}
+	}
+	return &parent, nil
+}
+
+// nestedItemCode checks that code extends the parent's code. Under a coded
+// parent an empty code becomes "<parent>.<n>" with the first unused n, so
+// codes stay stable when siblings are reordered or removed.
+func nestedItemCode(db *gorm.DB, parent *model.ContractLineItem, code string) (string, error) {
+	if parent == nil || parent.ItemCode == "" {
+		return code, nil
+	}
+	if code != "" {
+		if len(code) <= len(parent.ItemCode) || !strings.HasPrefix(code, parent.ItemCode) {
+			return "", &ServiceError{Message: fmt.Sprintf("item_code must extend the parent's code %s", parent.ItemCode), Code: 422}
+		}
+		return code, nil
+	}
+	var n int64
+	if err := db.Unscoped().Model(&model.ContractLineItem{}).Where("parent_id = ?", parent.ID).Count(&n).Error; err != nil {
+		return "", &ServiceError{Message: "Database error", Code: 500}
+	}
+	for {
+		n++
+		code = fmt.Sprintf("%s.%d", parent.ItemCode, n)
+		var taken int64
+		if err := db.Model(&model.ContractLineItem{}).
+			Where("contract_id = ? AND item_code = ?", parent.ContractID, code).Count(&taken).Error; err != nil {
+			return "", &ServiceError{Message: "Database error", Code: 500}
+		}
+		if taken == 0 {
+			return code, nil
+		}
+	}
+}
+
+// contractRate is what a schedule price (or a sum of line totals) is paid at
+// on the contract: unit_rate contracts apply the contract coefficient (ضریب
+// پیمان). Line items keep the unadjusted price.
+func contractRate(ct *model.Contract, rate decimal.Decimal) decimal.Decimal {
+	if ct.Type == model.ContractUnitRate {
+		return rate.Mul(ct.ContractCoefficient)
+	}
+	return rate
+}
+
+func lineItemID(li *model.ContractLineItem) *uuid.UUID {
+	if li == nil {
+		return nil
+	}
+	return &li.ID
+}
+
+// LineItemNode is a line with its children. Amount is quantity × unit_rate
+// for an item and the subtotal of its descendants for a group line.
+type LineItemNode struct {
+	model.ContractLineItem
+	Amount   decimal.Decimal `json:"amount"`
+	Children []*LineItemNode `json:"children,omitempty"`
+}
+
+// ChapterSubtotal sums the items of one فصل; Title is the top-level group
+// line of that chapter, if any.
+type ChapterSubtotal struct {
+	Chapter int             `json:"chapter"`
+	Title   string          `json:"title,omitempty"`
+	Items   int             `json:"items"`
+	Amount  decimal.Decimal `json:"amount"`
+}
+
+type LineItemTree struct {
+	Total    decimal.Decimal   `json:"total"`
+	Chapters []ChapterSubtotal `json:"chapters"`
+	Items    []*LineItemNode   `json:"items"`
+}
+
+// LineItemTree returns the contract's lines nested under their groups with
+// group and chapter subtotals.
+func (s *ContractSvc) LineItemTree(ctx context.Context, contractID string) (*LineItemTree, error) {
+	items, err := s.ListLineItems(ctx, contractID)
+	if err != nil {
+		return nil, err
+	}
+	return buildLineItemTree(items), nil
+}
+
+// buildLineItemTree nests items (in display order) and computes subtotals.
+// A line whose parent is missing is shown at the top level.
+func buildLineItemTree(items []model.ContractLineItem) *LineItemTree {
+	nodes := make(map[uuid.UUID]*LineItemNode, len(items))
+	for i := range items {
+		nodes[items[i].ID] = &LineItemNode{ContractLineItem: items[i]}
+	}
+	tree := &LineItemTree{Total: decimal.Zero, Chapters: []ChapterSubtotal{}, Items: []*LineItemNode{}}
+	for i := range items {
+		n := nodes[items[i].ID]
+		if n.ParentID != nil {
+			if p, ok := nodes[*n.ParentID]; ok {
+				p.Children = append(p.Children, n)
+				continue
+			}
+		}
+		tree.Items = append(tree.Items, n)
+	}
+
+	chapters := make(map[int]*ChapterSubtotal)
+	var order []int
+	var sum func(n *LineItemNode) decimal.Decimal
+	sum = func(n *LineItemNode) decimal.Decimal {
+		if !n.IsGroup {
+			n.Amount = n.Quantity.Mul(n.UnitRate)
+			ch, ok := chapters[n.Chapter]
+			if !ok {
+				ch = &ChapterSubtotal{Chapter: n.Chapter, Amount: decimal.Zero}
+				chapters[n.Chapter] = ch
+				order = append(order, n.Chapter)
+			}
+			ch.Items++
+			ch.Amount = ch.Amount.Add(n.Amount)
+			return n.Amount
+		}
+		n.Amount = decimal.Zero
+		for _, c := range n.Children {
+			n.Amount = n.Amount.Add(sum(c))
+		}
+		return n.Amount
+	}
+	for _, n := range tree.Items {
+		tree.Total = tree.Total.Add(sum(n))
+	}
+
+	titles := make(map[int]string)
+	for _, n := range tree.Items {
+		if _, ok := titles[n.Chapter]; n.IsGroup && !ok {
+			titles[n.Chapter] = n.Description
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { // chapter 0 (none) last
+		if (order[i] == 0) != (order[j] == 0) {
+			return order[j] == 0
+		}
+		return order[i] < order[j]
+	})
+	for _, c := range order {
+		ch := chapters[c]
+		ch.Title = titles[c]
+		tree.Chapters = append(tree.Chapters, *ch)
+	}
+	return tree
+}
@@ -1 +1,75 @@
 package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+)
+
+func TestBuildLineItemTree(t *testing.T) {
+	d := decimal.RequireFromString
+	line := func(parent *uuid.UUID, chapter int, qty, rate string) model.ContractLineItem {
+		li := model.ContractLineItem{ParentID: parent, Chapter: chapter, Description: "item", Quantity: d(qty), UnitRate: d(rate)}
+		li.ID = uuid.New()
+		return li
+	}
+	group := func(parent *uuid.UUID, chapter int, title string) model.ContractLineItem {
+		li := model.ContractLineItem{ParentID: parent, Chapter: chapter, Description: title, IsGroup: true}
+		li.ID = uuid.New()
+		return li
+	}
+
+	earth := group(nil, 2, "عملیات خاکی")
+	dig := group(&earth.ID, 2, "گودبرداری")
+	concrete := group(nil, 8, "بتن")
+	missing := uuid.New()
+	items := []model.ContractLineItem{
+		earth,
+		dig,
+		line(&dig.ID, 2, "10", "1.5"),  // 15
+		line(&earth.ID, 2, "4", "2.5"), // 10
+		concrete,
+		line(&concrete.ID, 8, "3", "100"), // 300
+		line(nil, 0, "1", "7"),            // 7, no chapter
+		line(&missing, 8, "2", "5"),       // 10, orphan shown at top level
+	}
+
+	tree := buildLineItemTree(items)
+
+	if !tree.Total.Equal(d("342")) {
+		t.Errorf("total = %s, want 342", tree.Total)
+	}
+	if len(tree.Items) != 4 {
+		t.Fatalf("top-level items = %d, want 4 (two groups, a loose line, an orphan)", len(tree.Items))
+	}
+	if got := tree.Items[0]; !got.Amount.Equal(d("25")) || len(got.Children) != 2 {
+		t.Errorf("earthworks = %s with %d children, want 25 with 2", got.Amount, len(got.Children))
+	}
+	if got := tree.Items[0].Children[0]; !got.Amount.Equal(d("15")) {
+		t.Errorf("excavation = %s, want 15", got.Amount)
+	}
+
+	want := []ChapterSubtotal{
+		{Chapter: 2, Title: "عملیات خاکی", Items: 2, Amount: d("25")},
+		{Chapter: 8, Title: "بتن", Items: 2, Amount: d("310")},
+		{Chapter: 0, Items: 1, Amount: d("7")},
+	}
+	if len(tree.Chapters) != len(want) {
+		t.Fatalf("chapters = %+v, want %+v", tree.Chapters, want)
+	}
+	for i, w := range want {
+		got := tree.Chapters[i]
+		if got.Chapter != w.Chapter || got.Title != w.Title || got.Items != w.Items || !got.Amount.Equal(w.Amount) {
+			t.Errorf("chapter %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestBuildLineItemTreeEmpty(t *testing.T) {
+	tree := buildLineItemTree(nil)
+	if !tree.Total.IsZero() || tree.Items == nil || tree.Chapters == nil {
+		t.Fatalf("empty tree = %+v, want zero total and empty (non-nil) slices", tree)
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+)
+
+// DelegationService manages time-bounded approval delegations. Transition
+// honours them through resolveApprover.
+type DelegationService struct{ db *gorm.DB }
+
+func NewDelegationService(db *gorm.DB) *DelegationService { return &DelegationService{db: db} }
+
+type CreateDelegationReq struct {
+	DelegatorID string `json:"delegator_id"` // defaults to the caller
+	DelegateID  string `json:"delegate_id"`
+	EntityType  string `json:"entity_type"` // "" or a workflow entity type
+	ProjectID   string `json:"project_id"`  // optional
+	StartsOn    string `json:"starts_on"`   // "2006-01-02"
+	EndsOn      string `json:"ends_on"`     // "2006-01-02"
+	Reason      string `json:"reason"`
+}
+
+// canManageDelegations reports whether roles may create or revoke delegations
+// of someone else's authority.
+func canManageDelegations(roles []string) bool {
+	for _, r := range roles {
+		if r == "sudoer" || r == string(model.RoleAdmin) || r == string(model.RoleManager) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorityRank orders the roles that carry administrative authority, so a
+// manager cannot hand out an admin's authority.
+func authorityRank(roles []string) int {
+	rank := 0
+	for _, r := range roles {
+		switch r {
+		case "sudoer":
+			rank = max(rank, 3)
+		case string(model.RoleAdmin):
+			rank = max(rank, 2)
+		case string(model.RoleManager):
+			rank = max(rank, 1)
+		}
+	}
+	return rank
+}
+
+// delegableRoles keeps the head roles of a principal, each of which stands
+// for an approver position (see headRolePositions). Admin and sudoer are
+// never delegated.
+func delegableRoles(roles []string) []string {
+	var out []string
+	for _, r := range roles {
+		if _, ok := headRolePositions[r]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// List returns the delegations of a company, optionally narrowed to one
+// delegator or delegate; activeOnly keeps the unrevoked ones covering today.
+func (s *DelegationService) List(ctx context.Context, companyID, delegatorID, delegateID string, activeOnly bool) ([]model.ApprovalDelegation, error) {
+	q := s.db.WithContext(ctx).Model(&model.ApprovalDelegation{})
+	for col, v := range map[string]string{"company_id": companyID, "delegator_id": delegatorID, "delegate_id": delegateID} {
+		if v == "" {
+			continue
+		}
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid " + col, Code: 400}
+		}
+		q = q.Where(col+" = ?", id)
+	}
+	if activeOnly {
+		q = q.Where("revoked_at IS NULL AND starts_on <= CURRENT_DATE AND ends_on >= CURRENT_DATE")
+	}
+	var out []model.ApprovalDelegation
+	if err := q.Order("starts_on DESC, created_at DESC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+// Create records a delegation. Callers may delegate their own authority;
+// delegating someone else's requires manager or admin.
+func (s *DelegationService) Create(ctx context.Context, req CreateDelegationReq, callerCompanyID string, actorID uuid.UUID, callerRoles []string) (*model.ApprovalDelegation, error) {
+	cid, err := uuid.Parse(callerCompanyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	delegatorID := actorID
+	if req.DelegatorID != "" {
+		if delegatorID, err = uuid.Parse(req.DelegatorID); err != nil {
+			return nil, &ServiceError{Message: "Invalid delegator_id", Code: 400}
+		}
+	}
+	if delegatorID != actorID && !canManageDelegations(callerRoles) {
+		return nil, &ServiceError{Message: "Only manager or admin can delegate another employee's authority", Code: 403}
+	}
+	delegateID, err := uuid.Parse(req.DelegateID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid delegate_id", Code: 400}
+	}
+	if delegateID == delegatorID {
+		return nil, &ServiceError{Message: "An employee cannot delegate to themselves", Code: 422}
+	}
+	entityType := strings.TrimSpace(req.EntityType)
+	if _, ok := workflowGoal[entityType]; entityType != "" && !ok {
+		return nil, &ServiceError{Message: errWorkflowEntityType, Code: 400}
+	}
+	startsOn, endsOn := parseDate(req.StartsOn), parseDate(req.EndsOn)
+	if startsOn == nil || endsOn == nil {
+		return nil, &ServiceError{Message: "starts_on and ends_on are required (YYYY-MM-DD)", Code: 400}
+	}
+	if endsOn.Before(*startsOn) {
+		return nil, &ServiceError{Message: "ends_on must not be before starts_on", Code: 422}
+	}
+
+	db := s.db.WithContext(ctx)
+	var n int64
+	if err := db.Model(&model.Employee{}).
+		Where("id IN ? AND company_id = ? AND active", []uuid.UUID{delegatorID, delegateID}, cid).
+		Count(&n).Error; err != nil {
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if n != 2 {
+		return nil, &ServiceError{Message: "Delegator and delegate must be active employees of the company", Code: 422}
+	}
+	if delegatorID != actorID {
+		var delegator model.Employee
+		if err := db.Select("id, roles").First(&delegator, "id = ?", delegatorID).Error; err != nil {
+			return nil, &ServiceError{Message: "Database error", Code: 500}
+		}
+		if authorityRank(delegator.Roles) > authorityRank(callerRoles) {
+			return nil, &ServiceError{Message: "Cannot delegate the authority of an employee who outranks you", Code: 403}
+		}
+	}
+
+	d := model.ApprovalDelegation{
+		CompanyID:   cid,
+		DelegatorID: delegatorID,
+		DelegateID:  delegateID,
+		EntityType:  entityType,
+		StartsOn:    *startsOn,
+		EndsOn:      *endsOn,
+		Reason:      req.Reason,
+		CreatedByID: actorID,
+	}
+	if req.ProjectID != "" {
+		pid, err := uuid.Parse(req.ProjectID)
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid project_id", Code: 400}
+		}
+		var project model.Project
+		if err := db.Select("id").First(&project, "id = ? AND company_id = ?", pid, cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, &ServiceError{Message: "Project not found", Code: 404}
+			}
+			return nil, &ServiceError{Message: "Database error", Code: 500}
+		}
+		d.ProjectID = &pid
+	}
+	if err := db.Create(&d).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return &d, nil
+}
+
+// Revoke ends a delegation immediately. The delegator can revoke their own;
+// manager or admin can revoke any.
+func (s *DelegationService) Revoke(ctx context.Context, id, callerCompanyID string, actorID uuid.UUID, callerRoles []string) (*model.ApprovalDelegation, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid delegation ID", Code: 400}
+	}
+	cid, err := uuid.Parse(callerCompanyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	var d model.ApprovalDelegation
+	if err := db.First(&d, "id = ? AND company_id = ?", uid, cid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Delegation not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if d.DelegatorID != actorID && !canManageDelegations(callerRoles) {
+		return nil, &ServiceError{Message: "Only the delegator, manager or admin can revoke a delegation", Code: 403}
+	}
+	if d.RevokedAt != nil {
+		return nil, &ServiceError{Message: "Delegation is already revoked", Code: 409}
+	}
+	now := time.Now()
+	if err := db.Model(&d).Update("revoked_at", now).Error; err != nil {
+		return nil, &ServiceError{Message: "Update failed", Code: 500}
+	}
+	d.RevokedAt = &now
+	return &d, nil
+}
+
+// approvalScope is the document a workflow step is taken on.
+type approvalScope struct {
+	EntityType string
+	EntityID   uuid.UUID
+	CompanyID  uuid.UUID
+	ProjectID  *uuid.UUID
+}
+
+// approver is the identity a workflow step is taken under.
+type approver struct {
+	Position   string
+	OnBehalfOf *uuid.UUID // principal, when acting as a delegate
+}
+
+// resolveApprover decides under which identity actorID may take step: their
+// own positions and token roles first, then the positions and head roles of
+// each principal who has delegated to them for this scope. Within one approval round (since the
+// document last went back to draft) a person keeps a single identity, so
+// nobody approves the same document twice as two different people. A nil
+// approver means the actor may not take the step.
+func resolveApprover(tx *gorm.DB, step *model.WorkflowStep, scope approvalScope, actorID uuid.UUID, roles []string) (*approver, error) {
+	var events []model.ApprovalEvent
+	if err := tx.Select("actor_id, on_behalf_of_id, to_status").
+		Where("entity_type = ? AND entity_id = ?", scope.EntityType, scope.EntityID).
+		Order("created_at ASC, id ASC").
+		Find(&events).Error; err != nil {
+		return nil, &ServiceError{Message: "Failed to load approval history", Code: 500}
+	}
+	var usedAs *uuid.UUID
+	for _, e := range events {
+		if e.ToStatus == "draft" {
+			usedAs = nil
+			continue
+		}
+		if e.ActorID == actorID {
+			id := actorID
+			if e.OnBehalfOfID != nil {
+				id = *e.OnBehalfOfID
+			}
+			usedAs = &id
+		}
+	}
+
+	type identity struct {
+		principal uuid.UUID
+		roles     []string
+	}
+	candidates := []identity{{principal: actorID, roles: roles}}
+
+	q := tx.Where("company_id = ? AND delegate_id = ? AND revoked_at IS NULL", scope.CompanyID, actorID).
+		Where("starts_on <= CURRENT_DATE AND ends_on >= CURRENT_DATE").
+		Where("entity_type = '' OR entity_type = ?", scope.EntityType).
+		Where("entity_id IS NULL OR entity_id = ?", scope.EntityID)
+	if scope.ProjectID != nil {
+		q = q.Where("project_id IS NULL OR project_id = ?", *scope.ProjectID)
+	} else {
+		q = q.Where("project_id IS NULL")
+	}
+	var delegations []model.ApprovalDelegation
+	if err := q.Order("starts_on ASC").Find(&delegations).Error; err != nil {
+		return nil, &ServiceError{Message: "Failed to load delegations", Code: 500}
+	}
+	for _, d := range delegations {
+		var principal model.Employee
+		if err := tx.Select("id, roles").First(&principal, "id = ? AND active", d.DelegatorID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, &ServiceError{Message: "Database error", Code: 500}
+		}
+		candidates = append(candidates, identity{principal: principal.ID, roles: delegableRoles(principal.Roles)})
+	}
+
+	blocked := false
+	for _, c := range candidates {
+		positions, err := approverPositions(tx, scope.CompanyID, scope.ProjectID, c.principal)
+		if err != nil {
+			return nil, err
+		}
+		pos := authorizeStep(step, positions, c.roles)
+		if pos == "" {
+			continue
+		}
+		if usedAs != nil && *usedAs != c.principal {
+			blocked = true
+			continue
+		}
+		a := &approver{Position: pos}
+		if c.principal != actorID {
+			p := c.principal
+			a.OnBehalfOf = &p
+		}
+		return a, nil
+	}
+	if blocked {
+		return nil, &ServiceError{Message: "You have already acted on this document under another identity in this approval round", Code: 403}
+	}
+	return nil, nil
+}
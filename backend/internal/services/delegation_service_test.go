@@ -0,0 +1,58 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+)
+
+func TestDelegableRolesDropAdmin(t *testing.T) {
+	got := delegableRoles([]string{"sudoer", "admin", "finance_head", "engineering", "manager"})
+	want := []string{"finance_head", "manager"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("delegableRoles = %v, want %v", got, want)
+	}
+}
+
+// A delegate of an admin must not pass the admin-only steps of the built-in
+// chains, which resolveApprover checks with the principal's delegated roles.
+func TestDelegatedAdminCannotTakeAdminSteps(t *testing.T) {
+	roles := delegableRoles([]string{string(model.RoleAdmin), "sudoer"})
+	for entityType, steps := range defaultWorkflows {
+		for i := range steps {
+			step := &steps[i]
+			if !slices.Contains(step.Roles, string(model.RoleAdmin)) {
+				continue
+			}
+			if got := authorizeStep(step, nil, roles); got != "" {
+				t.Errorf("%s %s→%s: delegated admin authorised as %q", entityType, step.From, step.To, got)
+			}
+		}
+	}
+}
+
+func TestDelegatedHeadRoleStillApproves(t *testing.T) {
+	step := &model.WorkflowStep{From: "pending_finance", Action: "approve", To: "pending_legal", Roles: []string{"finance_head"}}
+	if got := authorizeStep(step, nil, delegableRoles([]string{"finance_head"})); got != "finance_head" {
+		t.Fatalf("authorizeStep = %q, want finance_head", got)
+	}
+}
+
+func TestAuthorityRank(t *testing.T) {
+	cases := []struct {
+		delegator, caller []string
+		refused           bool
+	}{
+		{[]string{"admin"}, []string{"manager"}, true},
+		{[]string{"sudoer"}, []string{"admin"}, true},
+		{[]string{"admin"}, []string{"admin"}, false},
+		{[]string{"finance_head"}, []string{"manager"}, false},
+		{[]string{"manager", "finance_head"}, []string{"manager"}, false},
+	}
+	for _, c := range cases {
+		if got := authorityRank(c.delegator) > authorityRank(c.caller); got != c.refused {
+			t.Errorf("delegator %v, caller %v: refused = %v, want %v", c.delegator, c.caller, got, c.refused)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailBatchSize   = 20
+	emailMaxAttempts = 6
+	emailBaseBackoff = time.Minute
+	// A claimed email is retried after this if the worker dies; it covers a
+	// whole batch of SMTP sends at their 30s timeout.
+	emailLease = 15 * time.Minute
+)
+
+// EmailService manages email preferences and the outgoing mail queue. Emails
+// are queued by queueEmails and sent by DeliverDue.
+type EmailService struct {
+	db     *gorm.DB
+	from   string
+	mailer Mailer
+}
+
+// NewEmailService returns a service that sends through cfg. A zero cfg is
+// fine for everything but DeliverDue.
+func NewEmailService(db *gorm.DB, cfg SMTPConfig) *EmailService {
+	return &EmailService{db: db, from: cfg.From, mailer: NewSMTPMailer(cfg)}
+}
+
+type UpdateNotificationPreferenceReq struct {
+	EmailEnabled *bool    `json:"email_enabled"`
+	Language     *string  `json:"language"` // fa | en
+	Kinds        []string `json:"kinds"`    // approval_needed, rejected; empty = all
+}
+
+func defaultPreference(employeeID uuid.UUID) model.NotificationPreference {
+	return model.NotificationPreference{EmployeeID: employeeID, Language: "fa", Kinds: pq.StringArray{}}
+}
+
+// GetPreferences returns the employee's preferences, or the defaults (email
+// off, Persian) if they never saved any.
+func (s *EmailService) GetPreferences(ctx context.Context, employeeID uuid.UUID) (*model.NotificationPreference, error) {
+	var p model.NotificationPreference
+	err := s.db.WithContext(ctx).First(&p, "employee_id = ?", employeeID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		p = defaultPreference(employeeID)
+		return &p, nil
+	}
+	if err != nil {
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &p, nil
+}
+
+func (s *EmailService) UpdatePreferences(ctx context.Context, employeeID uuid.UUID, req UpdateNotificationPreferenceReq) (*model.NotificationPreference, error) {
+	var out model.NotificationPreference
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&out, "employee_id = ?", employeeID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			out = defaultPreference(employeeID)
+		} else if err != nil {
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+
+		if req.EmailEnabled != nil {
+			out.EmailEnabled = *req.EmailEnabled
+		}
+		if req.Language != nil {
+			if *req.Language != "fa" && *req.Language != "en" {
+				return &ServiceError{Message: "language must be fa or en", Code: 400}
+			}
+			out.Language = *req.Language
+		}
+		if req.Kinds != nil {
+			kinds := pq.StringArray{}
+			for _, k := range req.Kinds {
+				if _, ok := emailTemplates[k]; !ok {
+					return &ServiceError{Message: "Invalid email kind: " + k, Code: 400}
+				}
+				kinds = append(kinds, k)
+			}
+			out.Kinds = kinds
+		}
+		if out.EmailEnabled {
+			var email string
+			if err := tx.Model(&model.Employee{}).Where("id = ?", employeeID).Pluck("email", &email).Error; err != nil {
+				return &ServiceError{Message: "Database error", Code: 500}
+			}
+			if email == "" {
+				return &ServiceError{Message: "Set an email address before enabling email notifications", Code: 400}
+			}
+		}
+		return dbErr(tx.Save(&out).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListMessages lists the outgoing emails of a company, newest first.
+func (s *EmailService) ListMessages(ctx context.Context, companyID, status string, page, limit int) ([]model.EmailMessage, int64, error) {
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return nil, 0, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	q := s.db.WithContext(ctx).Model(&model.EmailMessage{}).Where("company_id = ?", cid)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	var out []model.EmailMessage
+	if err := q.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&out).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, total, nil
+}
+
+func (s *EmailService) findMessage(db *gorm.DB, companyID, id string) (*model.EmailMessage, error) {
+	mid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid email ID", Code: 400}
+	}
+	var m model.EmailMessage
+	if err := db.First(&m, "id = ? AND company_id = ?", mid, companyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Email not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &m, nil
+}
+
+// ListDeliveries returns the delivery attempts of one email.
+func (s *EmailService) ListDeliveries(ctx context.Context, companyID, id string) ([]model.EmailDeliveryLog, error) {
+	db := s.db.WithContext(ctx)
+	m, err := s.findMessage(db, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.EmailDeliveryLog
+	if err := db.Where("message_id = ?", m.ID).Order("attempt ASC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+// Retry re-queues a failed email for immediate delivery with a fresh set of
+// attempts.
+func (s *EmailService) Retry(ctx context.Context, companyID, id string) (*model.EmailMessage, error) {
+	db := s.db.WithContext(ctx)
+	m, err := s.findMessage(db, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if m.Status == model.EmailSent {
+		return nil, &ServiceError{Message: "Email was already sent", Code: 409}
+	}
+	now := time.Now()
+	if err := db.Model(m).Updates(map[string]any{
+		"status":          model.EmailPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+	}).Error; err != nil {
+		return nil, &ServiceError{Message: "Update failed", Code: 500}
+	}
+	m.Status, m.Attempts, m.NextAttemptAt = model.EmailPending, 0, now
+	return m, nil
+}
+
+// Run delivers due emails every interval until ctx is cancelled.
+func (s *EmailService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := s.DeliverDue(ctx)
+			if err != nil {
+				log.Printf("[email] delivery failed: %v", err)
+			} else if n > 0 {
+				log.Printf("[email] processed %d email(s)", n)
+			}
+			if err != nil || n < emailBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends one batch of pending emails whose next attempt is due and
+// returns how many it tried. Emails are claimed with SKIP LOCKED and a lease
+// on NextAttemptAt, so the SMTP sends run outside the transaction and several
+// API instances can run the worker. A failed attempt is retried after
+// 1m, 2m, 4m, ... until emailMaxAttempts, then the email is marked failed.
+func (s *EmailService) DeliverDue(ctx context.Context) (int, error) {
+	db := s.db.WithContext(ctx)
+	var batch []model.EmailMessage
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		if err := tx.Model(&model.EmailMessage{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.EmailPending, time.Now()).
+			Order("next_attempt_at ASC").Limit(emailBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&model.EmailMessage{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(emailLease)).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Order("next_attempt_at ASC").Find(&batch).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i := range batch {
+		if err := s.deliver(db, &batch[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+// deliver sends a claimed email and records the attempt.
+func (s *EmailService) deliver(db *gorm.DB, m *model.EmailMessage) error {
+	msg, err := buildEmail(s.from, m.ToAddress, m.Subject, m.TextBody, m.HTMLBody)
+	if err == nil {
+		err = s.mailer.Send(m.ToAddress, msg)
+	}
+
+	attempt := m.Attempts + 1
+	entry := model.EmailDeliveryLog{MessageID: m.ID, Attempt: attempt, Success: err == nil}
+	updates := map[string]any{"attempts": attempt}
+	if err == nil {
+		updates["status"] = model.EmailSent
+		updates["sent_at"] = time.Now()
+		updates["last_error"] = ""
+	} else {
+		entry.Error = err.Error()
+		updates["last_error"] = entry.Error
+		if attempt >= emailMaxAttempts {
+			updates["status"] = model.EmailFailed
+		} else {
+			updates["next_attempt_at"] = time.Now().Add(emailBaseBackoff << (attempt - 1))
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		return tx.Model(m).Updates(updates).Error
+	})
+}
+
+// ------------------------------------------------------------
+// Domain-event hook
+// ------------------------------------------------------------
+
+// emailKind maps a notice to the email template it is sent with, or "" if
+// the notice is in-app only.
+func emailKind(n notice) string {
+	switch {
+	case n.Kind == NoticeApprovalNeeded:
+		return emailApprovalNeeded
+	// A rejection is recognised by the step's action: contract rejections
+	// return the contract to draft rather than to a "rejected" status.
+	case n.Kind == NoticeDecision && n.Action == "reject":
+		return emailRejected
+	}
+	return ""
+}
+
+// queueEmails queues an email for every recipient of e who opted in to the
+// notice's kind and has an address.
+func queueEmails(tx *gorm.DB, e *DomainEvent) error {
+	notices, err := e.recipients(tx)
+	if err != nil {
+		return err
+	}
+	kinds := make(map[uuid.UUID]string)
+	var ids []uuid.UUID
+	for _, n := range notices {
+		if k := emailKind(n); k != "" {
+			kinds[n.RecipientID] = k
+			ids = append(ids, n.RecipientID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var rows []struct {
+		EmployeeID uuid.UUID
+		FirstName  string
+		LastName   string
+		Email      string
+		Language   string
+		Kinds      pq.StringArray
+	}
+	if err := tx.Table("notification_preferences p").
+		Select("p.employee_id, e.first_name, e.last_name, e.email, p.language, p.kinds").
+		Joins("JOIN employees e ON e.id = p.employee_id AND e.deleted_at IS NULL").
+		Where("p.employee_id IN ? AND p.email_enabled AND e.active AND e.email <> ''", ids).
+		Where("p.deleted_at IS NULL").
+		Scan(&rows).Error; err != nil {
+		return &ServiceError{Message: "Database error", Code: 500}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	noticeOf := make(map[uuid.UUID]notice, len(notices))
+	for _, n := range notices {
+		noticeOf[n.RecipientID] = n
+	}
+
+	var out []model.EmailMessage
+	for _, r := range rows {
+		kind := kinds[r.EmployeeID]
+		if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, kind) {
+			continue
+		}
+		n := noticeOf[r.EmployeeID]
+		emp := model.Employee{FirstName: r.FirstName, LastName: r.LastName}
+		data := emailData{Name: emp.FullName(), Label: n.Label, Stage: stageName(r.Language, n.Stage), Comment: n.Comment}
+		if r.Language == "fa" && n.LabelFa != "" {
+			data.Label = n.LabelFa
+		}
+		subject, text, html, err := renderEmail(kind, r.Language, data)
+		if err != nil {
+			return &ServiceError{Message: "Failed to render email", Code: 500}
+		}
+		out = append(out, model.EmailMessage{
+			CompanyID:   e.CompanyID,
+			RecipientID: r.EmployeeID,
+			ToAddress:   r.Email,
+			Subject:     subject,
+			TextBody:    text,
+			HTMLBody:    html,
+			EventType:   e.Type,
+			EntityType:  e.EntityType,
+			EntityID:    e.EntityID,
+			Status:      model.EmailPending,
+		})
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	if err := tx.Create(&out).Error; err != nil {
+		return &ServiceError{Message: "Failed to queue emails", Code: 500}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package services
+
+import "testing"
+
+func TestEmailKind(t *testing.T) {
+	cases := []struct {
+		name string
+		n    notice
+		want string
+	}{
+		{"approval needed", notice{Kind: NoticeApprovalNeeded, Stage: "finance_review"}, emailApprovalNeeded},
+		{"statement rejected", notice{Kind: NoticeDecision, Stage: "rejected", Action: "reject"}, emailRejected},
+		{"contract sent back to draft", notice{Kind: NoticeDecision, Stage: "draft", Action: "reject"}, emailRejected},
+		{"approved", notice{Kind: NoticeDecision, Stage: "pending_finance", Action: "approve"}, ""},
+		{"reopened", notice{Kind: NoticeDecision, Stage: "draft", Action: "reopen"}, ""},
+		{"attachment", notice{Kind: NoticeDocument, Stage: "submitted"}, ""},
+	}
+	for _, c := range cases {
+		if got := emailKind(c.n); got != c.want {
+			t.Errorf("%s: emailKind = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+// Email template keys. A notice is emailed only when it maps to one of these.
+const (
+	emailApprovalNeeded = "approval_needed"
+	emailRejected       = "rejected"
+)
+
+type emailTemplate struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// emailData is what the templates render.
+type emailData struct {
+	Name    string
+	Label   string
+	Stage   string
+	Comment string
+}
+
+// stageNames translates workflow statuses for email bodies.
+var stageNames = map[string]map[string]string{
+	"fa": {
+		"draft":               "پیش‌نویس",
+		"submitted":           "ارسال‌شده",
+		"finance_review":      "بررسی مالی",
+		"pm_review":           "بررسی مدیر پروژه",
+		"director_review":     "بررسی مدیرعامل",
+		"approved":            "تأییدشده",
+		"rejected":            "ردشده",
+		"pending_engineering": "بررسی فنی",
+		"pending_finance":     "بررسی مالی",
+		"pending_legal":       "بررسی حقوقی",
+		"pending_ceo":         "تأیید مدیرعامل",
+		"ready_to_print":      "آماده چاپ",
+		"signed":              "امضاشده",
+		"active":              "فعال",
+	},
+	"en": {
+		"draft":               "Draft",
+		"submitted":           "Submitted",
+		"finance_review":      "Finance review",
+		"pm_review":           "Project manager review",
+		"director_review":     "Director review",
+		"approved":            "Approved",
+		"rejected":            "Rejected",
+		"pending_engineering": "Engineering review",
+		"pending_finance":     "Finance review",
+		"pending_legal":       "Legal review",
+		"pending_ceo":         "CEO approval",
+		"ready_to_print":      "Ready to print",
+		"signed":              "Signed",
+		"active":              "Active",
+	},
+}
+
+func stageName(lang, stage string) string {
+	if s, ok := stageNames[lang][stage]; ok {
+		return s
+	}
+	return stage
+}
+
+const htmlLayoutFa = `<!DOCTYPE html>
+<html lang="fa" dir="rtl"><body style="font-family:Tahoma,Vazirmatn,sans-serif;direction:rtl;text-align:right">
+{{block "content" .}}{{end}}
+</body></html>`
+
+const htmlLayoutEn = `<!DOCTYPE html>
+<html lang="en"><body style="font-family:Arial,sans-serif">
+{{block "content" .}}{{end}}
+</body></html>`
+
+var emailTemplateSources = map[string]map[string][3]string{
+	emailApprovalNeeded: {
+		"fa": {
+			`نیاز به اقدام شما: {{.Label}} ({{.Stage}})`,
+			`{{.Name}} گرامی،
+
+{{.Label}} در مرحله «{{.Stage}}» منتظر بررسی شماست.
+{{if .Comment}}
+توضیحات: {{.Comment}}
+{{end}}`,
+			`{{define "content"}}<p>{{.Name}} گرامی،</p>
+<p><strong>{{.Label}}</strong> در مرحله «{{.Stage}}» منتظر بررسی شماست.</p>
+{{if .Comment}}<p>توضیحات: {{.Comment}}</p>{{end}}{{end}}`,
+		},
+		"en": {
+			`Action required: {{.Label}} ({{.Stage}})`,
+			`Dear {{.Name}},
+
+{{.Label}} is waiting for your review at the "{{.Stage}}" stage.
+{{if .Comment}}
+Comment: {{.Comment}}
+{{end}}`,
+			`{{define "content"}}<p>Dear {{.Name}},</p>
+<p><strong>{{.Label}}</strong> is waiting for your review at the “{{.Stage}}” stage.</p>
+{{if .Comment}}<p>Comment: {{.Comment}}</p>{{end}}{{end}}`,
+		},
+	},
+	emailRejected: {
+		"fa": {
+			`رد شد: {{.Label}}`,
+			`{{.Name}} گرامی،
+
+{{.Label}} رد شد.
+
+دلیل: {{.Comment}}`,
+			`{{define "content"}}<p>{{.Name}} گرامی،</p>
+<p><strong>{{.Label}}</strong> رد شد.</p>
+<p>دلیل: {{.Comment}}</p>{{end}}`,
+		},
+		"en": {
+			`Rejected: {{.Label}}`,
+			`Dear {{.Name}},
+
+{{.Label}} was rejected.
+
+Reason: {{.Comment}}`,
+			`{{define "content"}}<p>Dear {{.Name}},</p>
+<p><strong>{{.Label}}</strong> was rejected.</p>
+<p>Reason: {{.Comment}}</p>{{end}}`,
+		},
+	},
+}
+
+// emailTemplates is keyed by template key, then language.
+var emailTemplates = func() map[string]map[string]*emailTemplate {
+	out := make(map[string]map[string]*emailTemplate)
+	for key, langs := range emailTemplateSources {
+		out[key] = make(map[string]*emailTemplate)
+		for lang, src := range langs {
+			layout := htmlLayoutEn
+			if lang == "fa" {
+				layout = htmlLayoutFa
+			}
+			out[key][lang] = &emailTemplate{
+				subject: template.Must(template.New("subject").Parse(src[0])),
+				text:    template.Must(template.New("text").Parse(src[1])),
+				html:    htmltemplate.Must(htmltemplate.Must(htmltemplate.New("html").Parse(layout)).Parse(src[2])),
+			}
+		}
+	}
+	return out
+}()
+
+// renderEmail renders the subject, plain-text and HTML bodies of key in lang.
+func renderEmail(key, lang string, data emailData) (subject, text, html string, err error) {
+	t, ok := emailTemplates[key][lang]
+	if !ok {
+		t = emailTemplates[key]["fa"]
+	}
+	var sb, tb, hb bytes.Buffer
+	if err = t.subject.Execute(&sb, data); err != nil {
+		return
+	}
+	if err = t.text.Execute(&tb, data); err != nil {
+		return
+	}
+	if err = t.html.Execute(&hb, data); err != nil {
+		return
+	}
+	return sb.String(), tb.String(), hb.String(), nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultEscalationCoefficientBps = 9500
+
+// EscalationService manages the price index tables and exposes the per-chapter
+// escalation (تعدیل) of statements. The computation itself runs inside
+// recompute (see computeEscalation).
+type EscalationService struct{ db *gorm.DB }
+
+func NewEscalationService(db *gorm.DB) *EscalationService { return &EscalationService{db: db} }
+
+type PriceIndexReq struct {
+	IndexSet string `json:"index_set"`
+	Chapter  int    `json:"chapter"`
+	Year     int    `json:"year"`    // Jalali, e.g. 1404
+	Quarter  int    `json:"quarter"` // 1–4
+	Value    string `json:"value"`
+	Source   string `json:"source"`
+}
+
+type PriceIndexImportResult struct {
+	Imported int `json:"imported"`
+}
+
+func (req PriceIndexReq) toPriceIndex() (*model.PriceIndex, error) {
+	set := strings.TrimSpace(req.IndexSet)
+	if set == "" {
+		return nil, errors.New("index_set is required")
+	}
+	if req.Chapter < 0 {
+		return nil, errors.New("chapter must be zero or positive")
+	}
+	if req.Year < 1300 || req.Year > 1500 {
+		return nil, errors.New("year must be a Jalali year")
+	}
+	if req.Quarter < 1 || req.Quarter > 4 {
+		return nil, errors.New("quarter must be between 1 and 4")
+	}
+	value, err := decimal.NewFromString(strings.TrimSpace(req.Value))
+	if err != nil || !value.IsPositive() {
+		return nil, errors.New("value must be a positive number")
+	}
+	return &model.PriceIndex{
+		IndexSet: set,
+		Chapter:  req.Chapter,
+		Year:     req.Year,
+		Quarter:  req.Quarter,
+		Value:    value,
+		Source:   strings.TrimSpace(req.Source),
+	}, nil
+}
+
+// priceIndexUpsert overwrites the value of an existing (set, chapter, year, quarter).
+var priceIndexUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "index_set"}, {Name: "chapter"}, {Name: "year"}, {Name: "quarter"}},
+	DoUpdates: clause.AssignmentColumns([]string{"value", "source", "updated_at"}),
+}
+
+func (s *EscalationService) ListIndices(ctx context.Context, indexSet string, year, quarter, page, limit int) ([]model.PriceIndex, int64, error) {
+	q := s.db.WithContext(ctx).Model(&model.PriceIndex{})
+	if indexSet != "" {
+		q = q.Where("index_set = ?", indexSet)
+	}
+	if year > 0 {
+		q = q.Where("year = ?", year)
+	}
+	if quarter > 0 {
+		q = q.Where("quarter = ?", quarter)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	var items []model.PriceIndex
+	if err := q.Order("index_set ASC, year DESC, quarter DESC, chapter ASC").
+		Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return items, total, nil
+}
+
+// PutIndex creates the index or overwrites the value of an existing one.
+func (s *EscalationService) PutIndex(ctx context.Context, req PriceIndexReq) (*model.PriceIndex, error) {
+	idx, err := req.toPriceIndex()
+	if err != nil {
+		return nil, &ServiceError{Message: err.Error(), Code: 400}
+	}
+	if err := s.db.WithContext(ctx).Clauses(priceIndexUpsert).Create(idx).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return idx, nil
+}
+
+// DeleteIndex hard-deletes the index so its key can be reused.
+func (s *EscalationService) DeleteIndex(ctx context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return &ServiceError{Message: "Invalid price index ID", Code: 400}
+	}
+	result := s.db.WithContext(ctx).Unscoped().Where("id = ?", uid).Delete(&model.PriceIndex{})
+	if result.Error != nil {
+		return &ServiceError{Message: "Delete failed", Code: 500}
+	}
+	if result.RowsAffected == 0 {
+		return &ServiceError{Message: "Price index not found", Code: 404}
+	}
+	return nil
+}
+
+// ImportIndices bulk-loads indices from CSV with columns
+// index_set,chapter,year,quarter,value[,source]. A header row is skipped.
+// All-or-nothing, like the FX import; a key repeated within the file is a
+// row error and existing keys are overwritten.
+func (s *EscalationService) ImportIndices(ctx context.Context, r io.Reader) (*PriceIndexImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rowErrs []ImportRowError
+	var indices []model.PriceIndex
+	type key struct {
+		set                    string
+		chapter, year, quarter int
+	}
+	seen := make(map[key]int)
+	for line := 1; ; line++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, ImportRowError{Line: line, Message: err.Error()})
+			continue
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "index_set") {
+			continue
+		}
+		if len(rec) < 5 {
+			rowErrs = append(rowErrs, ImportRowError{Line: line, Message: "expected index_set,chapter,year,quarter,value[,source]"})
+			continue
+		}
+		chapter, err1 := strconv.Atoi(strings.TrimSpace(rec[1]))
+		year, err2 := strconv.Atoi(strings.TrimSpace(rec[2]))
+		quarter, err3 := strconv.Atoi(strings.TrimSpace(rec[3]))
+		if err1 != nil || err2 != nil || err3 != nil {
+			rowErrs = append(rowErrs, ImportRowError{Line: line, Message: "chapter, year and quarter must be integers"})
+			continue
+		}
+		req := PriceIndexReq{IndexSet: rec[0], Chapter: chapter, Year: year, Quarter: quarter, Value: rec[4]}
+		if len(rec) > 5 {
+			req.Source = rec[5]
+		}
+		idx, err := req.toPriceIndex()
+		if err != nil {
+			rowErrs = append(rowErrs, ImportRowError{Line: line, Message: err.Error()})
+			continue
+		}
+		k := key{idx.IndexSet, idx.Chapter, idx.Year, idx.Quarter}
+		if first, dup := seen[k]; dup {
+			rowErrs = append(rowErrs, ImportRowError{
+				Line:    line,
+				Message: fmt.Sprintf("%s chapter %d %d-Q%d repeats line %d", idx.IndexSet, idx.Chapter, idx.Year, idx.Quarter, first),
+			})
+			continue
+		}
+		seen[k] = line
+		indices = append(indices, *idx)
+	}
+	if len(rowErrs) > 0 {
+		return nil, &ServiceError{
+			Message: fmt.Sprintf("%d invalid row(s); nothing imported", len(rowErrs)),
+			Code:    422,
+			Errors:  rowErrs,
+		}
+	}
+	if len(indices) == 0 {
+		return nil, &ServiceError{Message: "CSV contains no indices", Code: 400}
+	}
+	if err := s.db.WithContext(ctx).Clauses(priceIndexUpsert).CreateInBatches(&indices, 500).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return &PriceIndexImportResult{Imported: len(indices)}, nil
+}
+
+// StatementEscalations returns the per-chapter escalation rows of a statement.
+func (s *EscalationService) StatementEscalations(ctx context.Context, statementID string) ([]model.StatementEscalation, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	var rows []model.StatementEscalation
+	if err := s.db.WithContext(ctx).Where("statement_id = ?", sid).Order("chapter ASC").Find(&rows).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return rows, nil
+}
+
+// computeEscalation rebuilds the StatementEscalation rows of stmt (work done
+// must be loaded) and sets stmt.EscalationAmount. Work amounts are grouped by
+// the chapter of their BOQ line and adjusted by the ratio of the index for the
+// Jalali quarter of PeriodEnd to the contract's base-quarter index. Only
+// unit-rate contracts with an index set escalate.
+func computeEscalation(tx *gorm.DB, stmt *model.InterimStatement, ct *model.Contract) error {
+	stmt.EscalationAmount = decimal.Zero
+	if err := tx.Unscoped().Where("statement_id = ?", stmt.ID).Delete(&model.StatementEscalation{}).Error; err != nil {
+		return &ServiceError{Message: "Failed to reset escalation", Code: 500}
+	}
+	if ct.Type != model.ContractUnitRate || ct.EscalationIndexSet == "" ||
+		ct.EscalationBaseYear == 0 || ct.EscalationBaseQuarter == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(stmt.WorkDoneItems))
+	for i := range stmt.WorkDoneItems {
+		if stmt.WorkDoneItems[i].LineItemID != nil {
+			ids = append(ids, *stmt.WorkDoneItems[i].LineItemID)
+		}
+	}
+	chapterOf := make(map[uuid.UUID]int, len(ids))
+	if len(ids) > 0 {
+		var lines []model.ContractLineItem
+		if err := tx.Select("id, chapter").Where("id IN ?", ids).Find(&lines).Error; err != nil {
+			return &ServiceError{Message: "Failed to load line items", Code: 500}
+		}
+		for _, li := range lines {
+			chapterOf[li.ID] = li.Chapter
+		}
+	}
+	work := make(map[int]decimal.Decimal)
+	for i := range stmt.WorkDoneItems {
+		wd := &stmt.WorkDoneItems[i]
+		ch := 0
+		if wd.LineItemID != nil {
+			ch = chapterOf[*wd.LineItemID]
+		}
+		work[ch] = work[ch].Add(wd.Amount)
+	}
+	if len(work) == 0 {
+		return nil
+	}
+
+	jy, jm, _ := gregorianToJalali(stmt.PeriodEnd.Year(), int(stmt.PeriodEnd.Month()), stmt.PeriodEnd.Day())
+	jq := (jm-1)/3 + 1
+
+	var indices []model.PriceIndex
+	if err := tx.Where("index_set = ? AND ((year = ? AND quarter = ?) OR (year = ? AND quarter = ?))",
+		ct.EscalationIndexSet, ct.EscalationBaseYear, ct.EscalationBaseQuarter, jy, jq).
+		Find(&indices).Error; err != nil {
+		return &ServiceError{Message: "Failed to load price indices", Code: 500}
+	}
+	type key struct{ chapter, year, quarter int }
+	byKey := make(map[key]decimal.Decimal, len(indices))
+	for _, idx := range indices {
+		byKey[key{idx.Chapter, idx.Year, idx.Quarter}] = idx.Value
+	}
+	lookup := func(ch, y, q int) (decimal.Decimal, bool) {
+		if v, ok := byKey[key{ch, y, q}]; ok {
+			return v, true
+		}
+		v, ok := byKey[key{0, y, q}]
+		return v, ok
+	}
+
+	coef := decimal.NewFromInt(int64(ct.EscalationCoefficientBps)).Div(decimal.NewFromInt(10000))
+	chapters := make([]int, 0, len(work))
+	for ch := range work {
+		chapters = append(chapters, ch)
+	}
+	slices.Sort(chapters)
+
+	rows := make([]model.StatementEscalation, 0, len(chapters))
+	for _, ch := range chapters {
+		row := model.StatementEscalation{
+			StatementID:    stmt.ID,
+			Chapter:        ch,
+			WorkAmount:     work[ch],
+			Year:           jy,
+			Quarter:        jq,
+			CoefficientBps: ct.EscalationCoefficientBps,
+		}
+		base, okBase := lookup(ch, ct.EscalationBaseYear, ct.EscalationBaseQuarter)
+		cur, okCur := lookup(ch, jy, jq)
+		if okBase && okCur && base.IsPositive() {
+			row.BaseIndex, row.CurrentIndex = base, cur
+			row.Amount = work[ch].Mul(coef).Mul(cur.Sub(base)).Div(base)
+		} else {
+			row.IndexMissing = true
+		}
+		stmt.EscalationAmount = stmt.EscalationAmount.Add(row.Amount)
+		rows = append(rows, row)
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return dbErr(err)
+	}
+	return nil
+}
+
+// requireEscalationIndices fails with 422 while any escalation row of the
+// statement is waiting for an unpublished price index: such a row counts as
+// zero, so submitting or approving would certify an understated amount.
+func requireEscalationIndices(tx *gorm.DB, statementID uuid.UUID) error {
+	var chapters []int
+	if err := tx.Model(&model.StatementEscalation{}).
+		Where("statement_id = ? AND index_missing", statementID).
+		Order("chapter ASC").Pluck("chapter", &chapters).Error; err != nil {
+		return &ServiceError{Message: "Failed to load escalation", Code: 500}
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+	names := make([]string, len(chapters))
+	for i, ch := range chapters {
+		names[i] = strconv.Itoa(ch)
+	}
+	return &ServiceError{
+		Message: "Price index not yet published for escalation chapter(s) " + strings.Join(names, ", "),
+		Code:    422,
+	}
+}
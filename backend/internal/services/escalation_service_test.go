@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Repeated keys are rejected before the upsert, which Postgres refuses when
+// one statement touches the same row twice.
+func TestImportIndicesRejectsRepeatedKeys(t *testing.T) {
+	csv := "index_set,chapter,year,quarter,value\n" +
+		"ابنیه,3,1403,2,412.5\n" +
+		"ابنیه,4,1403,2,398\n" +
+		" ابنیه ,3,1403,2,415\n"
+	_, err := (&EscalationService{}).ImportIndices(context.Background(), strings.NewReader(csv))
+	svcErr, ok := err.(*ServiceError)
+	if !ok || svcErr.Code != 422 {
+		t.Fatalf("ImportIndices error = %v, want a 422 ServiceError", err)
+	}
+	rows, _ := svcErr.Errors.([]ImportRowError)
+	if len(rows) != 1 || rows[0].Line != 4 || !strings.Contains(rows[0].Message, "repeats line 2") {
+		t.Fatalf("row errors = %+v, want line 4 repeating line 2", rows)
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+)
+
+// Domain event types.
+const (
+	EventContractTransitioned  = "contract.transitioned"
+	EventStatementTransitioned = "statement.transitioned"
+	EventStatementApproved     = "statement.approved"
+	EventStatementRejected     = "statement.rejected"
+	EventRetentionReleased     = "retention.released"
+	EventDamageWaived          = "liquidated_damage.waived"
+	EventAttachmentCreated     = "attachment.created"
+	EventApprovalEscalated     = "approval.escalated"
+	EventPaymentRecorded       = "payment.recorded"
+	EventPaymentVoided         = "payment.voided"
+	EventStatementRecomputed   = "statement.recomputed" // live updates only
+	EventVariationTransitioned = "variation_order.transitioned"
+	EventVariationApproved     = "variation_order.approved"
+	EventExtensionTransitioned = "extension_of_time.transitioned"
+	EventExtensionApproved     = "extension_of_time.approved"
+)
+
+// EventTypes lists every domain event type, e.g. for webhook subscriptions.
+var EventTypes = []string{
+	EventContractTransitioned,
+	EventStatementTransitioned,
+	EventStatementApproved,
+	EventStatementRejected,
+	EventRetentionReleased,
+	EventDamageWaived,
+	EventAttachmentCreated,
+	EventApprovalEscalated,
+	EventPaymentRecorded,
+	EventPaymentVoided,
+	EventVariationTransitioned,
+	EventVariationApproved,
+	EventExtensionTransitioned,
+	EventExtensionApproved,
+}
+
+// DomainEvent is something that happened to a document. It is published to
+// the in-process subscribers inside the transaction that caused it, so they
+// commit or roll back with it.
+type DomainEvent struct {
+	Type       string
+	CompanyID  uuid.UUID
+	EntityType string
+	EntityID   uuid.UUID
+	ActorID    uuid.UUID // uuid.Nil for system events (SLA scheduler)
+	OccurredAt time.Time
+
+	Approval   *model.ApprovalEvent      // transitions
+	Attachment *model.Attachment         // attachment.created
+	Escalation *model.ApprovalEscalation // approval.escalated
+	Payment    *model.Payment            // payment.recorded, payment.voided
+	Statement  *model.InterimStatement   // statement.recomputed
+
+	notices     []notice // resolved once, shared by the subscribers
+	noticesDone bool
+}
+
+// recipients resolves (once) who should hear about e; see noticesFor.
+func (e *DomainEvent) recipients(tx *gorm.DB) ([]notice, error) {
+	if !e.noticesDone {
+		n, err := noticesFor(tx, e)
+		if err != nil {
+			return nil, err
+		}
+		e.notices, e.noticesDone = n, true
+	}
+	return e.notices, nil
+}
+
+type domainSubscriber func(tx *gorm.DB, e *DomainEvent) error
+
+// domainSubscribers run in order for every published event.
+var domainSubscribers = []domainSubscriber{
+	notifyDomainEvent,
+	queueEmails,
+	queueWebhooks,
+	notifyLive,
+}
+
+func publish(tx *gorm.DB, e *DomainEvent) error {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+	for _, fn := range domainSubscribers {
+		if err := fn(tx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// approvalEventType names the domain event of a transition.
+func approvalEventType(evt *model.ApprovalEvent) string {
+	switch evt.EntityType {
+	case model.WorkflowContract:
+		return EventContractTransitioned
+	case model.WorkflowInterimStatement:
+		switch model.StatementStatus(evt.ToStatus) {
+		case model.StatementApproved:
+			return EventStatementApproved
+		case model.StatementRejected:
+			return EventStatementRejected
+		}
+		return EventStatementTransitioned
+	case model.WorkflowVariationOrder:
+		if evt.ToStatus == string(model.VariationApproved) {
+			return EventVariationApproved
+		}
+		return EventVariationTransitioned
+	case model.WorkflowExtensionOfTime:
+		if evt.ToStatus == string(model.ExtensionApproved) {
+			return EventExtensionApproved
+		}
+		return EventExtensionTransitioned
+	case "retention_record":
+		return EventRetentionReleased
+	case "liquidated_damage":
+		return EventDamageWaived
+	}
+	return evt.EntityType + ".transitioned"
+}
+
+// recordApproval writes an ApprovalEvent and publishes it. Every status
+// transition goes through here.
+func recordApproval(tx *gorm.DB, companyID uuid.UUID, evt *model.ApprovalEvent) error {
+	if err := tx.Create(evt).Error; err != nil {
+		return &ServiceError{Message: "Failed to write approval event", Code: 500}
+	}
+	return publish(tx, &DomainEvent{
+		Type:       approvalEventType(evt),
+		CompanyID:  companyID,
+		EntityType: evt.EntityType,
+		EntityID:   evt.EntityID,
+		ActorID:    evt.ActorID,
+		OccurredAt: evt.CreatedAt,
+		Approval:   evt,
+	})
+}
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExtensionService manages extensions of time: requests that move a
+// contract's completion date once approved.
+type ExtensionService struct{ db *gorm.DB }
+
+func NewExtensionService(db *gorm.DB) *ExtensionService { return &ExtensionService{db: db} }
+
+type CreateExtensionReq struct {
+	Cause            string `json:"cause"`
+	Description      string `json:"description"`
+	DaysRequested    int    `json:"days_requested"`
+	VariationOrderID string `json:"variation_order_id"`
+}
+
+type UpdateExtensionReq struct {
+	Cause            *string `json:"cause"`
+	Description      *string `json:"description"`
+	DaysRequested    *int    `json:"days_requested"`
+	VariationOrderID *string `json:"variation_order_id"` // "" clears the link
+}
+
+func (s *ExtensionService) List(ctx context.Context, contractID, status string) ([]model.ExtensionOfTime, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	q := s.db.WithContext(ctx).Where("contract_id = ?", cid)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var out []model.ExtensionOfTime
+	if err := q.Order("number ASC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+func (s *ExtensionService) Get(ctx context.Context, id string) (*model.ExtensionOfTime, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid extension of time ID", Code: 400}
+	}
+	var eot model.ExtensionOfTime
+	if err := s.db.WithContext(ctx).First(&eot, "id = ?", uid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Extension of time not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &eot, nil
+}
+
+// Create drafts the next extension of time of a signed or active contract
+// that has an end date.
+func (s *ExtensionService) Create(ctx context.Context, contractID string, req CreateExtensionReq, actorID uuid.UUID) (*model.ExtensionOfTime, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	cause := model.ExtensionCause(req.Cause)
+	if !cause.Valid() {
+		return nil, &ServiceError{Message: "cause must be employer_delay, force_majeure, variation, adverse_weather, late_payment or other", Code: 400}
+	}
+	if req.DaysRequested <= 0 {
+		return nil, &ServiceError{Message: "days_requested must be positive", Code: 400}
+	}
+
+	var eot model.ExtensionOfTime
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ct model.Contract
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, company_id, project_id, status, ends_on").First(&ct, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if ct.Status != model.ContractSigned && ct.Status != model.ContractActive {
+			return &ServiceError{Message: "Extensions of time can only be requested on signed or active contracts", Code: 409}
+		}
+		if ct.EndsOn == nil {
+			return &ServiceError{Message: "The contract has no end date to extend", Code: 422}
+		}
+		voID, err := extensionVariation(tx, cid, req.VariationOrderID)
+		if err != nil {
+			return err
+		}
+
+		var maxNo int
+		if err := tx.Unscoped().Model(&model.ExtensionOfTime{}).Where("contract_id = ?", cid).
+			Select("COALESCE(MAX(number), 0)").Scan(&maxNo).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+		eot = model.ExtensionOfTime{
+			CompanyID:        ct.CompanyID,
+			ContractID:       cid,
+			ProjectID:        ct.ProjectID,
+			Number:           maxNo + 1,
+			Reference:        fmt.Sprintf("EOT-%d", maxNo+1),
+			Cause:            cause,
+			Description:      strings.TrimSpace(req.Description),
+			DaysRequested:    req.DaysRequested,
+			Status:           model.ExtensionDraft,
+			VariationOrderID: voID,
+			CreatedByID:      actorID,
+		}
+		if err := tx.Create(&eot).Error; err != nil {
+			return dbErr(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &eot, nil
+}
+
+// Update edits a draft extension of time.
+func (s *ExtensionService) Update(ctx context.Context, id string, req UpdateExtensionReq) (*model.ExtensionOfTime, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid extension of time ID", Code: 400}
+	}
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		eot, err := lockDraftExtension(tx, uid)
+		if err != nil {
+			return err
+		}
+		updates := make(map[string]any)
+		if req.Cause != nil {
+			cause := model.ExtensionCause(*req.Cause)
+			if !cause.Valid() {
+				return &ServiceError{Message: "cause must be employer_delay, force_majeure, variation, adverse_weather, late_payment or other", Code: 400}
+			}
+			updates["cause"] = cause
+		}
+		if req.Description != nil {
+			updates["description"] = strings.TrimSpace(*req.Description)
+		}
+		if req.DaysRequested != nil {
+			if *req.DaysRequested <= 0 {
+				return &ServiceError{Message: "days_requested must be positive", Code: 400}
+			}
+			updates["days_requested"] = *req.DaysRequested
+		}
+		if req.VariationOrderID != nil {
+			voID, err := extensionVariation(tx, eot.ContractID, *req.VariationOrderID)
+			if err != nil {
+				return err
+			}
+			updates["variation_order_id"] = voID
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(eot).Updates(updates).Error; err != nil {
+				return &ServiceError{Message: "Update failed", Code: 500}
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete removes a draft extension of time. Its number is not reused.
+func (s *ExtensionService) Delete(ctx context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return &ServiceError{Message: "Invalid extension of time ID", Code: 400}
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		eot, err := lockDraftExtension(tx, uid)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(eot).Error; err != nil {
+			return &ServiceError{Message: "Delete failed", Code: 500}
+		}
+		return nil
+	})
+}
+
+func lockDraftExtension(tx *gorm.DB, id uuid.UUID) (*model.ExtensionOfTime, error) {
+	var eot model.ExtensionOfTime
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&eot, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Extension of time not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if eot.Status != model.ExtensionDraft {
+		return nil, &ServiceError{Message: "Only draft extensions of time can be edited", Code: 422}
+	}
+	return &eot, nil
+}
+
+// extensionVariation resolves the optional variation order an extension
+// stems from; it must belong to the same contract.
+func extensionVariation(tx *gorm.DB, contractID uuid.UUID, raw string) (*uuid.UUID, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	voID, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid variation_order_id", Code: 400}
+	}
+	var n int64
+	if err := tx.Model(&model.VariationOrder{}).
+		Where("id = ? AND contract_id = ?", voID, contractID).Count(&n).Error; err != nil {
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if n == 0 {
+		return nil, &ServiceError{Message: "Variation order not found on this contract", Code: 422}
+	}
+	return &voID, nil
+}
+
+// Transition applies action following the company's extension of time
+// workflow (see workflowFor). daysGranted may only accompany the step that
+// reaches approved and defaults to the days requested; approval moves the
+// contract's effective completion date in the same transaction.
+func (s *ExtensionService) Transition(ctx context.Context, id string, actorID uuid.UUID, actorRoles []string, action, comment string, daysGranted *int) (*model.ExtensionOfTime, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid extension of time ID", Code: 400}
+	}
+
+	var eot model.ExtensionOfTime
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&eot, "id = ?", uid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Extension of time not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+
+		def, err := workflowFor(tx, eot.CompanyID, model.WorkflowExtensionOfTime, string(eot.Status), eot.WorkflowDefinitionID)
+		if err != nil {
+			return err
+		}
+		if !hasStepsFrom(def.Steps, string(eot.Status)) {
+			return &ServiceError{Message: fmt.Sprintf("No transitions available from status %q", eot.Status), Code: 409}
+		}
+		step := findStep(def.Steps, string(eot.Status), func(st *model.WorkflowStep) bool { return st.Action == action })
+		if step == nil {
+			return &ServiceError{Message: fmt.Sprintf("Action %q is not valid for status %q", action, eot.Status), Code: 409}
+		}
+		scope := approvalScope{EntityType: model.WorkflowExtensionOfTime, EntityID: eot.ID, CompanyID: eot.CompanyID, ProjectID: &eot.ProjectID}
+		actor, err := resolveApprover(tx, step, scope, actorID, actorRoles)
+		if err != nil {
+			return err
+		}
+		if actor == nil {
+			return &ServiceError{Message: fmt.Sprintf("Action %q requires one of: %s", action, stepRequirement(step)), Code: 403}
+		}
+		if step.CommentRequired && comment == "" {
+			return &ServiceError{Message: "Comment is required for this action", Code: 400}
+		}
+
+		next := model.ExtensionStatus(step.To)
+		if daysGranted != nil && next != model.ExtensionApproved {
+			return &ServiceError{Message: "days_granted is only accepted on final approval", Code: 400}
+		}
+		cols := map[string]any{"status": next}
+		if eot.Status == model.ExtensionDraft {
+			eot.WorkflowDefinitionID = workflowPin(def)
+			cols["workflow_definition_id"] = eot.WorkflowDefinitionID
+		}
+		if next == model.ExtensionApproved {
+			granted := eot.DaysRequested
+			if daysGranted != nil {
+				granted = *daysGranted
+			}
+			if granted <= 0 || granted > eot.DaysRequested {
+				return &ServiceError{Message: fmt.Sprintf("days_granted must be between 1 and %d", eot.DaysRequested), Code: 422}
+			}
+			if err := applyExtension(tx, &eot, granted); err != nil {
+				return err
+			}
+			cols["days_granted"] = eot.DaysGranted
+			cols["prev_ends_on"] = eot.PrevEndsOn
+			cols["new_ends_on"] = eot.NewEndsOn
+			cols["approved_at"] = eot.ApprovedAt
+		}
+		if err := tx.Model(&model.ExtensionOfTime{}).Where("id = ?", eot.ID).Updates(cols).Error; err != nil {
+			return &ServiceError{Message: "Transition failed", Code: 500}
+		}
+
+		evt := model.ApprovalEvent{
+			EntityType:    model.WorkflowExtensionOfTime,
+			EntityID:      eot.ID,
+			ActorID:       actorID,
+			FromStatus:    string(eot.Status),
+			ToStatus:      string(next),
+			Action:        step.Action,
+			Comment:       comment,
+			CreatedAt:     time.Now(),
+			ActorPosition: actor.Position,
+			OnBehalfOfID:  actor.OnBehalfOf,
+		}
+		if err := recordApproval(tx, eot.CompanyID, &evt); err != nil {
+			return err
+		}
+		eot.Status = next
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &eot, nil
+}
+
+// applyExtension adds granted days to the contract and moves its effective
+// completion date. The contract row is locked so concurrent approvals add up;
+// EffectiveEndsOn is always recomputed from the original EndsOn.
+func applyExtension(tx *gorm.DB, eot *model.ExtensionOfTime, granted int) error {
+	var ct model.Contract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id, status, ends_on, extension_days").
+		First(&ct, "id = ?", eot.ContractID).Error; err != nil {
+		return &ServiceError{Message: "Contract not found", Code: 404}
+	}
+	if ct.Status != model.ContractSigned && ct.Status != model.ContractActive {
+		return &ServiceError{Message: "The contract is no longer signed or active", Code: 409}
+	}
+	if ct.EndsOn == nil {
+		return &ServiceError{Message: "The contract has no end date to extend", Code: 422}
+	}
+
+	days, prev, next := extendedEndsOn(*ct.EndsOn, ct.ExtensionDays, granted)
+	if err := tx.Model(&ct).Updates(map[string]any{
+		"extension_days":    days,
+		"effective_ends_on": next,
+	}).Error; err != nil {
+		return &ServiceError{Message: "Failed to update contract completion date", Code: 500}
+	}
+	now := time.Now()
+	eot.DaysGranted, eot.PrevEndsOn, eot.NewEndsOn, eot.ApprovedAt = granted, &prev, &next, &now
+	return nil
+}
+
+// extendedEndsOn adds granted days to the extensionDays already on a contract
+// ending on endsOn. It returns the new total and the effective completion
+// dates before and after, both counted from endsOn.
+func extendedEndsOn(endsOn time.Time, extensionDays, granted int) (days int, prev, next time.Time) {
+	days = extensionDays + granted
+	return days, endsOn.AddDate(0, 0, extensionDays), endsOn.AddDate(0, 0, days)
+}
+
+func (s *ExtensionService) ListApprovals(ctx context.Context, id string) ([]model.ApprovalEvent, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid extension of time ID", Code: 400}
+	}
+	var events []model.ApprovalEvent
+	if err := s.db.WithContext(ctx).
+		Where("entity_type = ? AND entity_id = ?", model.WorkflowExtensionOfTime, uid).
+		Order("created_at ASC").
+		Find(&events).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return events, nil
+}
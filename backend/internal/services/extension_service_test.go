@@ -0,0 +1,31 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtendedEndsOn(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	cases := []struct {
+		name              string
+		endsOn            time.Time
+		extension, grant  int
+		days              int
+		wantPrev, wantNew time.Time
+	}{
+		{"first extension", date(2025, 6, 30), 0, 45, 45, date(2025, 6, 30), date(2025, 8, 14)},
+		{"adds to earlier extensions", date(2025, 6, 30), 45, 30, 75, date(2025, 8, 14), date(2025, 9, 13)},
+		{"across a leap day", date(2024, 2, 20), 0, 10, 10, date(2024, 2, 20), date(2024, 3, 1)},
+		{"across the year end", date(2025, 12, 15), 10, 20, 30, date(2025, 12, 25), date(2026, 1, 14)},
+		{"zero days granted", date(2025, 6, 30), 45, 0, 45, date(2025, 8, 14), date(2025, 8, 14)},
+	}
+	for _, c := range cases {
+		days, prev, next := extendedEndsOn(c.endsOn, c.extension, c.grant)
+		if days != c.days || !prev.Equal(c.wantPrev) || !next.Equal(c.wantNew) {
+			t.Errorf("%s: got %d days, %s → %s; want %d days, %s → %s", c.name,
+				days, prev.Format(time.DateOnly), next.Format(time.DateOnly),
+				c.days, c.wantPrev.Format(time.DateOnly), c.wantNew.Format(time.DateOnly))
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reportingCurrency is the currency statements are settled in. Statements of
+// contracts in any other currency lock a <currency>→IRR rate at approval.
+const reportingCurrency = "IRR"
+
+// FxService manages the fx_rates reference table.
+type FxService struct{ db *gorm.DB }
+
+func NewFxService(db *gorm.DB) *FxService { return &FxService{db: db} }
+
+type FxRateReq struct {
+	FromCode      string `json:"from_code"`
+	ToCode        string `json:"to_code"`
+	Rate          string `json:"rate"`
+	EffectiveDate string `json:"effective_date"` // "2006-01-02"
+	Source        string `json:"source"`
+}
+
+type UpdateFxRateReq struct {
+	Rate   *string `json:"rate"`
+	Source *string `json:"source"`
+}
+
+// ImportRowError reports why one imported row was rejected (1-based line number).
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type FxImportResult struct {
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// toFxRate validates req and builds the row. Codes are upper-cased.
+func (req FxRateReq) toFxRate() (*model.FXRate, error) {
+	from := strings.ToUpper(strings.TrimSpace(req.FromCode))
+	to := strings.ToUpper(strings.TrimSpace(req.ToCode))
+	if len(from) != 3 || len(to) != 3 {
+		return nil, errors.New("from_code and to_code must be 3-letter ISO codes")
+	}
+	if from == to {
+		return nil, errors.New("from_code and to_code must differ")
+	}
+	rate, err := decimal.NewFromString(strings.TrimSpace(req.Rate))
+	if err != nil || !rate.IsPositive() {
+		return nil, errors.New("rate must be a positive number")
+	}
+	date := parseDate(strings.TrimSpace(req.EffectiveDate))
+	if date == nil {
+		return nil, errors.New("invalid effective_date (expected YYYY-MM-DD)")
+	}
+	return &model.FXRate{
+		FromCode:      from,
+		ToCode:        to,
+		Rate:          rate,
+		EffectiveDate: *date,
+		Source:        strings.TrimSpace(req.Source),
+	}, nil
+}
+
+func (s *FxService) List(ctx context.Context, from, to string, page, limit int) ([]model.FXRate, int64, error) {
+	q := s.db.WithContext(ctx).Model(&model.FXRate{})
+	if from != "" {
+		q = q.Where("from_code = ?", strings.ToUpper(from))
+	}
+	if to != "" {
+		q = q.Where("to_code = ?", strings.ToUpper(to))
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	var items []model.FXRate
+	if err := q.Order("effective_date DESC, from_code ASC").Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return items, total, nil
+}
+
+func (s *FxService) Create(ctx context.Context, req FxRateReq) (*model.FXRate, error) {
+	rate, err := req.toFxRate()
+	if err != nil {
+		return nil, &ServiceError{Message: err.Error(), Code: 400}
+	}
+	if err := s.db.WithContext(ctx).Create(rate).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return rate, nil
+}
+
+func (s *FxService) Update(ctx context.Context, id string, req UpdateFxRateReq) (*model.FXRate, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid FX rate ID", Code: 400}
+	}
+	var rate model.FXRate
+	if err := s.db.WithContext(ctx).First(&rate, "id = ?", uid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "FX rate not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	updates := make(map[string]any)
+	if req.Rate != nil {
+		v, err := decimal.NewFromString(*req.Rate)
+		if err != nil || !v.IsPositive() {
+			return nil, &ServiceError{Message: "rate must be a positive number", Code: 400}
+		}
+		updates["rate"] = v
+	}
+	if req.Source != nil {
+		updates["source"] = *req.Source
+	}
+	if len(updates) > 0 {
+		if err := s.db.WithContext(ctx).Model(&rate).Updates(updates).Error; err != nil {
+			return nil, &ServiceError{Message: "Update failed", Code: 500}
+		}
+	}
+	return &rate, nil
+}
+
+// Delete hard-deletes the rate so the (from, to, date) slot can be reused.
+// Statements keep their own FxRate snapshot, so history is unaffected.
+func (s *FxService) Delete(ctx context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return &ServiceError{Message: "Invalid FX rate ID", Code: 400}
+	}
+	result := s.db.WithContext(ctx).Unscoped().Where("id = ?", uid).Delete(&model.FXRate{})
+	if result.Error != nil {
+		return &ServiceError{Message: "Delete failed", Code: 500}
+	}
+	if result.RowsAffected == 0 {
+		return &ServiceError{Message: "FX rate not found", Code: 404}
+	}
+	return nil
+}
+
+// Lookup returns the rate in effect on date: the latest row on or before it.
+func (s *FxService) Lookup(ctx context.Context, from, to, date string) (*model.FXRate, error) {
+	if len(from) != 3 || len(to) != 3 {
+		return nil, &ServiceError{Message: "from and to must be 3-letter ISO codes", Code: 400}
+	}
+	on := time.Now()
+	if date != "" {
+		t := parseDate(date)
+		if t == nil {
+			return nil, &ServiceError{Message: "Invalid date (expected YYYY-MM-DD)", Code: 400}
+		}
+		on = *t
+	}
+	rate, err := fxRateOn(s.db.WithContext(ctx), strings.ToUpper(from), strings.ToUpper(to), on)
+	if err != nil {
+		return nil, err
+	}
+	if rate == nil {
+		return nil, &ServiceError{Message: "No FX rate in effect on " + on.Format("2006-01-02"), Code: 404}
+	}
+	return rate, nil
+}
+
+// Import bulk-loads rates from CSV with columns
+// from_code,to_code,rate,effective_date[,source]. A header row is skipped.
+// The import is all-or-nothing: any invalid row aborts it and every row error
+// is reported. A (from, to, date) repeated within the file is a row error;
+// existing rows are overwritten.
+func (s *FxService) Import(ctx context.Context, r io.Reader) (*FxImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	result := &FxImportResult{}
+	var rates []model.FXRate
+	// seen maps from|to|date to the line it was first read on.
+	seen := make(map[string]int)
+	for line := 1; ; line++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Line: line, Message: err.Error()})
+			continue
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "from_code") {
+			continue
+		}
+		if len(rec) < 4 {
+			result.Errors = append(result.Errors, ImportRowError{Line: line, Message: "expected from_code,to_code,rate,effective_date[,source]"})
+			continue
+		}
+		req := FxRateReq{FromCode: rec[0], ToCode: rec[1], Rate: rec[2], EffectiveDate: rec[3]}
+		if len(rec) > 4 {
+			req.Source = rec[4]
+		}
+		rate, err := req.toFxRate()
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Line: line, Message: err.Error()})
+			continue
+		}
+		day := rate.EffectiveDate.Format("2006-01-02")
+		key := rate.FromCode + "|" + rate.ToCode + "|" + day
+		if first, ok := seen[key]; ok {
+			result.Errors = append(result.Errors, ImportRowError{
+				Line:    line,
+				Message: fmt.Sprintf("duplicate of line %d: %s→%s on %s", first, rate.FromCode, rate.ToCode, day),
+			})
+			continue
+		}
+		seen[key] = line
+		rates = append(rates, *rate)
+	}
+	if len(result.Errors) > 0 {
+		return nil, &ServiceError{
+			Message: fmt.Sprintf("%d invalid row(s); nothing imported", len(result.Errors)),
+			Code:    422,
+			Errors:  result.Errors,
+		}
+	}
+	if len(rates) == 0 {
+		return nil, &ServiceError{Message: "CSV contains no rates", Code: 400}
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "from_code"}, {Name: "to_code"}, {Name: "effective_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(&rates, 500).Error
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	result.Imported = len(rates)
+	return result, nil
+}
+
+// fxRateOn returns the latest from→to rate effective on or before date, or
+// nil when there is none.
+func fxRateOn(tx *gorm.DB, from, to string, date time.Time) (*model.FXRate, error) {
+	var rate model.FXRate
+	err := tx.Where("from_code = ? AND to_code = ? AND effective_date <= ?", from, to, date).
+		Order("effective_date DESC").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &rate, nil
+}
+
+// lockFxRate snapshots the rate in effect on stmt.IssuedOn into FxRate and
+// FxRateDate. Statements in the reporting currency lock 1.
+func lockFxRate(tx *gorm.DB, stmt *model.InterimStatement) error {
+	rate, date := decimal.NewFromInt(1), stmt.IssuedOn
+	if stmt.Currency != reportingCurrency {
+		fx, err := fxRateOn(tx, stmt.Currency, reportingCurrency, stmt.IssuedOn)
+		if err != nil {
+			return err
+		}
+		if fx == nil {
+			return &ServiceError{
+				Message: fmt.Sprintf("No %s→%s FX rate in effect on %s", stmt.Currency, reportingCurrency, stmt.IssuedOn.Format("2006-01-02")),
+				Code:    422,
+			}
+		}
+		rate, date = fx.Rate, fx.EffectiveDate
+	}
+	if err := tx.Model(stmt).Updates(map[string]any{"fx_rate": rate, "fx_rate_date": date}).Error; err != nil {
+		return &ServiceError{Message: "Failed to lock FX rate", Code: 500}
+	}
+	stmt.FxRate, stmt.FxRateDate = rate, &date
+	return nil
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Duplicate rows are rejected before the upsert, which Postgres refuses when
+// one statement touches the same row twice.
+func TestFxImportRejectsDuplicateRows(t *testing.T) {
+	csv := "from_code,to_code,rate,effective_date\n" +
+		"USD,IRR,585000,2025-05-01\n" +
+		"EUR,IRR,640000,2025-05-01\n" +
+		"usd, irr ,590000,2025-05-01\n"
+	_, err := (&FxService{}).Import(context.Background(), strings.NewReader(csv))
+	svcErr, ok := err.(*ServiceError)
+	if !ok || svcErr.Code != 422 {
+		t.Fatalf("Import error = %v, want a 422 ServiceError", err)
+	}
+	rows, _ := svcErr.Errors.([]ImportRowError)
+	if len(rows) != 1 || rows[0].Line != 4 || !strings.Contains(rows[0].Message, "duplicate of line 2") {
+		t.Fatalf("row errors = %+v, want line 4 duplicate of line 2", rows)
+	}
+}
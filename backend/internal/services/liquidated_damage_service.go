@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LiquidatedDamageService manages the damages (خسارت) applied to an
+// InterimStatement. Delay damages are proposed from Contract.CompletionDate and
+// Contract.LdRatePerDay; performance and other damages are entered manually.
+// The non-waived total feeds InterimStatement.LdAmount through recompute.
+type LiquidatedDamageService struct{ db *gorm.DB }
+
+func NewLiquidatedDamageService(db *gorm.DB) *LiquidatedDamageService {
+	return &LiquidatedDamageService{db: db}
+}
+
+type CreateLiquidatedDamageReq struct {
+	LdType     string `json:"ld_type"` // performance | other
+	Amount     string `json:"amount"`
+	PeriodFrom string `json:"period_from"` // "2006-01-02"; defaults to the statement period
+	PeriodTo   string `json:"period_to"`
+}
+
+type WaiveLiquidatedDamageReq struct {
+	Reason string `json:"reason"`
+}
+
+func (s *LiquidatedDamageService) List(ctx context.Context, statementID string) ([]model.LiquidatedDamage, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	var items []model.LiquidatedDamage
+	if err := s.db.WithContext(ctx).
+		Where("interim_statement_id = ?", sid).
+		Order("created_at ASC").
+		Find(&items).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return items, nil
+}
+
+// ProposeDelay computes delay damages for the part of the statement period
+// that runs past the contract's effective completion date (after approved
+// extensions of time): LdRatePerDay × days, clamped to whatever is
+// left under the contract cap. Re-proposing replaces the statement's existing
+// delay row, so the proposal follows edits to the period or contract terms.
+func (s *LiquidatedDamageService) ProposeDelay(ctx context.Context, statementID string, actorID uuid.UUID) (*model.LiquidatedDamage, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+
+	var ld model.LiquidatedDamage
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stmt, ct, err := lockDraftStatement(tx, sid)
+		if err != nil {
+			return err
+		}
+		completion := ct.CompletionDate()
+		if completion == nil {
+			return &ServiceError{Message: "Contract has no end date", Code: 422}
+		}
+		if !ct.LdRatePerDay.IsPositive() {
+			return &ServiceError{Message: "Contract has no ld_rate_per_day", Code: 422}
+		}
+		from := stmt.PeriodStart
+		if !from.After(*completion) {
+			from = completion.AddDate(0, 0, 1)
+		}
+		days := calendarDays(from, stmt.PeriodEnd)
+		if days <= 0 {
+			return &ServiceError{Message: "Statement period does not run past the contract end date", Code: 422}
+		}
+
+		var existing model.LiquidatedDamage
+		err = tx.Where("interim_statement_id = ? AND ld_type = ?", sid, model.LDDelay).First(&existing).Error
+		switch {
+		case err == nil && existing.Waived:
+			return &ServiceError{Message: "Delay damages on this statement have been waived", Code: 409}
+		case err == nil:
+			if err := tx.Delete(&existing).Error; err != nil {
+				return &ServiceError{Message: "Delete failed", Code: 500}
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+
+		amount := ct.LdRatePerDay.Mul(decimal.NewFromInt(int64(days)))
+		if headroom, capped := ldHeadroom(tx, ct); capped {
+			amount = decimal.Min(amount, headroom)
+		}
+		ld = model.LiquidatedDamage{
+			InterimStatementID: stmt.ID,
+			ContractID:         ct.ID,
+			LdType:             model.LDDelay,
+			RatePerDay:         ct.LdRatePerDay,
+			DaysApplied:        days,
+			Amount:             amount,
+			CurrencyCode:       stmt.Currency,
+			PeriodFrom:         from,
+			PeriodTo:           stmt.PeriodEnd,
+			CreatedByID:        actorID,
+		}
+		if err := tx.Create(&ld).Error; err != nil {
+			return dbErr(err)
+		}
+		return reloadAndRecompute(tx, stmt, ct)
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &ld, nil
+}
+
+// Create adds a manual performance or other damage. Unlike delay proposals the
+// amount is never clamped: exceeding the contract cap is rejected.
+func (s *LiquidatedDamageService) Create(ctx context.Context, statementID string, req CreateLiquidatedDamageReq, actorID uuid.UUID) (*model.LiquidatedDamage, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	ldType := model.LDType(req.LdType)
+	if ldType != model.LDPerformance && ldType != model.LDOther {
+		return nil, &ServiceError{Message: "ld_type must be performance or other (delay damages are proposed)", Code: 400}
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		return nil, &ServiceError{Message: "amount must be a positive number", Code: 400}
+	}
+
+	var ld model.LiquidatedDamage
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stmt, ct, err := lockDraftStatement(tx, sid)
+		if err != nil {
+			return err
+		}
+		if headroom, capped := ldHeadroom(tx, ct); capped && amount.GreaterThan(headroom) {
+			return &ServiceError{Message: "amount exceeds the remaining damages cap of " + headroom.String(), Code: 422}
+		}
+		from, to := stmt.PeriodStart, stmt.PeriodEnd
+		if req.PeriodFrom != "" {
+			if from = derefDate(parseDate(req.PeriodFrom)); from.IsZero() {
+				return &ServiceError{Message: "Invalid period_from (expected YYYY-MM-DD)", Code: 400}
+			}
+		}
+		if req.PeriodTo != "" {
+			if to = derefDate(parseDate(req.PeriodTo)); to.IsZero() {
+				return &ServiceError{Message: "Invalid period_to (expected YYYY-MM-DD)", Code: 400}
+			}
+		}
+		ld = model.LiquidatedDamage{
+			InterimStatementID: stmt.ID,
+			ContractID:         ct.ID,
+			LdType:             ldType,
+			Amount:             amount,
+			CurrencyCode:       stmt.Currency,
+			PeriodFrom:         from,
+			PeriodTo:           to,
+			CreatedByID:        actorID,
+		}
+		if err := tx.Create(&ld).Error; err != nil {
+			return dbErr(err)
+		}
+		return reloadAndRecompute(tx, stmt, ct)
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &ld, nil
+}
+
+func (s *LiquidatedDamageService) Delete(ctx context.Context, statementID, ldID string) error {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	lid, err := uuid.Parse(ldID)
+	if err != nil {
+		return &ServiceError{Message: "Invalid damage ID", Code: 400}
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stmt, ct, err := lockDraftStatement(tx, sid)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND interim_statement_id = ?", lid, sid).Delete(&model.LiquidatedDamage{})
+		if result.Error != nil {
+			return &ServiceError{Message: "Delete failed", Code: 500}
+		}
+		if result.RowsAffected == 0 {
+			return &ServiceError{Message: "Damage not found", Code: 404}
+		}
+		return reloadAndRecompute(tx, stmt, ct)
+	})
+}
+
+// Waive forgives a damage while its statement is still in the approval chain.
+// The row is kept for audit; an ApprovalEvent records who waived it and why.
+func (s *LiquidatedDamageService) Waive(ctx context.Context, statementID, ldID string, req WaiveLiquidatedDamageReq, actorID uuid.UUID) (*model.LiquidatedDamage, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	lid, err := uuid.Parse(ldID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid damage ID", Code: 400}
+	}
+	if req.Reason == "" {
+		return nil, &ServiceError{Message: "reason is required", Code: 400}
+	}
+
+	var ld model.LiquidatedDamage
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stmt model.InterimStatement
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stmt, "id = ?", sid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Statement not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if stmt.Status == model.StatementApproved || stmt.Status == model.StatementRejected {
+			return &ServiceError{Message: "Damages on approved or rejected statements cannot be waived", Code: 422}
+		}
+		if err := tx.First(&ld, "id = ? AND interim_statement_id = ?", lid, sid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Damage not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if ld.Waived {
+			return &ServiceError{Message: "Damage is already waived", Code: 409}
+		}
+		if err := tx.Model(&ld).Updates(map[string]any{"waived": true, "waiver_reason": req.Reason}).Error; err != nil {
+			return &ServiceError{Message: "Update failed", Code: 500}
+		}
+		ld.Waived, ld.WaiverReason = true, req.Reason
+
+		evt := model.ApprovalEvent{
+			EntityType: "liquidated_damage",
+			EntityID:   ld.ID,
+			ActorID:    actorID,
+			FromStatus: "applied",
+			ToStatus:   "waived",
+			Comment:    req.Reason,
+			CreatedAt:  time.Now(),
+		}
+		if err := recordApproval(tx, stmt.CompanyID, &evt); err != nil {
+			return err
+		}
+
+		var ct model.Contract
+		if err := tx.First(&ct, "id = ?", stmt.ContractID).Error; err != nil {
+			return &ServiceError{Message: "Contract not found", Code: 500}
+		}
+		return reloadAndRecompute(tx, &stmt, &ct)
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &ld, nil
+}
+
+// lockDraftStatement locks a draft statement and its contract for editing.
+// The contract lock serialises cap checks across the contract's statements.
+func lockDraftStatement(tx *gorm.DB, sid uuid.UUID) (*model.InterimStatement, *model.Contract, error) {
+	var stmt model.InterimStatement
+	if err := tx.First(&stmt, "id = ?", sid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, &ServiceError{Message: "Statement not found", Code: 404}
+		}
+		return nil, nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if stmt.Status != model.StatementDraft {
+		return nil, nil, &ServiceError{Message: "Only draft statements can be edited", Code: 422}
+	}
+	var ct model.Contract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, "id = ?", stmt.ContractID).Error; err != nil {
+		return nil, nil, &ServiceError{Message: "Contract not found", Code: 500}
+	}
+	return &stmt, &ct, nil
+}
+
+// reloadAndRecompute reloads stmt with its children and refreshes its aggregates.
+func reloadAndRecompute(tx *gorm.DB, stmt *model.InterimStatement, ct *model.Contract) error {
+	if err := tx.Preload("WorkDoneItems").Preload("ExtraWorkItems").Preload("DeductionItems").
+		First(stmt, "id = ?", stmt.ID).Error; err != nil {
+		return &ServiceError{Message: "Database error", Code: 500}
+	}
+	return recompute(tx, stmt, ct)
+}
+
+// ldTotal is the non-waived damages total of a statement (InterimStatement.LdAmount).
+func ldTotal(tx *gorm.DB, statementID uuid.UUID) decimal.Decimal {
+	var total decimal.Decimal
+	tx.Model(&model.LiquidatedDamage{}).
+		Where("interim_statement_id = ? AND waived = false", statementID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total)
+	return total
+}
+
+// ldHeadroom returns how much more damage may be applied to the contract
+// before hitting LdCapPctBps of GrossBudget. capped is false when no cap is set.
+func ldHeadroom(tx *gorm.DB, ct *model.Contract) (headroom decimal.Decimal, capped bool) {
+	if ct.LdCapPctBps <= 0 {
+		return decimal.Zero, false
+	}
+	limit := ct.GrossBudget.Mul(decimal.NewFromInt(int64(ct.LdCapPctBps))).Div(decimal.NewFromInt(10000))
+	var applied decimal.Decimal
+	tx.Model(&model.LiquidatedDamage{}).
+		Where("contract_id = ? AND waived = false", ct.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&applied)
+	return decimal.Max(limit.Sub(applied), decimal.Zero), true
+}
+
+// calendarDays counts the days from..to inclusive, ignoring time of day.
+func calendarDays(from, to time.Time) int {
+	f := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	t := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(t.Sub(f).Hours()/24) + 1
+}
+
+func derefDate(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarDays(t *testing.T) {
+	tehran := time.FixedZone("IRST", 3*3600+1800)
+	cases := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"same day", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), 1},
+		{"time of day ignored", time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC), time.Date(2025, 3, 2, 0, 1, 0, 0, time.UTC), 2},
+		{"across february of a leap year", time.Date(2024, 2, 27, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 4},
+		{"whole year", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), 365},
+		{"local dates", time.Date(2025, 3, 20, 1, 0, 0, 0, tehran), time.Date(2025, 3, 21, 23, 0, 0, 0, tehran), 2},
+		{"to before from", time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), 0},
+	}
+	for _, c := range cases {
+		if got := calendarDays(c.from, c.to); got != c.want {
+			t.Errorf("%s: calendarDays = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
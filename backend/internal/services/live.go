@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// liveChannel is the Postgres NOTIFY channel every API instance listens on.
+const liveChannel = "ledger_live"
+
+// NOTIFY payloads are capped at 8000 bytes; larger events are sent without
+// Data and clients refetch.
+const liveMaxPayload = 7900
+
+const liveClientBuffer = 64
+
+// LiveResync tells a client it may have missed events and should refetch.
+const LiveResync = "live.resync"
+
+// liveTicketTTL is how long a stream ticket may wait before it is redeemed.
+const liveTicketTTL = 30 * time.Second
+
+// liveAmountRoles may see statement aggregates and payment amounts on the
+// stream: the roles that can read statement payments, plus admin and sudoer.
+var liveAmountRoles = []string{"sudoer", "admin", "manager", "engineering_head", "finance_head", "juridical_head"}
+
+// liveAmountEvents carry statement aggregates or payment amounts in Data.
+var liveAmountEvents = map[string]bool{
+	EventStatementRecomputed: true,
+	EventPaymentRecorded:     true,
+	EventPaymentVoided:       true,
+}
+
+// CanSeeLiveAmounts reports whether a subscriber with roles receives the
+// Data of statement and payment events.
+func CanSeeLiveAmounts(roles []string) bool {
+	for _, r := range roles {
+		if slices.Contains(liveAmountRoles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// LiveEvent is what the live endpoint streams to clients. ContractID is set
+// for events of a contract and of its statements, variation orders and
+// extensions of time, so a contract page can follow all of its documents.
+type LiveEvent struct {
+	Type       string     `json:"type"`
+	CompanyID  uuid.UUID  `json:"company_id"`
+	EntityType string     `json:"entity_type"`
+	EntityID   uuid.UUID  `json:"entity_id"`
+	ContractID *uuid.UUID `json:"contract_id,omitempty"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty"`
+	OccurredAt time.Time  `json:"occurred_at"`
+	Data       any        `json:"data,omitempty"`
+}
+
+// liveEventFor picks the small part of e that live clients need.
+func liveEventFor(e *DomainEvent) LiveEvent {
+	out := LiveEvent{
+		Type:       e.Type,
+		CompanyID:  e.CompanyID,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		OccurredAt: e.OccurredAt,
+	}
+	if e.ActorID != uuid.Nil {
+		id := e.ActorID
+		out.ActorID = &id
+	}
+	if e.EntityType == model.WorkflowContract {
+		id := e.EntityID
+		out.ContractID = &id
+	}
+	switch {
+	case e.Statement != nil:
+		out.ContractID = &e.Statement.ContractID
+		out.Data = aggregateCols(e.Statement)
+	case e.Approval != nil:
+		out.Data = map[string]any{
+			"from_status": e.Approval.FromStatus,
+			"to_status":   e.Approval.ToStatus,
+			"comment":     e.Approval.Comment,
+		}
+	case e.Attachment != nil:
+		out.Data = map[string]any{
+			"id":        e.Attachment.ID,
+			"file_name": e.Attachment.FileName,
+		}
+	case e.Escalation != nil:
+		out.Data = map[string]any{"stage": e.Escalation.Stage, "due_at": e.Escalation.DueAt}
+	case e.Payment != nil:
+		out.ContractID = &e.Payment.ContractID
+		out.Data = map[string]any{"payment_id": e.Payment.ID, "amount": e.Payment.Amount}
+	}
+	return out
+}
+
+// notifyLive queues e on the live channel. Postgres delivers NOTIFY only when
+// the transaction commits, so clients never see rolled-back changes.
+func notifyLive(tx *gorm.DB, e *DomainEvent) error {
+	evt := liveEventFor(e)
+	var parent any
+	switch e.EntityType {
+	case model.WorkflowInterimStatement:
+		parent = &model.InterimStatement{}
+	case model.WorkflowVariationOrder:
+		parent = &model.VariationOrder{}
+	case model.WorkflowExtensionOfTime:
+		parent = &model.ExtensionOfTime{}
+	}
+	if evt.ContractID == nil && parent != nil {
+		var ids []uuid.UUID
+		if err := tx.Model(parent).Where("id = ?", e.EntityID).Pluck("contract_id", &ids).Error; err != nil {
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if len(ids) == 1 {
+			evt.ContractID = &ids[0]
+		}
+	}
+	payload, err := json.Marshal(evt)
+	if err == nil && len(payload) > liveMaxPayload {
+		evt.Data = nil
+		payload, err = json.Marshal(evt)
+	}
+	if err != nil {
+		return &ServiceError{Message: "Failed to encode live event", Code: 500}
+	}
+	if err := tx.Exec("SELECT pg_notify(?, ?)", liveChannel, string(payload)).Error; err != nil {
+		return &ServiceError{Message: "Failed to publish live event", Code: 500}
+	}
+	return nil
+}
+
+// LiveFilter scopes a live subscription to a company, optionally narrowed
+// to one entity type or one entity. Following a contract includes the
+// events of its statements, variation orders, extensions of time and
+// payments.
+type LiveFilter struct {
+	CompanyID  uuid.UUID
+	EntityType string
+	EntityID   *uuid.UUID
+	// Amounts keeps the Data of statement and payment events; without it
+	// they are sent bare and only say that something changed.
+	Amounts bool
+}
+
+func (f LiveFilter) match(e *LiveEvent) bool {
+	if e.CompanyID != f.CompanyID {
+		return false
+	}
+	if f.EntityID != nil {
+		if e.EntityID == *f.EntityID {
+			return true
+		}
+		return f.EntityType == model.WorkflowContract && e.ContractID != nil && *e.ContractID == *f.EntityID
+	}
+	return f.EntityType == "" || e.EntityType == f.EntityType
+}
+
+// view returns e as the subscriber may see it.
+func (f LiveFilter) view(e LiveEvent) LiveEvent {
+	if !f.Amounts && liveAmountEvents[e.Type] {
+		e.Data = nil
+	}
+	return e
+}
+
+// LiveSubscription receives the events of one client. Events are dropped
+// when the client falls liveClientBuffer events behind.
+type LiveSubscription struct {
+	C      <-chan LiveEvent
+	ch     chan LiveEvent
+	filter LiveFilter
+}
+
+// LiveHub fans the live channel out to the clients connected to this API
+// instance. Every instance runs its own hub, so an event committed on one
+// reaches clients on all of them.
+type LiveHub struct {
+	mu   sync.RWMutex
+	subs map[*LiveSubscription]struct{}
+	done chan struct{}
+}
+
+func NewLiveHub() *LiveHub {
+	return &LiveHub{subs: make(map[*LiveSubscription]struct{}), done: make(chan struct{})}
+}
+
+func (h *LiveHub) Subscribe(f LiveFilter) *LiveSubscription {
+	ch := make(chan LiveEvent, liveClientBuffer)
+	sub := &LiveSubscription{C: ch, ch: ch, filter: f}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *LiveHub) Unsubscribe(sub *LiveSubscription) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+// Done is closed when the hub stops; streams should end then.
+func (h *LiveHub) Done() <-chan struct{} { return h.done }
+
+func (h *LiveHub) dispatch(e *LiveEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if !sub.filter.match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- sub.filter.view(*e):
+		default:
+		}
+	}
+}
+
+// resync sends live.resync to every client, whatever its filter.
+func (h *LiveHub) resync() {
+	e := LiveEvent{Type: LiveResync, OccurredAt: time.Now()}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// Run listens on the live channel with its own connection (dsn) and
+// dispatches every notification until ctx is cancelled.
+func (h *LiveHub) Run(ctx context.Context, dsn string) {
+	defer close(h.done)
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("[live] listener: %v", err)
+		}
+	})
+	defer listener.Close()
+	// On failure the listener keeps retrying and re-issues LISTEN once connected.
+	if err := listener.Listen(liveChannel); err != nil {
+		log.Printf("[live] listen failed: %v", err)
+	}
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			if n == nil {
+				// Reconnected; notifications in between are lost, so tell
+				// every client to refetch.
+				h.resync()
+				continue
+			}
+			var e LiveEvent
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Printf("[live] bad payload: %v", err)
+				continue
+			}
+			h.dispatch(&e)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// ─── Stream tickets ───────────────────────────────────────────────────────────
+
+// LiveTicketService issues the single-use tickets that open the live stream,
+// so no long-lived bearer token ends up in URLs and access logs. Tickets are
+// stored in the database and work on every API instance.
+type LiveTicketService struct {
+	db *gorm.DB
+}
+
+func NewLiveTicketService(db *gorm.DB) *LiveTicketService {
+	return &LiveTicketService{db: db}
+}
+
+func hashLiveTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a ticket for the caller that expires after liveTicketTTL.
+// Expired tickets are swept on the way.
+func (s *LiveTicketService) Issue(ctx context.Context, userID, companyID string, roles []string) (string, time.Time, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return "", time.Time{}, &ServiceError{Message: "Invalid user ID", Code: 400}
+	}
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return "", time.Time{}, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, &ServiceError{Message: "Failed to generate ticket", Code: 500}
+	}
+	ticket := hex.EncodeToString(b)
+
+	db := s.db.WithContext(ctx)
+	if err := db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.LiveTicket{}).Error; err != nil {
+		return "", time.Time{}, dbErr(err)
+	}
+	t := model.LiveTicket{
+		UserID:    uid,
+		CompanyID: cid,
+		Roles:     roles,
+		TokenHash: hashLiveTicket(ticket),
+		ExpiresAt: time.Now().Add(liveTicketTTL),
+	}
+	if err := db.Create(&t).Error; err != nil {
+		return "", time.Time{}, dbErr(err)
+	}
+	return ticket, t.ExpiresAt, nil
+}
+
+// Redeem consumes ticket and returns what it was issued for. A ticket works
+// once: it is deleted in the same statement that reads it.
+func (s *LiveTicketService) Redeem(ctx context.Context, ticket string) (*model.LiveTicket, error) {
+	if ticket == "" {
+		return nil, &ServiceError{Message: "Missing ticket", Code: 401}
+	}
+	var rows []model.LiveTicket
+	err := s.db.WithContext(ctx).Unscoped().
+		Clauses(clause.Returning{}).
+		Where("token_hash = ?", hashLiveTicket(ticket)).
+		Delete(&rows).Error
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	if len(rows) != 1 || !rows[0].ExpiresAt.After(time.Now()) {
+		return nil, &ServiceError{Message: "Invalid or expired ticket", Code: 401}
+	}
+	return &rows[0], nil
+}
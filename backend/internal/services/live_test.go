@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCanSeeLiveAmounts(t *testing.T) {
+	cases := []struct {
+		roles []string
+		want  bool
+	}{
+		{[]string{"finance_head"}, true},
+		{[]string{"admin"}, true},
+		{[]string{"engineering", "manager"}, true},
+		{[]string{"engineering"}, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := CanSeeLiveAmounts(c.roles); got != c.want {
+			t.Errorf("CanSeeLiveAmounts(%v) = %v, want %v", c.roles, got, c.want)
+		}
+	}
+}
+
+func TestLiveFilterViewHidesAmounts(t *testing.T) {
+	amounts := map[string]any{"net_amount": "1000"}
+	status := map[string]any{"to_status": "approved"}
+	cases := []struct {
+		typ     string
+		data    any
+		amounts bool
+		kept    bool
+	}{
+		{EventStatementRecomputed, amounts, false, false},
+		{EventPaymentRecorded, amounts, false, false},
+		{EventPaymentVoided, amounts, false, false},
+		{EventStatementRecomputed, amounts, true, true},
+		{EventStatementApproved, status, false, true},
+	}
+	for _, c := range cases {
+		f := LiveFilter{CompanyID: uuid.New(), Amounts: c.amounts}
+		got := f.view(LiveEvent{Type: c.typ, Data: c.data})
+		if (got.Data != nil) != c.kept {
+			t.Errorf("%s amounts=%v: data kept = %v, want %v", c.typ, c.amounts, got.Data != nil, c.kept)
+		}
+	}
+}
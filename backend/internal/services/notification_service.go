@@ -0,0 +1,403 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+)
+
+// NotificationService serves the caller's in-app inbox. Notifications are
+// written by notifyDomainEvent.
+type NotificationService struct{ db *gorm.DB }
+
+func NewNotificationService(db *gorm.DB) *NotificationService { return &NotificationService{db: db} }
+
+func (s *NotificationService) List(ctx context.Context, recipientID uuid.UUID, unreadOnly bool, page, limit int) ([]model.Notification, int64, error) {
+	q := s.db.WithContext(ctx).Model(&model.Notification{}).Where("recipient_id = ?", recipientID)
+	if unreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	var out []model.Notification
+	if err := q.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&out).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, total, nil
+}
+
+func (s *NotificationService) UnreadCount(ctx context.Context, recipientID uuid.UUID) (int64, error) {
+	var n int64
+	if err := s.db.WithContext(ctx).Model(&model.Notification{}).
+		Where("recipient_id = ? AND read_at IS NULL", recipientID).
+		Count(&n).Error; err != nil {
+		return 0, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	return n, nil
+}
+
+// MarkRead marks one of the recipient's notifications read.
+func (s *NotificationService) MarkRead(ctx context.Context, recipientID uuid.UUID, id string) (*model.Notification, error) {
+	nid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid notification ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	var n model.Notification
+	if err := db.First(&n, "id = ? AND recipient_id = ?", nid, recipientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Notification not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if n.ReadAt == nil {
+		now := time.Now()
+		if err := db.Model(&n).Update("read_at", now).Error; err != nil {
+			return nil, &ServiceError{Message: "Update failed", Code: 500}
+		}
+		n.ReadAt = &now
+	}
+	return &n, nil
+}
+
+// MarkAllRead marks every unread notification of the recipient read.
+func (s *NotificationService) MarkAllRead(ctx context.Context, recipientID uuid.UUID) (int64, error) {
+	res := s.db.WithContext(ctx).Model(&model.Notification{}).
+		Where("recipient_id = ? AND read_at IS NULL", recipientID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		return 0, &ServiceError{Message: "Update failed", Code: 500}
+	}
+	return res.RowsAffected, nil
+}
+
+// ------------------------------------------------------------
+// Domain-event hook
+// ------------------------------------------------------------
+
+// Notice kinds, i.e. why a recipient is told about an event.
+const (
+	NoticeApprovalNeeded = "approval_needed" // the document waits in the recipient's stage
+	NoticeDecision       = "decision"        // the recipient's document moved on, was rejected or approved
+	NoticeDocument       = "document"        // a file was attached to a document they handle
+	NoticeFinance        = "finance"         // retention released, damages waived
+	NoticeEscalation     = "escalation"      // an approval overstayed its SLA
+)
+
+// notice is one recipient's view of a domain event.
+type notice struct {
+	RecipientID uuid.UUID
+	Kind        string
+	Label       string // e.g. "Statement #3 of contract C-101"
+	LabelFa     string
+	Stage       string // status the document is in
+	Action      string // workflow step action that moved it there, for decisions
+	Comment     string
+	Title       string
+	Body        string
+}
+
+// docInfo is what notices need to know about a statement or contract.
+type docInfo struct {
+	Label     string
+	LabelFa   string
+	Status    string
+	ProjectID *uuid.UUID
+	Pinned    *uuid.UUID
+	CreatedBy *uuid.UUID
+}
+
+func loadDocInfo(tx *gorm.DB, entityType string, id uuid.UUID) (*docInfo, error) {
+	var row struct {
+		SequenceNo           int
+		ContractNo           string
+		Reference            string
+		Status               string
+		ProjectID            *uuid.UUID
+		WorkflowDefinitionID *uuid.UUID
+		CreatedByID          *uuid.UUID
+	}
+	var err error
+	switch entityType {
+	case model.WorkflowInterimStatement:
+		err = tx.Table("interim_statements s").
+			Select("s.sequence_no, c.contract_no, s.status, c.project_id, s.workflow_definition_id").
+			Joins("JOIN contracts c ON c.id = s.contract_id").
+			Where("s.id = ?", id).Take(&row).Error
+	case model.WorkflowContract:
+		err = tx.Table("contracts").
+			Select("contract_no, status, project_id, workflow_definition_id, created_by_id").
+			Where("id = ?", id).Take(&row).Error
+	case model.WorkflowVariationOrder:
+		err = tx.Table("variation_orders v").
+			Select("v.reference, c.contract_no, v.status, v.project_id, v.workflow_definition_id, v.created_by_id").
+			Joins("JOIN contracts c ON c.id = v.contract_id").
+			Where("v.id = ?", id).Take(&row).Error
+	case model.WorkflowExtensionOfTime:
+		err = tx.Table("extensions_of_time x").
+			Select("x.reference, c.contract_no, x.status, x.project_id, x.workflow_definition_id, x.created_by_id").
+			Joins("JOIN contracts c ON c.id = x.contract_id").
+			Where("x.id = ?", id).Take(&row).Error
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	info := &docInfo{Status: row.Status, ProjectID: row.ProjectID, Pinned: row.WorkflowDefinitionID, CreatedBy: row.CreatedByID}
+	switch entityType {
+	case model.WorkflowInterimStatement:
+		info.Label = fmt.Sprintf("Statement #%d of contract %s", row.SequenceNo, row.ContractNo)
+		info.LabelFa = fmt.Sprintf("صورت وضعیت شماره %d قرارداد %s", row.SequenceNo, row.ContractNo)
+	case model.WorkflowVariationOrder:
+		info.Label = fmt.Sprintf("Variation order %s of contract %s", row.Reference, row.ContractNo)
+		info.LabelFa = fmt.Sprintf("دستور تغییر %s قرارداد %s", row.Reference, row.ContractNo)
+	case model.WorkflowExtensionOfTime:
+		info.Label = fmt.Sprintf("Extension of time %s of contract %s", row.Reference, row.ContractNo)
+		info.LabelFa = fmt.Sprintf("تمدید مدت %s قرارداد %s", row.Reference, row.ContractNo)
+	default:
+		info.Label = "Contract " + row.ContractNo
+		info.LabelFa = "قرارداد " + row.ContractNo
+	}
+	return info, nil
+}
+
+// stageRecipients returns who can act on a document waiting in stage: the
+// holders of the stage's positions, active employees with its roles (admin
+// excluded) and the active delegates of both.
+func stageRecipients(tx *gorm.DB, companyID uuid.UUID, entityType string, entityID uuid.UUID, doc *docInfo, stage string) ([]uuid.UUID, error) {
+	def, err := workflowFor(tx, companyID, entityType, stage, doc.Pinned)
+	if err != nil {
+		return nil, err
+	}
+	var out []uuid.UUID
+	var roles []string
+	for _, st := range def.Steps {
+		if st.From != stage {
+			continue
+		}
+		for _, p := range st.Positions {
+			id, err := positionHolder(tx, companyID, doc.ProjectID, p)
+			if err != nil {
+				return nil, err
+			}
+			if id != nil {
+				out = append(out, *id)
+			}
+		}
+		for _, r := range st.Roles {
+			if r != string(model.RoleAdmin) {
+				roles = append(roles, r)
+			}
+		}
+	}
+	for _, r := range roles {
+		var ids []uuid.UUID
+		if err := tx.Model(&model.Employee{}).
+			Where("company_id = ? AND active AND ? = ANY(roles)", companyID, r).
+			Pluck("id", &ids).Error; err != nil {
+			return nil, &ServiceError{Message: "Database error", Code: 500}
+		}
+		out = append(out, ids...)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	var delegates []uuid.UUID
+	q := tx.Model(&model.ApprovalDelegation{}).
+		Where("company_id = ? AND delegator_id IN ? AND revoked_at IS NULL", companyID, out).
+		Where("starts_on <= CURRENT_DATE AND ends_on >= CURRENT_DATE").
+		Where("entity_type = '' OR entity_type = ?", entityType).
+		Where("entity_id IS NULL OR entity_id = ?", entityID)
+	if doc.ProjectID != nil {
+		q = q.Where("project_id IS NULL OR project_id = ?", *doc.ProjectID)
+	} else {
+		q = q.Where("project_id IS NULL")
+	}
+	if err := q.Pluck("delegate_id", &delegates).Error; err != nil {
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return append(out, delegates...), nil
+}
+
+// documentOwner is whoever last submitted the document out of draft, falling
+// back to its creator.
+func documentOwner(tx *gorm.DB, entityType string, entityID uuid.UUID, doc *docInfo) (*uuid.UUID, error) {
+	var evt model.ApprovalEvent
+	err := tx.Select("actor_id").
+		Where("entity_type = ? AND entity_id = ? AND from_status = 'draft'", entityType, entityID).
+		Order("created_at DESC").Take(&evt).Error
+	switch {
+	case err == nil:
+		return &evt.ActorID, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return doc.CreatedBy, nil
+	}
+	return nil, &ServiceError{Message: "Database error", Code: 500}
+}
+
+// approvalNotices tells the approvers of the stage a document moved to that
+// it awaits them, and its owner what happened. A rejection reaches the owner
+// as a decision even when they are also the one to reopen the document.
+func approvalNotices(doc *docInfo, a *model.ApprovalEvent, owner *uuid.UUID, approvers []uuid.UUID) []notice {
+	to, comment := a.ToStatus, a.Comment
+	need := notice{
+		Kind: NoticeApprovalNeeded, Label: doc.Label, LabelFa: doc.LabelFa, Stage: to, Comment: comment,
+		Title: fmt.Sprintf("%s awaits your action (%s)", doc.Label, to),
+		Body:  comment,
+	}
+	decision := notice{
+		Kind: NoticeDecision, Label: doc.Label, LabelFa: doc.LabelFa, Stage: to, Action: a.Action, Comment: comment,
+		Title: fmt.Sprintf("%s moved from %s to %s", doc.Label, a.FromStatus, to),
+		Body:  comment,
+	}
+
+	// Everyone gets one notice; the first one added wins.
+	var out []notice
+	seen := map[uuid.UUID]bool{a.ActorID: true}
+	add := func(id uuid.UUID, n notice) {
+		if !seen[id] {
+			seen[id] = true
+			n.RecipientID = id
+			out = append(out, n)
+		}
+	}
+	if owner != nil && a.Action == "reject" {
+		add(*owner, decision)
+	}
+	for _, id := range approvers {
+		add(id, need)
+	}
+	if owner != nil {
+		add(*owner, decision)
+	}
+	return out
+}
+
+// noticesFor resolves who should hear about e, and what to tell them.
+func noticesFor(tx *gorm.DB, e *DomainEvent) ([]notice, error) {
+	var out []notice
+	seen := map[uuid.UUID]bool{e.ActorID: true}
+	add := func(ids []uuid.UUID, n notice) {
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			n.RecipientID = id
+			out = append(out, n)
+		}
+	}
+
+	switch {
+	case e.Approval != nil && workflowGoal[e.EntityType] != "":
+		doc, err := loadDocInfo(tx, e.EntityType, e.EntityID)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		owner, err := documentOwner(tx, e.EntityType, e.EntityID, doc)
+		if err != nil {
+			return nil, err
+		}
+		var approvers []uuid.UUID
+		if to := e.Approval.ToStatus; to != string(model.StatementDraft) {
+			if approvers, err = stageRecipients(tx, e.CompanyID, e.EntityType, e.EntityID, doc, to); err != nil {
+				return nil, err
+			}
+		}
+		for _, n := range approvalNotices(doc, e.Approval, owner, approvers) {
+			add([]uuid.UUID{n.RecipientID}, n)
+		}
+
+	case e.Approval != nil:
+		// Retention releases and damage waivers concern finance.
+		head, err := positionHolder(tx, e.CompanyID, nil, model.PositionFinancialHead)
+		if err != nil || head == nil {
+			return nil, err
+		}
+		title := "Retention released"
+		if e.Type == EventDamageWaived {
+			title = "Liquidated damage waived"
+		}
+		add([]uuid.UUID{*head}, notice{Kind: NoticeFinance, Stage: e.Approval.ToStatus, Comment: e.Approval.Comment, Title: title, Body: e.Approval.Comment})
+
+	case e.Attachment != nil:
+		doc, err := loadDocInfo(tx, e.EntityType, e.EntityID)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		ids, err := stageRecipients(tx, e.CompanyID, e.EntityType, e.EntityID, doc, doc.Status)
+		if err != nil {
+			return nil, err
+		}
+		if doc.CreatedBy != nil {
+			ids = append(ids, *doc.CreatedBy)
+		}
+		add(ids, notice{
+			Kind: NoticeDocument, Label: doc.Label, LabelFa: doc.LabelFa, Stage: doc.Status,
+			Title: fmt.Sprintf("New document on %s: %s", doc.Label, e.Attachment.FileName),
+		})
+
+	case e.Escalation != nil:
+		doc, err := loadDocInfo(tx, e.EntityType, e.EntityID)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		var ids []uuid.UUID
+		if e.Escalation.EscalatedToID != nil {
+			ids = append(ids, *e.Escalation.EscalatedToID)
+		}
+		if e.Escalation.DelegationID != nil {
+			var d model.ApprovalDelegation
+			if err := tx.Select("delegate_id").First(&d, "id = ?", *e.Escalation.DelegationID).Error; err != nil {
+				return nil, &ServiceError{Message: "Database error", Code: 500}
+			}
+			ids = append(ids, d.DelegateID)
+		}
+		add(ids, notice{
+			Kind: NoticeEscalation, Label: doc.Label, LabelFa: doc.LabelFa, Stage: e.Escalation.Stage,
+			Title: fmt.Sprintf("Overdue: %s has been in %s since %s", doc.Label, e.Escalation.Stage, e.Escalation.EnteredAt.Format("2006-01-02 15:04")),
+		})
+	}
+	return out, nil
+}
+
+// notifyDomainEvent writes an in-app notification per recipient of e.
+func notifyDomainEvent(tx *gorm.DB, e *DomainEvent) error {
+	notices, err := e.recipients(tx)
+	if err != nil || len(notices) == 0 {
+		return err
+	}
+	var actor *uuid.UUID
+	if e.ActorID != uuid.Nil {
+		id := e.ActorID
+		actor = &id
+	}
+	rows := make([]model.Notification, 0, len(notices))
+	for _, n := range notices {
+		rows = append(rows, model.Notification{
+			CompanyID:   e.CompanyID,
+			RecipientID: n.RecipientID,
+			Type:        e.Type,
+			Title:       n.Title,
+			Body:        n.Body,
+			EntityType:  e.EntityType,
+			EntityID:    e.EntityID,
+			ActorID:     actor,
+		})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return &ServiceError{Message: "Failed to write notifications", Code: 500}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+)
+
+func TestApprovalNotices(t *testing.T) {
+	doc := &docInfo{Label: "Statement #3 of contract C-101"}
+	owner, head, engineer, actor := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	type want struct {
+		id    uuid.UUID
+		kind  string
+		email string
+	}
+	cases := []struct {
+		name      string
+		a         model.ApprovalEvent
+		owner     *uuid.UUID
+		approvers []uuid.UUID
+		want      []want
+	}{
+		{
+			// The project lead who submitted is also who reopens a rejected
+			// statement; they must still hear it was rejected.
+			name:      "rejection reaches an owner who can reopen",
+			a:         model.ApprovalEvent{ActorID: actor, FromStatus: "submitted", ToStatus: "rejected", Action: "reject", Comment: "wrong quantities"},
+			owner:     &owner,
+			approvers: []uuid.UUID{owner, engineer},
+			want: []want{
+				{owner, NoticeDecision, emailRejected},
+				{engineer, NoticeApprovalNeeded, emailApprovalNeeded},
+			},
+		},
+		{
+			name:      "approval: approvers first, owner told of progress",
+			a:         model.ApprovalEvent{ActorID: actor, FromStatus: "submitted", ToStatus: "finance_review", Action: "approve"},
+			owner:     &owner,
+			approvers: []uuid.UUID{head},
+			want: []want{
+				{head, NoticeApprovalNeeded, emailApprovalNeeded},
+				{owner, NoticeDecision, ""},
+			},
+		},
+		{
+			name:      "an owner who approves next gets the action request",
+			a:         model.ApprovalEvent{ActorID: actor, FromStatus: "finance_review", ToStatus: "pm_review", Action: "approve"},
+			owner:     &owner,
+			approvers: []uuid.UUID{owner},
+			want:      []want{{owner, NoticeApprovalNeeded, emailApprovalNeeded}},
+		},
+		{
+			name:      "the actor is never notified",
+			a:         model.ApprovalEvent{ActorID: owner, FromStatus: "draft", ToStatus: "submitted", Action: "submit"},
+			owner:     &owner,
+			approvers: []uuid.UUID{head, owner},
+			want:      []want{{head, NoticeApprovalNeeded, emailApprovalNeeded}},
+		},
+		{
+			name:      "no owner",
+			a:         model.ApprovalEvent{ActorID: actor, FromStatus: "pending_finance", ToStatus: "draft", Action: "reject"},
+			approvers: nil,
+			want:      nil,
+		},
+	}
+	for _, c := range cases {
+		got := approvalNotices(doc, &c.a, c.owner, c.approvers)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: %d notices, want %d: %+v", c.name, len(got), len(c.want), got)
+			continue
+		}
+		for i, w := range c.want {
+			n := got[i]
+			if n.RecipientID != w.id || n.Kind != w.kind || emailKind(n) != w.email {
+				t.Errorf("%s: notice %d = %s/%s email %q, want %s/%s email %q",
+					c.name, i, n.RecipientID, n.Kind, emailKind(n), w.id, w.kind, w.email)
+			}
+		}
+	}
+}
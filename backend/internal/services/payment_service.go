@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentService records the money actually paid against approved statements.
+// Each statement caches its PaidAmount and PaymentStatus so listings can show
+// whether it is paid without loading the payments.
+type PaymentService struct{ db *gorm.DB }
+
+func NewPaymentService(db *gorm.DB) *PaymentService { return &PaymentService{db: db} }
+
+type RecordPaymentReq struct {
+	Amount    string `json:"amount"`
+	PaidOn    string `json:"paid_on"` // "2006-01-02"; defaults to today
+	Method    string `json:"method"`
+	Reference string `json:"reference"`
+	Notes     string `json:"notes"`
+}
+
+// StatementBalance is the payable / paid / outstanding position of one statement.
+type StatementBalance struct {
+	StatementID   uuid.UUID           `json:"statement_id"`
+	SequenceNo    int                 `json:"sequence_no"`
+	IsFinal       bool                `json:"is_final"`
+	Payable       decimal.Decimal     `json:"payable"`
+	Paid          decimal.Decimal     `json:"paid"`
+	Outstanding   decimal.Decimal     `json:"outstanding"`
+	PaymentStatus model.PaymentStatus `json:"payment_status"`
+}
+
+// StatementPayments is the payment history of a statement plus its balance.
+type StatementPayments struct {
+	StatementBalance
+	Payments []model.Payment `json:"payments"`
+}
+
+// ContractPayments is the payment position of every approved statement of a
+// contract plus contract totals.
+type ContractPayments struct {
+	Statements  []StatementBalance `json:"statements"`
+	Payments    []model.Payment    `json:"payments"`
+	Payable     decimal.Decimal    `json:"payable"`
+	Paid        decimal.Decimal    `json:"paid"`
+	Outstanding decimal.Decimal    `json:"outstanding"`
+}
+
+// paymentStatus classifies paid against the statement's net amount. A
+// statement with nothing payable counts as paid.
+func paymentStatus(net, paid decimal.Decimal) model.PaymentStatus {
+	switch {
+	case paid.GreaterThanOrEqual(net):
+		return model.PaymentPaid
+	case paid.IsPositive():
+		return model.PaymentPartiallyPaid
+	default:
+		return model.PaymentUnpaid
+	}
+}
+
+func statementBalance(stmt *model.InterimStatement) StatementBalance {
+	return StatementBalance{
+		StatementID:   stmt.ID,
+		SequenceNo:    stmt.SequenceNo,
+		IsFinal:       stmt.IsFinal,
+		Payable:       stmt.NetAmount,
+		Paid:          stmt.PaidAmount,
+		Outstanding:   decimal.Max(stmt.NetAmount.Sub(stmt.PaidAmount), decimal.Zero),
+		PaymentStatus: stmt.PaymentStatus,
+	}
+}
+
+func (s *PaymentService) ListByStatement(ctx context.Context, statementID string) (*StatementPayments, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	var stmt model.InterimStatement
+	if err := db.First(&stmt, "id = ?", sid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Statement not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	var payments []model.Payment
+	if err := db.Where("interim_statement_id = ?", sid).
+		Order("paid_on ASC, created_at ASC").
+		Find(&payments).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return &StatementPayments{StatementBalance: statementBalance(&stmt), Payments: payments}, nil
+}
+
+func (s *PaymentService) ListByContract(ctx context.Context, contractID string) (*ContractPayments, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	var ct model.Contract
+	if err := db.Select("id").First(&ct, "id = ?", cid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Contract not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	var stmts []model.InterimStatement
+	if err := db.Where("contract_id = ? AND status = ?", cid, model.StatementApproved).
+		Order("sequence_no ASC").
+		Find(&stmts).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	var payments []model.Payment
+	if err := db.Where("contract_id = ?", cid).
+		Order("paid_on ASC, created_at ASC").
+		Find(&payments).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+
+	out := &ContractPayments{Statements: make([]StatementBalance, 0, len(stmts)), Payments: payments}
+	for i := range stmts {
+		b := statementBalance(&stmts[i])
+		out.Statements = append(out.Statements, b)
+		out.Payable = out.Payable.Add(decimal.Max(b.Payable, decimal.Zero))
+		out.Paid = out.Paid.Add(b.Paid)
+		out.Outstanding = out.Outstanding.Add(b.Outstanding)
+	}
+	return out, nil
+}
+
+// Record books a (possibly partial) payment against an approved statement.
+// Paying more than the outstanding balance is rejected.
+func (s *PaymentService) Record(ctx context.Context, statementID string, req RecordPaymentReq, actorID uuid.UUID) (*model.Payment, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		return nil, &ServiceError{Message: "amount must be a positive number", Code: 400}
+	}
+	method := model.PaymentMethod(req.Method)
+	if !method.Valid() {
+		return nil, &ServiceError{Message: "method must be transfer, cheque or treasury_bond", Code: 400}
+	}
+	reference := strings.TrimSpace(req.Reference)
+	if method != model.PaymentTransfer && reference == "" {
+		return nil, &ServiceError{Message: "reference is required for cheque and treasury_bond payments", Code: 400}
+	}
+	paidOn := time.Now()
+	if req.PaidOn != "" {
+		t := parseDate(req.PaidOn)
+		if t == nil {
+			return nil, &ServiceError{Message: "Invalid paid_on (expected YYYY-MM-DD)", Code: 400}
+		}
+		paidOn = *t
+	}
+
+	var pmt model.Payment
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stmt model.InterimStatement
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stmt, "id = ?", sid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Statement not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if stmt.Status != model.StatementApproved {
+			return &ServiceError{Message: "Payments can only be recorded against approved statements", Code: 422}
+		}
+		outstanding := stmt.NetAmount.Sub(stmt.PaidAmount)
+		if !outstanding.IsPositive() {
+			return &ServiceError{Message: "Statement has no outstanding balance", Code: 409}
+		}
+		if amount.GreaterThan(outstanding) {
+			return &ServiceError{Message: "amount exceeds the outstanding balance of " + outstanding.String(), Code: 422}
+		}
+
+		pmt = model.Payment{
+			InterimStatementID: stmt.ID,
+			ContractID:         stmt.ContractID,
+			Amount:             amount,
+			CurrencyCode:       stmt.Currency,
+			PaidOn:             paidOn,
+			Method:             method,
+			Reference:          reference,
+			Notes:              req.Notes,
+			CreatedByID:        actorID,
+		}
+		if err := tx.Create(&pmt).Error; err != nil {
+			return dbErr(err)
+		}
+		if err := syncPaidAmount(tx, &stmt); err != nil {
+			return err
+		}
+		return publishPayment(tx, EventPaymentRecorded, &stmt, &pmt, actorID)
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &pmt, nil
+}
+
+// Delete voids a payment recorded in error and restores the statement balance.
+func (s *PaymentService) Delete(ctx context.Context, paymentID string, actorID uuid.UUID) error {
+	pid, err := uuid.Parse(paymentID)
+	if err != nil {
+		return &ServiceError{Message: "Invalid payment ID", Code: 400}
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pmt model.Payment
+		if err := tx.First(&pmt, "id = ?", pid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Payment not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		var stmt model.InterimStatement
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stmt, "id = ?", pmt.InterimStatementID).Error; err != nil {
+			return &ServiceError{Message: "Statement not found", Code: 500}
+		}
+		if err := tx.Delete(&pmt).Error; err != nil {
+			return &ServiceError{Message: "Delete failed", Code: 500}
+		}
+		if err := syncPaidAmount(tx, &stmt); err != nil {
+			return err
+		}
+		return publishPayment(tx, EventPaymentVoided, &stmt, &pmt, actorID)
+	})
+}
+
+func publishPayment(tx *gorm.DB, eventType string, stmt *model.InterimStatement, pmt *model.Payment, actorID uuid.UUID) error {
+	return publish(tx, &DomainEvent{
+		Type:       eventType,
+		CompanyID:  stmt.CompanyID,
+		EntityType: model.WorkflowInterimStatement,
+		EntityID:   stmt.ID,
+		ActorID:    actorID,
+		Payment:    pmt,
+	})
+}
+
+// syncPaidAmount re-sums the payments of stmt into PaidAmount / PaymentStatus.
+func syncPaidAmount(tx *gorm.DB, stmt *model.InterimStatement) error {
+	var paid decimal.Decimal
+	if err := tx.Model(&model.Payment{}).
+		Where("interim_statement_id = ?", stmt.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		return &ServiceError{Message: "Query failed", Code: 500}
+	}
+	stmt.PaidAmount = paid
+	stmt.PaymentStatus = paymentStatus(stmt.NetAmount, paid)
+	if err := tx.Model(stmt).Updates(map[string]any{
+		"paid_amount":    stmt.PaidAmount,
+		"payment_status": stmt.PaymentStatus,
+	}).Error; err != nil {
+		return &ServiceError{Message: "Failed to update statement balance", Code: 500}
+	}
+	return nil
+}
@@ -0,0 +1,505 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PriceListService manages the official price list catalog (فهرست بها) and
+// prices contract line items from it.
+type PriceListService struct{ db *gorm.DB }
+
+func NewPriceListService(db *gorm.DB) *PriceListService { return &PriceListService{db: db} }
+
+type PriceListEditionReq struct {
+	Discipline string `json:"discipline"`
+	Year       int    `json:"year"` // Jalali, e.g. 1404
+	Title      string `json:"title"`
+	Source     string `json:"source"`
+}
+
+func (req PriceListEditionReq) validate() error {
+	if strings.TrimSpace(req.Discipline) == "" {
+		return errors.New("discipline is required")
+	}
+	if req.Year < 1300 || req.Year > 1500 {
+		return errors.New("year must be a Jalali year")
+	}
+	return nil
+}
+
+func (req PriceListEditionReq) title() string {
+	if t := strings.TrimSpace(req.Title); t != "" {
+		return t
+	}
+	return fmt.Sprintf("فهرست بهای %s %d", strings.TrimSpace(req.Discipline), req.Year)
+}
+
+// priceListFields are the catalog attributes an import column can map to.
+var priceListFields = []importField{
+	{"code", true, []string{"code", "item_code", "کد", "شماره", "شماره ردیف", "شماره آیتم"}},
+	{"chapter", false, []string{"chapter", "فصل"}},
+	{"description", true, []string{"description", "شرح", "شرح ردیف", "شرح آیتم"}},
+	{"unit", false, []string{"unit", "واحد"}},
+	{"base_rate", false, []string{"base_rate", "rate", "unit_rate", "بها", "بهای واحد", "قیمت واحد", "فی"}},
+}
+
+// priceListItemUpsert overwrites an existing code of the edition.
+var priceListItemUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "edition_id"}, {Name: "code"}},
+	DoUpdates: clause.AssignmentColumns([]string{"chapter", "description", "unit", "base_rate", "updated_at"}),
+}
+
+type PriceListImportOptions struct {
+	Format    string            // "xlsx" or "csv"
+	Sheet     string            // xlsx only; defaults to the first sheet
+	HeaderRow int               // 1-based; defaults to 1
+	Mapping   map[string]string // field → column letter or header text
+}
+
+type PriceListImportResult struct {
+	Columns  map[string]string `json:"columns"`
+	Imported int               `json:"imported"`
+	Skipped  int               `json:"skipped"`
+}
+
+func (s *PriceListService) ListEditions(ctx context.Context, discipline string, year int) ([]model.PriceListEdition, error) {
+	q := s.db.WithContext(ctx).Model(&model.PriceListEdition{})
+	if discipline != "" {
+		q = q.Where("discipline = ?", discipline)
+	}
+	if year > 0 {
+		q = q.Where("year = ?", year)
+	}
+	var editions []model.PriceListEdition
+	if err := q.Order("discipline ASC, year DESC").Find(&editions).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	if len(editions) == 0 {
+		return editions, nil
+	}
+	ids := make([]uuid.UUID, len(editions))
+	for i, e := range editions {
+		ids[i] = e.ID
+	}
+	var counts []struct {
+		EditionID uuid.UUID
+		N         int64
+	}
+	if err := s.db.WithContext(ctx).Model(&model.PriceListItem{}).
+		Select("edition_id, COUNT(*) AS n").
+		Where("edition_id IN ?", ids).
+		Group("edition_id").Scan(&counts).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	byID := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		byID[c.EditionID] = c.N
+	}
+	for i := range editions {
+		editions[i].ItemCount = byID[editions[i].ID]
+	}
+	return editions, nil
+}
+
+func (s *PriceListService) GetEdition(ctx context.Context, id string) (*model.PriceListEdition, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid price list ID", Code: 400}
+	}
+	var ed model.PriceListEdition
+	if err := s.db.WithContext(ctx).First(&ed, "id = ?", uid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Price list not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if err := s.db.WithContext(ctx).Model(&model.PriceListItem{}).
+		Where("edition_id = ?", uid).Count(&ed.ItemCount).Error; err != nil {
+		return nil, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	return &ed, nil
+}
+
+func (s *PriceListService) CreateEdition(ctx context.Context, req PriceListEditionReq) (*model.PriceListEdition, error) {
+	if err := req.validate(); err != nil {
+		return nil, &ServiceError{Message: err.Error(), Code: 400}
+	}
+	ed := model.PriceListEdition{
+		Discipline: strings.TrimSpace(req.Discipline),
+		Year:       req.Year,
+		Title:      req.title(),
+		Source:     strings.TrimSpace(req.Source),
+	}
+	if err := s.db.WithContext(ctx).Create(&ed).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return &ed, nil
+}
+
+func (s *PriceListService) UpdateEdition(ctx context.Context, id string, req PriceListEditionReq) (*model.PriceListEdition, error) {
+	if err := req.validate(); err != nil {
+		return nil, &ServiceError{Message: err.Error(), Code: 400}
+	}
+	ed, err := s.GetEdition(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ed.Discipline = strings.TrimSpace(req.Discipline)
+	ed.Year = req.Year
+	ed.Title = req.title()
+	ed.Source = strings.TrimSpace(req.Source)
+	if err := s.db.WithContext(ctx).Select("discipline", "year", "title", "source").Updates(ed).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return ed, nil
+}
+
+// DeleteEdition hard-deletes the edition and its items so the discipline and
+// year can be reused. Line items priced from it keep their rates; only the
+// catalog link is cleared.
+func (s *PriceListService) DeleteEdition(ctx context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return &ServiceError{Message: "Invalid price list ID", Code: 400}
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("edition_id = ?", uid).Delete(&model.PriceListItem{}).Error; err != nil {
+			return &ServiceError{Message: "Delete failed", Code: 500}
+		}
+		result := tx.Unscoped().Where("id = ?", uid).Delete(&model.PriceListEdition{})
+		if result.Error != nil {
+			return &ServiceError{Message: "Delete failed", Code: 500}
+		}
+		if result.RowsAffected == 0 {
+			return &ServiceError{Message: "Price list not found", Code: 404}
+		}
+		return nil
+	})
+}
+
+// ListItems pages through an edition. search matches a code prefix or the
+// description.
+func (s *PriceListService) ListItems(ctx context.Context, editionID, search string, chapter, page, limit int) ([]model.PriceListItem, int64, error) {
+	eid, err := uuid.Parse(editionID)
+	if err != nil {
+		return nil, 0, &ServiceError{Message: "Invalid price list ID", Code: 400}
+	}
+	q := s.db.WithContext(ctx).Model(&model.PriceListItem{}).Where("edition_id = ?", eid)
+	if chapter > 0 {
+		q = q.Where("chapter = ?", chapter)
+	}
+	if search = strings.TrimSpace(search); search != "" {
+		q = q.Where("code LIKE ? OR description ILIKE ?", normalizeDigits(search)+"%", "%"+search+"%")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	var items []model.PriceListItem
+	if err := q.Order("code ASC").Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return items, total, nil
+}
+
+// ImportItems loads an edition's rows from .xlsx or CSV. Rows without a unit
+// or rate are chapter headings and are skipped. All-or-nothing, like the
+// other imports; codes already in the edition are overwritten.
+func (s *PriceListService) ImportItems(ctx context.Context, editionID string, r io.Reader, opts PriceListImportOptions) (*PriceListImportResult, error) {
+	ed, err := s.GetEdition(ctx, editionID)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := readImportSheet(r, opts.Format, opts.Sheet, opts.HeaderRow, opts.Mapping, priceListFields)
+	if err != nil {
+		return nil, err
+	}
+	result := &PriceListImportResult{Columns: sheet.columns}
+
+	var rowErrs []ImportRowError
+	var items []model.PriceListItem
+	codes := make(map[string]int)
+	for i := range sheet.rows {
+		line := sheet.line(i)
+		cell := func(field string) string { return sheet.cell(i, field) }
+		code, desc, unit, rateRaw := normalizeDigits(cell("code")), cell("description"), cell("unit"), cell("base_rate")
+		if code == "" && desc == "" && unit == "" && rateRaw == "" {
+			continue
+		}
+		if unit == "" && rateRaw == "" {
+			result.Skipped++
+			continue
+		}
+
+		var msgs []string
+		if code == "" {
+			msgs = append(msgs, "code is required")
+		} else if len(code) > 64 {
+			msgs = append(msgs, "code is longer than 64 characters")
+		} else if first, dup := codes[code]; dup {
+			msgs = append(msgs, fmt.Sprintf("code %s repeats line %d", code, first))
+		} else {
+			codes[code] = line
+		}
+		if desc == "" {
+			msgs = append(msgs, "description is required")
+		}
+		if unit == "" {
+			msgs = append(msgs, "unit is required")
+		} else if len(unit) > 32 {
+			msgs = append(msgs, "unit is longer than 32 characters")
+		}
+		rate, err := parseImportDecimal(rateRaw)
+		if err != nil || rate.IsNegative() {
+			msgs = append(msgs, fmt.Sprintf("invalid base_rate %q", rateRaw))
+		}
+		chapter, err := boqChapter(normalizeDigits(cell("chapter")), code)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+		if len(msgs) > 0 {
+			rowErrs = append(rowErrs, ImportRowError{Line: line, Message: strings.Join(msgs, "; ")})
+			continue
+		}
+		items = append(items, model.PriceListItem{
+			EditionID:   ed.ID,
+			Code:        code,
+			Chapter:     chapter,
+			Description: desc,
+			Unit:        unit,
+			BaseRate:    rate,
+		})
+	}
+	if len(rowErrs) > 0 {
+		return nil, &ServiceError{
+			Message: fmt.Sprintf("%d invalid row(s); nothing imported", len(rowErrs)),
+			Code:    422,
+			Errors:  rowErrs,
+		}
+	}
+	if len(items) == 0 {
+		return nil, &ServiceError{Message: "The file contains no price list items", Code: 400}
+	}
+	if err := s.db.WithContext(ctx).Clauses(priceListItemUpsert).CreateInBatches(&items, 500).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	result.Imported = len(items)
+	return result, nil
+}
+
+type CatalogLineReq struct {
+	Code      string `json:"code"`
+	Quantity  string `json:"quantity"`
+	SortOrder int    `json:"sort_order"`
+}
+
+type CreateCatalogLinesReq struct {
+	EditionID string           `json:"edition_id"`
+	ParentID  string           `json:"parent_id"` // optional; otherwise nested under the group with the longest matching code
+	Items     []CatalogLineReq `json:"items"`
+}
+
+// CatalogLinesResult reports the lines created. Total is Σ quantity ×
+// unit_rate at catalog prices; ContractTotal applies the contract coefficient
+// the way works done are paid.
+type CatalogLinesResult struct {
+	BOQVersion    string                   `json:"boq_version,omitempty"`
+	Total         decimal.Decimal          `json:"total"`
+	ContractTotal decimal.Decimal          `json:"contract_total"`
+	Items         []model.ContractLineItem `json:"items"`
+}
+
+// CreateLineItems adds one contract line per picked catalog code, copying its
+// description, unit, chapter and base rate. The rate is stored before the
+// contract coefficient, like every line item; the coefficient is applied in
+// the budget guard and when works done are priced. All-or-nothing: every
+// unknown or already-used code is reported.
+func (s *PriceListService) CreateLineItems(ctx context.Context, contractID string, req CreateCatalogLinesReq) (*CatalogLinesResult, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	eid, err := uuid.Parse(req.EditionID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid edition_id", Code: 400}
+	}
+	if len(req.Items) == 0 {
+		return nil, &ServiceError{Message: "items is required", Code: 400}
+	}
+
+	result := &CatalogLinesResult{Total: decimal.Zero}
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ct model.Contract
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, contractor_id, project_id, type, currency, gross_budget, contract_coefficient, boq_version").
+			First(&ct, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		var ed model.PriceListEdition
+		if err := tx.First(&ed, "id = ?", eid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Price list not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		parent, err := lineItemParent(tx, cid, req.ParentID)
+		if err != nil {
+			return err
+		}
+
+		codes := make([]string, len(req.Items))
+		for i, it := range req.Items {
+			codes[i] = normalizeDigits(strings.TrimSpace(it.Code))
+		}
+		var catalog []model.PriceListItem
+		if err := tx.Where("edition_id = ? AND code IN ?", eid, codes).Find(&catalog).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+		byCode := make(map[string]model.PriceListItem, len(catalog))
+		for _, c := range catalog {
+			byCode[c.Code] = c
+		}
+		var taken []string
+		if err := tx.Model(&model.ContractLineItem{}).
+			Where("contract_id = ? AND item_code IN ?", cid, codes).
+			Pluck("item_code", &taken).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+		used := make(map[string]bool, len(taken))
+		for _, c := range taken {
+			used[c] = true
+		}
+		var groups []model.ContractLineItem
+		if parent == nil {
+			if err := tx.Select("id, item_code, chapter").
+				Where("contract_id = ? AND is_group AND item_code <> ''", cid).
+				Find(&groups).Error; err != nil {
+				return &ServiceError{Message: "Query failed", Code: 500}
+			}
+		}
+		var maxSort int
+		if err := tx.Model(&model.ContractLineItem{}).
+			Select("COALESCE(MAX(sort_order), 0)").
+			Where("contract_id = ?", cid).Scan(&maxSort).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+
+		currency := ct.Currency
+		if len(currency) != 3 {
+			currency = "IRR"
+		}
+		var rowErrs []ImportRowError
+		items := make([]model.ContractLineItem, 0, len(req.Items))
+		for i, it := range req.Items {
+			line, code := i+1, codes[i]
+			entry, ok := byCode[code]
+			if !ok {
+				rowErrs = append(rowErrs, ImportRowError{Line: line, Message: fmt.Sprintf("code %q is not in %s", it.Code, ed.Title)})
+				continue
+			}
+			if used[code] {
+				rowErrs = append(rowErrs, ImportRowError{Line: line, Message: fmt.Sprintf("item_code %s already exists on the contract", code)})
+				continue
+			}
+			used[code] = true
+			qty, err := decimal.NewFromString(normalizeDigits(strings.TrimSpace(it.Quantity)))
+			if err != nil || qty.IsNegative() {
+				rowErrs = append(rowErrs, ImportRowError{Line: line, Message: fmt.Sprintf("invalid quantity %q", it.Quantity)})
+				continue
+			}
+			lineParent := parent
+			if lineParent != nil {
+				if _, err := nestedItemCode(tx, lineParent, code); err != nil {
+					rowErrs = append(rowErrs, ImportRowError{Line: line, Message: err.Error()})
+					continue
+				}
+			} else {
+				for gi := range groups {
+					g := &groups[gi]
+					if len(g.ItemCode) < len(code) && strings.HasPrefix(code, g.ItemCode) &&
+						(lineParent == nil || len(g.ItemCode) > len(lineParent.ItemCode)) {
+						lineParent = g
+					}
+				}
+			}
+			sortOrder := it.SortOrder
+			if sortOrder == 0 {
+				sortOrder = maxSort + i + 1
+			}
+			items = append(items, model.ContractLineItem{
+				ContractID:      cid,
+				ContractorID:    &ct.ContractorID,
+				ProjectID:       &ct.ProjectID,
+				ParentID:        lineItemID(lineParent),
+				SortOrder:       sortOrder,
+				ItemCode:        code,
+				Chapter:         entry.Chapter,
+				Description:     entry.Description,
+				Unit:            entry.Unit,
+				Quantity:        qty,
+				UnitRate:        entry.BaseRate,
+				CurrencyCode:    currency,
+				PriceListItemID: &entry.ID,
+			})
+			result.Total = result.Total.Add(qty.Mul(entry.BaseRate))
+		}
+
+		if len(rowErrs) == 0 && ct.GrossBudget.IsPositive() {
+			var existingSum decimal.Decimal
+			if err := tx.Raw(
+				"SELECT COALESCE(SUM(quantity * unit_rate), 0) FROM contract_line_items WHERE contract_id = ? AND deleted_at IS NULL", cid,
+			).Scan(&existingSum).Error; err != nil {
+				return &ServiceError{Message: "Query failed", Code: 500}
+			}
+			if projected := contractRate(&ct, existingSum.Add(result.Total)); projected.GreaterThan(ct.GrossBudget) {
+				return &ServiceError{
+					Message: fmt.Sprintf(
+						"جمع آیتم‌های WBS (%.0f) از مبلغ قرارداد (%.0f) بیشتر می‌شود",
+						projected.InexactFloat64(), ct.GrossBudget.InexactFloat64(),
+					),
+					Code: 422,
+				}
+			}
+		}
+		if len(rowErrs) > 0 {
+			return &ServiceError{
+				Message: fmt.Sprintf("%d invalid item(s); nothing created", len(rowErrs)),
+				Code:    422,
+				Errors:  rowErrs,
+			}
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			return dbErr(err)
+		}
+
+		// A contract priced from the catalog records the edition as its BOQ
+		// version unless one was set already.
+		result.BOQVersion = ct.BOQVersion
+		if result.BOQVersion == "" {
+			result.BOQVersion = ed.Title
+			if err := tx.Model(&ct).Update("boq_version", ed.Title).Error; err != nil {
+				return &ServiceError{Message: "Failed to set boq_version", Code: 500}
+			}
+		}
+		result.ContractTotal = contractRate(&ct, result.Total)
+		result.Items = items
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return result, nil
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-fonts/dejavu/dejavusans"
+	"github.com/go-fonts/dejavu/dejavusansbold"
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+)
+
+// ─── PDF builder ──────────────────────────────────────────────────────────────
+//
+// The PDF mirrors buildExcel section by section on A4 portrait. Columns A–E
+// keep the workbook's relative widths and run right to left, so column A is
+// the rightmost one as in the right-to-left sheet.
+
+const (
+	pdfFont    = "dejavu"
+	pdfMargin  = 10.0
+	pdfPadding = 1.2
+	ptToMM     = 25.4 / 72
+)
+
+// pdfStyle is the PDF counterpart of an Excel cell style.
+type pdfStyle struct {
+	fill   string
+	color  string
+	bold   bool
+	size   float64
+	align  string // "R", "C" or "L"
+	top    bool   // vertical align top instead of centre
+	border string
+	thick  bool
+}
+
+var pdfStyles = struct {
+	title, sectionHdr, tableHdr, label, value, data, dataNum,
+	summaryLbl, summaryAmt, summaryNot, signLabel, signBox pdfStyle
+}{
+	title:      pdfStyle{fill: "1B3A6B", color: "FFFFFF", bold: true, size: 13, align: "C", border: "1B3A6B", thick: true},
+	sectionHdr: pdfStyle{fill: "2B6CB0", color: "FFFFFF", bold: true, size: 11, align: "R", border: "8EA8C3"},
+	tableHdr:   pdfStyle{fill: "BEE3F8", color: "1B3A6B", bold: true, size: 10, align: "C", border: "8EA8C3"},
+	label:      pdfStyle{fill: "EDF2F7", color: "2D3748", bold: true, size: 10, align: "R", border: "8EA8C3"},
+	value:      pdfStyle{fill: "FFFFFF", color: "2D3748", size: 10, align: "R", border: "8EA8C3"},
+	data:       pdfStyle{fill: "FFFFFF", color: "2D3748", size: 10, align: "R", border: "8EA8C3"},
+	dataNum:    pdfStyle{fill: "FFFFFF", color: "276749", size: 10, align: "R", border: "8EA8C3"},
+	summaryLbl: pdfStyle{fill: "EBF8FF", color: "2D3748", size: 10, align: "R", border: "8EA8C3"},
+	summaryAmt: pdfStyle{fill: "EBF8FF", color: "276749", bold: true, size: 10, align: "R", border: "8EA8C3"},
+	summaryNot: pdfStyle{fill: "EBF8FF", color: "718096", size: 9, align: "R", border: "8EA8C3"},
+	signLabel:  pdfStyle{fill: "EDF2F7", color: "2D3748", bold: true, size: 9, align: "C", border: "8EA8C3"},
+	signBox:    pdfStyle{fill: "FFFFFF", color: "718096", size: 9, align: "C", top: true, border: "8EA8C3"},
+}
+
+// pdfCell spans columns from..to (0 = A) of one row.
+type pdfCell struct {
+	from, to int
+	text     string
+	st       pdfStyle
+}
+
+// pdfRow is a table row; height is the minimum in points, as in the workbook.
+type pdfRow struct {
+	height float64
+	cells  []pdfCell
+}
+
+type pdfReport struct {
+	pdf    *fpdf.Fpdf
+	widths [5]float64
+	// header is redrawn at the top of a new page while a table is open.
+	header *pdfRow
+}
+
+func buildPDF(d *reportData) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.SetTitle(reportTitle(d), true)
+	pdf.AddUTF8FontFromBytes(pdfFont, "", dejavusans.TTF)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", dejavusansbold.TTF)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin + 2)
+		pdf.SetFont(pdfFont, "", 8)
+		pdf.SetTextColor(0x71, 0x80, 0x96)
+		pdf.CellFormat(0, 4, rtlVisual("صفحه "+toPersianDigits(strconv.Itoa(pdf.PageNo()))), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	p := &pdfReport{pdf: pdf}
+	pageW, _ := pdf.GetPageSize()
+	// Column widths: A(5), B(32), C(16), D(22), E(26), scaled to the page.
+	excelW := [5]float64{5, 32, 16, 22, 26}
+	total := 0.0
+	for _, w := range excelW {
+		total += w
+	}
+	for i, w := range excelW {
+		p.widths[i] = w / total * (pageW - 2*pdfMargin)
+	}
+
+	p.title(d)
+	p.headerSection(d)
+	p.gap()
+	p.itemTable("جدول کارکرد", d.Stmt.WorkDoneItems, d)
+	p.gap()
+	p.extraTable(d.Stmt.ExtraWorkItems)
+	p.gap()
+	p.deductionTable(d.Stmt.DeductionItems)
+	p.gap()
+	if len(d.Escalations) > 0 {
+		p.escalationTable(d.Escalations)
+		p.gap()
+	}
+	p.financialSummary(d)
+	p.gap()
+	p.signatures()
+
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ─── layout primitives ───────────────────────────────────────────────────────
+
+func hexRGB(s string) (int, int, int) {
+	v, _ := strconv.ParseUint(s, 16, 32)
+	return int(v >> 16 & 0xFF), int(v >> 8 & 0xFF), int(v & 0xFF)
+}
+
+func (p *pdfReport) setFont(st pdfStyle) {
+	style := ""
+	if st.bold {
+		style = "B"
+	}
+	p.pdf.SetFont(pdfFont, style, st.size)
+}
+
+// span returns the left edge and width of columns from..to.
+func (p *pdfReport) span(from, to int) (float64, float64) {
+	pageW, _ := p.pdf.GetPageSize()
+	right := pageW - pdfMargin
+	for i := 0; i < from; i++ {
+		right -= p.widths[i]
+	}
+	w := 0.0
+	for i := from; i <= to; i++ {
+		w += p.widths[i]
+	}
+	return right - w, w
+}
+
+// wrap breaks logical text into lines that fit width at the current font.
+func (p *pdfReport) wrap(text string, width float64) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	line := words[0]
+	for _, w := range words[1:] {
+		if p.pdf.GetStringWidth(shapeArabic(line+" "+w)) <= width {
+			line += " " + w
+			continue
+		}
+		lines = append(lines, line)
+		line = w
+	}
+	return append(lines, line)
+}
+
+func lineHeight(size float64) float64 { return size * ptToMM * 1.35 }
+
+// need starts a new page when h millimetres do not fit on the current one,
+// repeating the open table's header.
+func (p *pdfReport) need(h float64) {
+	_, pageH := p.pdf.GetPageSize()
+	if p.pdf.GetY()+h <= pageH-pdfMargin {
+		return
+	}
+	p.pdf.AddPage()
+	if p.header != nil {
+		p.draw(*p.header)
+	}
+}
+
+// layout wraps every cell and returns the lines and the row height.
+func (p *pdfReport) layout(r pdfRow) ([][]string, float64) {
+	h := r.height * ptToMM
+	lines := make([][]string, len(r.cells))
+	for i, c := range r.cells {
+		p.setFont(c.st)
+		_, w := p.span(c.from, c.to)
+		lines[i] = p.wrap(c.text, w-2*pdfPadding)
+		if need := float64(len(lines[i]))*lineHeight(c.st.size) + 2*pdfPadding; need > h {
+			h = need
+		}
+	}
+	return lines, h
+}
+
+func (p *pdfReport) row(r pdfRow) {
+	_, h := p.layout(r)
+	p.need(h)
+	p.draw(r)
+}
+
+func (p *pdfReport) draw(r pdfRow) {
+	lines, h := p.layout(r)
+	pdf := p.pdf
+	y := pdf.GetY()
+	for i, c := range r.cells {
+		x, w := p.span(c.from, c.to)
+		pdf.SetFillColor(hexRGB(c.st.fill))
+		pdf.SetDrawColor(hexRGB(c.st.border))
+		pdf.SetLineWidth(0.2)
+		if c.st.thick {
+			pdf.SetLineWidth(0.5)
+		}
+		pdf.Rect(x, y, w, h, "FD")
+
+		p.setFont(c.st)
+		pdf.SetTextColor(hexRGB(c.st.color))
+		lh := lineHeight(c.st.size)
+		ty := y + pdfPadding
+		if !c.st.top {
+			ty = y + (h-float64(len(lines[i]))*lh)/2
+		}
+		for _, l := range lines[i] {
+			pdf.SetXY(x+pdfPadding, ty)
+			pdf.CellFormat(w-2*pdfPadding, lh, rtlVisual(l), "", 0, c.st.align, false, 0, "")
+			ty += lh
+		}
+	}
+	pdf.SetXY(pdfMargin, y+h)
+}
+
+// gap is the blank row between sections.
+func (p *pdfReport) gap() {
+	p.pdf.SetY(p.pdf.GetY() + 18*ptToMM)
+}
+
+func full(text string, st pdfStyle) []pdfCell { return []pdfCell{{0, 4, text, st}} }
+
+// sectionTitle keeps a section title on the same page as its table header
+// and first row.
+func (p *pdfReport) sectionTitle(title string, height float64) {
+	p.need((height + 2*18) * ptToMM)
+	p.row(pdfRow{height, full(title, pdfStyles.sectionHdr)})
+}
+
+// table opens a table: its header is drawn now and after every page break
+// until the next table or section.
+func (p *pdfReport) table(header pdfRow) {
+	p.header = nil
+	p.row(header)
+	p.header = &header
+}
+
+func (p *pdfReport) endTable() { p.header = nil }
+
+// ─── sections ────────────────────────────────────────────────────────────────
+
+func (p *pdfReport) title(d *reportData) {
+	p.row(pdfRow{28, full(reportTitle(d), pdfStyles.title)})
+}
+
+func (p *pdfReport) headerSection(d *reportData) {
+	s := pdfStyles
+	for _, r := range reportHeaderRows(d) {
+		p.row(pdfRow{18, []pdfCell{
+			{0, 1, r.leftLabel, s.label},
+			{2, 2, r.leftVal, s.value},
+			{3, 3, r.rightLabel, s.label},
+			{4, 4, r.rightVal, s.value},
+		}})
+	}
+}
+
+func itemTableHeader() pdfRow {
+	s := pdfStyles.tableHdr
+	return pdfRow{18, []pdfCell{
+		{0, 0, "ردیف", s},
+		{1, 1, "شرح آیتم", s},
+		{2, 2, "درصد پیشرفت", s},
+		{3, 4, "قیمت کل", s},
+	}}
+}
+
+func itemRow(no int, desc, pct string, amount decimal.Decimal) pdfRow {
+	s := pdfStyles
+	return pdfRow{18, []pdfCell{
+		{0, 0, toPersianDigits(fmt.Sprintf("%d", no)), s.data},
+		{1, 1, desc, s.data},
+		{2, 2, pct, s.data},
+		{3, 4, fmtPersianNum(amount), s.dataNum},
+	}}
+}
+
+func (p *pdfReport) itemTable(title string, items []model.WorkDoneItem, d *reportData) {
+	p.sectionTitle(title, 22)
+	p.table(itemTableHeader())
+	for _, ch := range workDoneChapters(items, d.LineItemMap) {
+		if ch.heading != "" {
+			p.row(pdfRow{18, full(ch.heading, pdfStyles.label)})
+		}
+		for _, l := range ch.lines {
+			p.row(itemRow(l.no, l.desc, l.pct, l.amount))
+		}
+		if ch.total != "" {
+			p.row(pdfRow{18, []pdfCell{
+				{0, 2, ch.total, pdfStyles.summaryLbl},
+				{3, 4, fmtPersianNum(ch.subtotal), pdfStyles.summaryAmt},
+			}})
+		}
+	}
+	if len(items) == 0 {
+		p.row(pdfRow{18, full("— بدون آیتم —", pdfStyles.data)})
+	}
+	p.endTable()
+}
+
+func (p *pdfReport) extraTable(items []model.ExtraWorkItem) {
+	p.sectionTitle("جدول اضافه کاری و دستورها", 22)
+	p.table(itemTableHeader())
+	for i, item := range items {
+		p.row(itemRow(i+1, item.Description, "۱۰۰٪", item.Amount))
+	}
+	if len(items) == 0 {
+		p.row(pdfRow{18, full("— بدون آیتم —", pdfStyles.data)})
+	}
+	p.endTable()
+}
+
+func (p *pdfReport) deductionTable(items []model.StatementDeductionItem) {
+	p.sectionTitle("جدول کسور (خدمات کارگاهی، جرائم و خسارات)", 22)
+	p.table(itemTableHeader())
+	for i, item := range items {
+		p.row(itemRow(i+1, item.Description, "", item.Amount))
+	}
+	if len(items) == 0 {
+		p.row(pdfRow{18, full("— بدون کسر —", pdfStyles.data)})
+	}
+	p.endTable()
+}
+
+func (p *pdfReport) escalationTable(rows []model.StatementEscalation) {
+	s := pdfStyles
+	q := rows[0]
+	p.sectionTitle("جدول تعدیل (نشریه ۴۳۱۱) – "+toPersianDigits(fmt.Sprintf("سه‌ماهه %d سال %d", q.Quarter, q.Year)), 22)
+	p.table(pdfRow{18, []pdfCell{
+		{0, 0, "ردیف", s.tableHdr},
+		{1, 1, "فصل فهرست بها", s.tableHdr},
+		{2, 2, "مبلغ کارکرد", s.tableHdr},
+		{3, 3, "شاخص پایه / شاخص دوره", s.tableHdr},
+		{4, 4, "مبلغ تعدیل", s.tableHdr},
+	}})
+	for i, r := range rows {
+		chapter := "فصل " + toPersianDigits(fmt.Sprintf("%d", r.Chapter))
+		if r.Chapter == 0 {
+			chapter = "بدون فصل (شاخص کلی)"
+		}
+		indices := "شاخص منتشر نشده"
+		if !r.IndexMissing {
+			indices = toPersianDigits(r.BaseIndex.String() + " / " + r.CurrentIndex.String())
+		}
+		p.row(pdfRow{18, []pdfCell{
+			{0, 0, toPersianDigits(fmt.Sprintf("%d", i+1)), s.data},
+			{1, 1, chapter, s.data},
+			{2, 2, fmtPersianNum(r.WorkAmount), s.dataNum},
+			{3, 3, indices, s.data},
+			{4, 4, fmtPersianNum(r.Amount), s.dataNum},
+		}})
+	}
+	p.endTable()
+}
+
+func (p *pdfReport) financialSummary(d *reportData) {
+	s := pdfStyles
+	sections, net := financialSummary(d)
+
+	p.sectionTitle("جدول خلاصه مالی", 22)
+	p.table(pdfRow{18, []pdfCell{
+		{0, 0, "ردیف", s.tableHdr},
+		{1, 1, "شرح", s.tableHdr},
+		{2, 2, "مبلغ (" + d.Contract.Currency + ")", s.tableHdr},
+		{3, 4, "توضیحات", s.tableHdr},
+	}})
+	for _, sec := range sections {
+		if sec.title != "" {
+			p.need((20 + 18) * ptToMM)
+			p.row(pdfRow{20, full(sec.title, s.sectionHdr)})
+		}
+		for i, r := range sec.rows {
+			p.row(pdfRow{18, []pdfCell{
+				{0, 0, toPersianDigits(fmt.Sprintf("%d", i+1)), s.summaryLbl},
+				{1, 1, r.label, s.summaryLbl},
+				{2, 2, r.amount, s.summaryAmt},
+				{3, 4, r.notes, s.summaryNot},
+			}})
+		}
+	}
+	p.row(pdfRow{22, []pdfCell{
+		{0, 1, "خالص پرداختی", s.sectionHdr},
+		{2, 2, net, s.summaryAmt},
+		{3, 4, "مبلغ پرداختی نهایی به پیمانکار", s.summaryNot},
+	}})
+	p.endTable()
+}
+
+func (p *pdfReport) signatures() {
+	s := pdfStyles
+	p.need((20 + 18 + 18 + 60) * ptToMM)
+	p.row(pdfRow{20, full("محل امضاها", s.sectionHdr)})
+
+	labels := pdfRow{height: 18}
+	dates := pdfRow{height: 18}
+	boxes := pdfRow{height: 60}
+	dateLbl := "تاریخ: " + jalaliDate(time.Now())
+	for i, lbl := range signatureLabels {
+		labels.cells = append(labels.cells, pdfCell{i, i, lbl, s.signLabel})
+		dates.cells = append(dates.cells, pdfCell{i, i, dateLbl, s.signBox})
+		boxes.cells = append(boxes.cells, pdfCell{i, i, "", s.signBox})
+	}
+	p.row(labels)
+	p.row(dates)
+	p.row(boxes)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -25,6 +26,7 @@ type reportData struct {
 	Project      *model.Project
 	PrevCumGross decimal.Decimal
 	LineItemMap  map[uuid.UUID]*model.ContractLineItem
+	Escalations  []model.StatementEscalation
 }
 
 // Build generates the Excel report for a given statement ID.
@@ -38,12 +40,30 @@ func (s *ReportService) Build(ctx context.Context, stmtID string) (*excelize.Fil
 	if err != nil {
 		return nil, "", &ServiceError{Message: "Excel generation failed: " + err.Error(), Code: 500}
 	}
-	name := fmt.Sprintf("statement-%s-%d-%s.xlsx",
+	return f, reportFileName(d, "xlsx"), nil
+}
+
+// BuildPDF renders the same report as a PDF with embedded Persian fonts.
+// Returns the document, suggested filename, and any error.
+func (s *ReportService) BuildPDF(ctx context.Context, stmtID string) ([]byte, string, error) {
+	d, err := s.load(ctx, stmtID)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := buildPDF(d)
+	if err != nil {
+		return nil, "", &ServiceError{Message: "PDF generation failed: " + err.Error(), Code: 500}
+	}
+	return b, reportFileName(d, "pdf"), nil
+}
+
+func reportFileName(d *reportData, ext string) string {
+	return fmt.Sprintf("statement-%s-%d-%s.%s",
 		strings.ReplaceAll(d.Contract.ContractNo, "/", "-"),
 		d.Stmt.SequenceNo,
 		jalaliDateASCII(d.Stmt.IssuedOn),
+		ext,
 	)
-	return f, name, nil
 }
 
 func (s *ReportService) load(ctx context.Context, stmtID string) (*reportData, error) {
@@ -82,13 +102,17 @@ func (s *ReportService) load(ctx context.Context, stmtID string) (*reportData, e
 		liMap[lineItems[i].ID] = &lineItems[i]
 	}
 
-	// Sum gross+extra of all prior statements in the same contract.
+	// Sum gross+extra of the approved prior statements in the same contract.
+	// Statement amounts are period deltas in both statement modes.
 	var prevCum decimal.Decimal
 	s.db.WithContext(ctx).Model(&model.InterimStatement{}).
-		Where("contract_id = ? AND sequence_no < ?", ct.ID, stmt.SequenceNo).
+		Where("contract_id = ? AND status = ? AND sequence_no < ?", ct.ID, model.StatementApproved, stmt.SequenceNo).
 		Select("COALESCE(SUM(gross_amount + extra_amount), 0)").
 		Scan(&prevCum)
 
+	var escalations []model.StatementEscalation
+	s.db.WithContext(ctx).Where("statement_id = ?", stmt.ID).Order("chapter ASC").Find(&escalations)
+
 	return &reportData{
 		Stmt:         &stmt,
 		Contract:     &ct,
@@ -96,6 +120,7 @@ func (s *ReportService) load(ctx context.Context, stmtID string) (*reportData, e
 		Project:      &project,
 		PrevCumGross: prevCum,
 		LineItemMap:  liMap,
+		Escalations:  escalations,
 	}, nil
 }
 
@@ -149,6 +174,10 @@ func buildExcel(d *reportData) (*excelize.File, error) {
 	row++
 	row = writeDeductionTable(f, d.Stmt.DeductionItems, st, row)
 	row++
+	if len(d.Escalations) > 0 {
+		row = writeEscalationTable(f, d.Escalations, st, row)
+		row++
+	}
 	row = writeFinancialSummary(f, d, st, row)
 	row++
 	writeSignatures(f, st, row)
@@ -170,15 +199,20 @@ func setValue(f *excelize.File, c string, v any) {
 func writeTitle(f *excelize.File, d *reportData, st styles, row int) int {
 	f.SetRowHeight(sheetName, row, 28)
 	mergeRange(f, cell("A", row), cell("E", row))
-	title := fmt.Sprintf("صورت وضعیت شماره %d  —  قرارداد %s", d.Stmt.SequenceNo, d.Contract.ContractNo)
-	setValue(f, cell("A", row), title)
+	setValue(f, cell("A", row), reportTitle(d))
 	setStyle(f, cell("A", row), cell("E", row), st.title)
 	return row + 1
 }
 
-func writeHeaderSection(f *excelize.File, d *reportData, st styles, row int) int {
-	type hRow struct{ leftLabel, leftVal, rightLabel, rightVal string }
+func reportTitle(d *reportData) string {
+	return fmt.Sprintf("صورت وضعیت شماره %d  —  قرارداد %s", d.Stmt.SequenceNo, d.Contract.ContractNo)
+}
+
+// headerRow is one line of the header panel: a label/value pair on each side.
+type headerRow struct{ leftLabel, leftVal, rightLabel, rightVal string }
 
+// reportHeaderRows is the contract and period panel under the title.
+func reportHeaderRows(d *reportData) []headerRow {
 	ct := d.Contract
 	tr := d.Contractor
 	proj := d.Project
@@ -206,13 +240,13 @@ func writeHeaderSection(f *excelize.File, d *reportData, st styles, row int) int
 		startDate = jalaliDate(*ct.StartsOn)
 	}
 	endDate := "—"
-	if ct.EndsOn != nil {
-		endDate = jalaliDate(*ct.EndsOn)
+	if end := ct.CompletionDate(); end != nil {
+		endDate = jalaliDate(*end)
 	}
 
 	durationDays := "—"
-	if ct.StartsOn != nil && ct.EndsOn != nil {
-		days := int(ct.EndsOn.Sub(*ct.StartsOn).Hours() / 24)
+	if end := ct.CompletionDate(); ct.StartsOn != nil && end != nil {
+		days := int(end.Sub(*ct.StartsOn).Hours() / 24)
 		durationDays = toPersianDigits(fmt.Sprintf("%d", days)) + " روز"
 	}
 
@@ -230,7 +264,7 @@ func writeHeaderSection(f *excelize.File, d *reportData, st styles, row int) int
 		periodPct = fmtPersianPct(diff)
 	}
 
-	rows := []hRow{
+	return []headerRow{
 		{"نام و نام خانوادگی / شرکت:", contractorName, "شماره قرارداد:", ct.ContractNo},
 		{"نوع پیمانکار:", contractorType, "تاریخ شروع قرارداد:", startDate},
 		{"شناسه تفضیلی پیمانکار:", taxID, "مدت قرارداد:", durationDays},
@@ -241,8 +275,10 @@ func writeHeaderSection(f *excelize.File, d *reportData, st styles, row int) int
 		{"درصد پیشرفت تا صورت وضعیت جدید:", currPct, "تاریخ صدور:", jalaliDate(stmt.IssuedOn)},
 		{"میزان پیشرفت این صورت وضعیت:", periodPct, "", ""},
 	}
+}
 
-	for _, r := range rows {
+func writeHeaderSection(f *excelize.File, d *reportData, st styles, row int) int {
+	for _, r := range reportHeaderRows(d) {
 		f.SetRowHeight(sheetName, row, 18)
 		// right panel: cols D-E
 		setValue(f, cell("D", row), r.rightLabel)
@@ -279,31 +315,142 @@ func writeTableHeader(f *excelize.File, st styles, row int) int {
 	return row + 1
 }
 
+// reportLine is one row of an item table.
+type reportLine struct {
+	no     int
+	desc   string
+	pct    string
+	amount decimal.Decimal
+}
+
+// reportChapter is the work done of one chapter (فصل) of the BOQ. Heading and
+// subtotal label are empty when the table is not grouped.
+type reportChapter struct {
+	heading  string
+	total    string
+	lines    []reportLine
+	subtotal decimal.Decimal
+}
+
+// workDoneChapters groups work done by the chapter of its line item. Items
+// without a chapter come last; when no item has one there is a single
+// ungrouped chapter.
+func workDoneChapters(items []model.WorkDoneItem, liMap map[uuid.UUID]*model.ContractLineItem) []reportChapter {
+	chapterOf := func(item model.WorkDoneItem) int {
+		if item.LineItemID != nil {
+			if li, ok := liMap[*item.LineItemID]; ok {
+				return li.Chapter
+			}
+		}
+		return 0
+	}
+	byChapter := make(map[int][]model.WorkDoneItem)
+	var chapters []int
+	for _, item := range items {
+		ch := chapterOf(item)
+		if _, ok := byChapter[ch]; !ok {
+			chapters = append(chapters, ch)
+		}
+		byChapter[ch] = append(byChapter[ch], item)
+	}
+	sort.Slice(chapters, func(i, j int) bool {
+		if (chapters[i] == 0) != (chapters[j] == 0) {
+			return chapters[j] == 0
+		}
+		return chapters[i] < chapters[j]
+	})
+	grouped := len(chapters) > 1 || (len(chapters) == 1 && chapters[0] != 0)
+
+	// Chapter titles come from the first top-level group line of each chapter.
+	heads := make(map[int]*model.ContractLineItem)
+	for _, li := range liMap {
+		if !li.IsGroup || li.ParentID != nil {
+			continue
+		}
+		if h, ok := heads[li.Chapter]; !ok || li.SortOrder < h.SortOrder ||
+			(li.SortOrder == h.SortOrder && li.ItemCode < h.ItemCode) {
+			heads[li.Chapter] = li
+		}
+	}
+
+	out := make([]reportChapter, 0, len(chapters))
+	n := 0
+	for _, ch := range chapters {
+		var rc reportChapter
+		if grouped {
+			label := "فصل " + toPersianDigits(fmt.Sprintf("%d", ch))
+			if ch == 0 {
+				label = "بدون فصل"
+			}
+			rc.heading = label
+			if h, ok := heads[ch]; ok && ch != 0 && h.Description != "" {
+				rc.heading += " — " + h.Description
+			}
+			rc.total = "جمع " + label
+		}
+		rc.subtotal = decimal.Zero
+		for _, item := range byChapter[ch] {
+			n++
+			pct := ""
+			if item.LineItemID != nil {
+				if li, ok := liMap[*item.LineItemID]; ok && li.Quantity.GreaterThan(decimal.Zero) {
+					p := item.Quantity.Div(li.Quantity).Mul(decimal.NewFromInt(100))
+					s := fmt.Sprintf("%.1f%%", mustFloat64Decimal(p))
+					s = strings.ReplaceAll(s, ".", "٫")
+					pct = toPersianDigits(s)
+				}
+			}
+			desc := item.Description
+			if item.BoQItemCode != "" {
+				desc = toPersianDigits(item.BoQItemCode) + " — " + desc
+			}
+			rc.lines = append(rc.lines, reportLine{no: n, desc: desc, pct: pct, amount: item.Amount})
+			rc.subtotal = rc.subtotal.Add(item.Amount)
+		}
+		out = append(out, rc)
+	}
+	return out
+}
+
+// writeItemTable lists work done grouped by chapter, each chapter closed by
+// a subtotal row (see workDoneChapters).
 func writeItemTable(f *excelize.File, title string, items []model.WorkDoneItem, liMap map[uuid.UUID]*model.ContractLineItem, _ decimal.Decimal, st styles, row int) int {
 	row = writeSectionTitle(f, title, st, row)
 	row = writeTableHeader(f, st, row)
 
-	for i, item := range items {
-		f.SetRowHeight(sheetName, row, 18)
-		pct := ""
-		if item.LineItemID != nil {
-			if li, ok := liMap[*item.LineItemID]; ok && li.Quantity.GreaterThan(decimal.Zero) {
-				p := item.Quantity.Div(li.Quantity).Mul(decimal.NewFromInt(100))
-				s := fmt.Sprintf("%.1f%%", mustFloat64Decimal(p))
-				s = strings.ReplaceAll(s, ".", "٫")
-				pct = toPersianDigits(s)
-			}
+	for _, ch := range workDoneChapters(items, liMap) {
+		if ch.heading != "" {
+			f.SetRowHeight(sheetName, row, 18)
+			mergeRange(f, cell("A", row), cell("E", row))
+			setValue(f, cell("A", row), ch.heading)
+			setStyle(f, cell("A", row), cell("E", row), st.label)
+			row++
+		}
+
+		for _, l := range ch.lines {
+			f.SetRowHeight(sheetName, row, 18)
+			setValue(f, cell("A", row), toPersianDigits(fmt.Sprintf("%d", l.no)))
+			setValue(f, cell("B", row), l.desc)
+			setValue(f, cell("C", row), l.pct)
+			setValue(f, cell("D", row), fmtPersianNum(l.amount))
+			mergeRange(f, cell("D", row), cell("E", row))
+			setStyle(f, cell("A", row), cell("A", row), st.data)
+			setStyle(f, cell("B", row), cell("B", row), st.data)
+			setStyle(f, cell("C", row), cell("C", row), st.data)
+			setStyle(f, cell("D", row), cell("E", row), st.dataNum)
+			row++
+		}
+
+		if ch.total != "" {
+			f.SetRowHeight(sheetName, row, 18)
+			mergeRange(f, cell("A", row), cell("C", row))
+			setValue(f, cell("A", row), ch.total)
+			setValue(f, cell("D", row), fmtPersianNum(ch.subtotal))
+			mergeRange(f, cell("D", row), cell("E", row))
+			setStyle(f, cell("A", row), cell("C", row), st.summaryLbl)
+			setStyle(f, cell("D", row), cell("E", row), st.summaryAmt)
+			row++
 		}
-		setValue(f, cell("A", row), toPersianDigits(fmt.Sprintf("%d", i+1)))
-		setValue(f, cell("B", row), item.Description)
-		setValue(f, cell("C", row), pct)
-		setValue(f, cell("D", row), fmtPersianNum(item.Amount))
-		mergeRange(f, cell("D", row), cell("E", row))
-		setStyle(f, cell("A", row), cell("A", row), st.data)
-		setStyle(f, cell("B", row), cell("B", row), st.data)
-		setStyle(f, cell("C", row), cell("C", row), st.data)
-		setStyle(f, cell("D", row), cell("E", row), st.dataNum)
-		row++
 	}
 
 	if len(items) == 0 {
@@ -375,7 +522,54 @@ func writeDeductionTable(f *excelize.File, items []model.StatementDeductionItem,
 	return row
 }
 
-func writeFinancialSummary(f *excelize.File, d *reportData, st styles, row int) int {
+func writeEscalationTable(f *excelize.File, rows []model.StatementEscalation, st styles, row int) int {
+	q := rows[0]
+	row = writeSectionTitle(f, "جدول تعدیل (نشریه ۴۳۱۱) – "+toPersianDigits(fmt.Sprintf("سه‌ماهه %d سال %d", q.Quarter, q.Year)), st, row)
+
+	f.SetRowHeight(sheetName, row, 18)
+	setValue(f, cell("A", row), "ردیف")
+	setValue(f, cell("B", row), "فصل فهرست بها")
+	setValue(f, cell("C", row), "مبلغ کارکرد")
+	setValue(f, cell("D", row), "شاخص پایه / شاخص دوره")
+	setValue(f, cell("E", row), "مبلغ تعدیل")
+	setStyle(f, cell("A", row), cell("E", row), st.tableHdr)
+	row++
+
+	for i, r := range rows {
+		f.SetRowHeight(sheetName, row, 18)
+		chapter := "فصل " + toPersianDigits(fmt.Sprintf("%d", r.Chapter))
+		if r.Chapter == 0 {
+			chapter = "بدون فصل (شاخص کلی)"
+		}
+		indices := "شاخص منتشر نشده"
+		if !r.IndexMissing {
+			indices = toPersianDigits(r.BaseIndex.String() + " / " + r.CurrentIndex.String())
+		}
+		setValue(f, cell("A", row), toPersianDigits(fmt.Sprintf("%d", i+1)))
+		setValue(f, cell("B", row), chapter)
+		setValue(f, cell("C", row), fmtPersianNum(r.WorkAmount))
+		setValue(f, cell("D", row), indices)
+		setValue(f, cell("E", row), fmtPersianNum(r.Amount))
+		setStyle(f, cell("A", row), cell("B", row), st.data)
+		setStyle(f, cell("C", row), cell("C", row), st.dataNum)
+		setStyle(f, cell("D", row), cell("D", row), st.data)
+		setStyle(f, cell("E", row), cell("E", row), st.dataNum)
+		row++
+	}
+	return row
+}
+
+type summaryRow struct{ label, amount, notes string }
+
+// summarySection is a block of the financial summary; rows are numbered
+// within it. The opening block has no title and sits under the table header.
+type summarySection struct {
+	title string
+	rows  []summaryRow
+}
+
+// financialSummary returns the summary blocks and the net payable amount.
+func financialSummary(d *reportData) ([]summarySection, string) {
 	stmt := d.Stmt
 	ct := d.Contract
 	bpsDivisor := decimal.NewFromInt(10000)
@@ -389,47 +583,13 @@ func writeFinancialSummary(f *excelize.File, d *reportData, st styles, row int)
 	vatRate := decimal.NewFromInt(int64(ct.VatPctBps)).Div(bpsDivisor).Mul(decimal.NewFromInt(100))
 	advanceRate := decimal.NewFromInt(int64(ct.AdvancePctBps)).Div(bpsDivisor).Mul(decimal.NewFromInt(100))
 
-	row = writeSectionTitle(f, "جدول خلاصه مالی", st, row)
-
-	// Table header
-	f.SetRowHeight(sheetName, row, 18)
-	setValue(f, cell("A", row), "ردیف")
-	setValue(f, cell("B", row), "شرح")
-	setValue(f, cell("C", row), "مبلغ ("+ct.Currency+")")
-	setValue(f, cell("D", row), "توضیحات")
-	mergeRange(f, cell("D", row), cell("E", row))
-	setStyle(f, cell("A", row), cell("E", row), st.tableHdr)
-	row++
-
-	type summaryRow struct{ label, amount, notes string }
-	summaryRows := []summaryRow{
+	gross := summarySection{rows: []summaryRow{
 		{"مبلغ ناخالص کارکرد تجمعی فعلی", fmtPersianNum(currCum), ""},
 		{"مبلغ ناخالص کارکرد تجمعی قبلی", fmtPersianNum(d.PrevCumGross), ""},
 		{"مبلغ ناخالص کارکرد دوره", fmtPersianNum(periodGross), ""},
-	}
-
-	for i, r := range summaryRows {
-		f.SetRowHeight(sheetName, row, 18)
-		setValue(f, cell("A", row), toPersianDigits(fmt.Sprintf("%d", i+1)))
-		setValue(f, cell("B", row), r.label)
-		setValue(f, cell("C", row), r.amount)
-		mergeRange(f, cell("D", row), cell("E", row))
-		setValue(f, cell("D", row), r.notes)
-		setStyle(f, cell("A", row), cell("A", row), st.summaryLbl)
-		setStyle(f, cell("B", row), cell("B", row), st.summaryLbl)
-		setStyle(f, cell("C", row), cell("C", row), st.summaryAmt)
-		setStyle(f, cell("D", row), cell("E", row), st.summaryNot)
-		row++
-	}
+	}}
 
-	// Legal deductions section
-	f.SetRowHeight(sheetName, row, 20)
-	mergeRange(f, cell("A", row), cell("E", row))
-	setValue(f, cell("A", row), "کسورات قانونی")
-	setStyle(f, cell("A", row), cell("E", row), st.sectionHdr)
-	row++
-
-	legalDeductions := []summaryRow{
+	legal := summarySection{title: "کسورات قانونی", rows: []summaryRow{
 		{
 			"سپرده حسن انجام کار (ضمانت اجرا)",
 			fmtPersianNum(stmt.RetentionAmount),
@@ -440,81 +600,70 @@ func writeFinancialSummary(f *excelize.File, d *reportData, st styles, row int)
 			fmtPersianNum(stmt.SocialSecurityAmount),
 			"با نرخ " + fmtPersianPct(mustFloat64Decimal(socialRate)) + " مطابق قرارداد",
 		},
-	}
-	for i, r := range legalDeductions {
-		f.SetRowHeight(sheetName, row, 18)
-		setValue(f, cell("A", row), toPersianDigits(fmt.Sprintf("%d", i+1)))
-		setValue(f, cell("B", row), r.label)
-		setValue(f, cell("C", row), r.amount)
-		mergeRange(f, cell("D", row), cell("E", row))
-		setValue(f, cell("D", row), r.notes)
-		setStyle(f, cell("A", row), cell("A", row), st.summaryLbl)
-		setStyle(f, cell("B", row), cell("B", row), st.summaryLbl)
-		setStyle(f, cell("C", row), cell("C", row), st.summaryAmt)
-		setStyle(f, cell("D", row), cell("E", row), st.summaryNot)
-		row++
+	}}
+
+	// Other deductions: the advance recovery, then the custom deduction items.
+	other := summarySection{title: "سایر کسورات", rows: []summaryRow{
+		{"پیش‌پرداخت – علی‌الحساب", fmtPersianNum(stmt.AdvanceRecovered), "با نرخ " + fmtPersianPct(mustFloat64Decimal(advanceRate)) + " مطابق قرارداد"},
+	}}
+	for _, item := range stmt.DeductionItems {
+		other.rows = append(other.rows, summaryRow{item.Description, fmtPersianNum(item.Amount), ""})
 	}
 
-	// Other deductions
-	f.SetRowHeight(sheetName, row, 20)
-	mergeRange(f, cell("A", row), cell("E", row))
-	setValue(f, cell("A", row), "سایر کسورات")
-	setStyle(f, cell("A", row), cell("E", row), st.sectionHdr)
-	row++
+	// Other additions: escalation when there is any, then VAT.
+	additions := summarySection{title: "سایر اضافات"}
+	if !stmt.EscalationAmount.IsZero() {
+		coef := decimal.NewFromInt(int64(ct.EscalationCoefficientBps)).Div(bpsDivisor).Mul(decimal.NewFromInt(100))
+		additions.rows = append(additions.rows, summaryRow{"تعدیل آحاد بها", fmtPersianNum(stmt.EscalationAmount), "با ضریب " + fmtPersianPct(mustFloat64Decimal(coef)) + " مطابق نشریه ۴۳۱۱"})
+	}
+	additions.rows = append(additions.rows, summaryRow{"مالیات بر ارزش افزوده", fmtPersianNum(stmt.VatAmount), "با نرخ " + fmtPersianPct(mustFloat64Decimal(vatRate)) + " مصوب دولت"})
 
-	advanceNote := "با نرخ " + fmtPersianPct(mustFloat64Decimal(advanceRate)) + " مطابق قرارداد"
-	f.SetRowHeight(sheetName, row, 18)
-	setValue(f, cell("A", row), "۱")
-	setValue(f, cell("B", row), "پیش‌پرداخت – علی‌الحساب")
-	setValue(f, cell("C", row), fmtPersianNum(stmt.AdvanceRecovered))
-	mergeRange(f, cell("D", row), cell("E", row))
-	setValue(f, cell("D", row), advanceNote)
-	setStyle(f, cell("A", row), cell("A", row), st.summaryLbl)
-	setStyle(f, cell("B", row), cell("B", row), st.summaryLbl)
-	setStyle(f, cell("C", row), cell("C", row), st.summaryAmt)
-	setStyle(f, cell("D", row), cell("E", row), st.summaryNot)
-	row++
+	return []summarySection{gross, legal, other, additions}, fmtPersianNum(stmt.NetAmount)
+}
 
-	// Custom deduction items
-	for i, item := range stmt.DeductionItems {
-		f.SetRowHeight(sheetName, row, 18)
-		setValue(f, cell("A", row), toPersianDigits(fmt.Sprintf("%d", i+2)))
-		setValue(f, cell("B", row), item.Description)
-		setValue(f, cell("C", row), fmtPersianNum(item.Amount))
-		mergeRange(f, cell("D", row), cell("E", row))
-		setValue(f, cell("D", row), "")
-		setStyle(f, cell("A", row), cell("A", row), st.summaryLbl)
-		setStyle(f, cell("B", row), cell("B", row), st.summaryLbl)
-		setStyle(f, cell("C", row), cell("C", row), st.summaryAmt)
-		setStyle(f, cell("D", row), cell("E", row), st.summaryNot)
-		row++
-	}
+func writeFinancialSummary(f *excelize.File, d *reportData, st styles, row int) int {
+	sections, net := financialSummary(d)
 
-	// Other additions (VAT)
-	f.SetRowHeight(sheetName, row, 20)
-	mergeRange(f, cell("A", row), cell("E", row))
-	setValue(f, cell("A", row), "سایر اضافات")
-	setStyle(f, cell("A", row), cell("E", row), st.sectionHdr)
-	row++
+	row = writeSectionTitle(f, "جدول خلاصه مالی", st, row)
 
-	vatNote := "با نرخ " + fmtPersianPct(mustFloat64Decimal(vatRate)) + " مصوب دولت"
+	// Table header
 	f.SetRowHeight(sheetName, row, 18)
-	setValue(f, cell("A", row), "۱")
-	setValue(f, cell("B", row), "مالیات بر ارزش افزوده")
-	setValue(f, cell("C", row), fmtPersianNum(stmt.VatAmount))
+	setValue(f, cell("A", row), "ردیف")
+	setValue(f, cell("B", row), "شرح")
+	setValue(f, cell("C", row), "مبلغ ("+d.Contract.Currency+")")
+	setValue(f, cell("D", row), "توضیحات")
 	mergeRange(f, cell("D", row), cell("E", row))
-	setValue(f, cell("D", row), vatNote)
-	setStyle(f, cell("A", row), cell("A", row), st.summaryLbl)
-	setStyle(f, cell("B", row), cell("B", row), st.summaryLbl)
-	setStyle(f, cell("C", row), cell("C", row), st.summaryAmt)
-	setStyle(f, cell("D", row), cell("E", row), st.summaryNot)
+	setStyle(f, cell("A", row), cell("E", row), st.tableHdr)
 	row++
 
+	for _, sec := range sections {
+		if sec.title != "" {
+			f.SetRowHeight(sheetName, row, 20)
+			mergeRange(f, cell("A", row), cell("E", row))
+			setValue(f, cell("A", row), sec.title)
+			setStyle(f, cell("A", row), cell("E", row), st.sectionHdr)
+			row++
+		}
+		for i, r := range sec.rows {
+			f.SetRowHeight(sheetName, row, 18)
+			setValue(f, cell("A", row), toPersianDigits(fmt.Sprintf("%d", i+1)))
+			setValue(f, cell("B", row), r.label)
+			setValue(f, cell("C", row), r.amount)
+			mergeRange(f, cell("D", row), cell("E", row))
+			setValue(f, cell("D", row), r.notes)
+			setStyle(f, cell("A", row), cell("A", row), st.summaryLbl)
+			setStyle(f, cell("B", row), cell("B", row), st.summaryLbl)
+			setStyle(f, cell("C", row), cell("C", row), st.summaryAmt)
+			setStyle(f, cell("D", row), cell("E", row), st.summaryNot)
+			row++
+		}
+	}
+
 	// Net payable
 	f.SetRowHeight(sheetName, row, 22)
 	mergeRange(f, cell("A", row), cell("B", row))
 	setValue(f, cell("A", row), "خالص پرداختی")
-	setValue(f, cell("C", row), fmtPersianNum(stmt.NetAmount))
+	setValue(f, cell("C", row), net)
 	mergeRange(f, cell("D", row), cell("E", row))
 	setValue(f, cell("D", row), "مبلغ پرداختی نهایی به پیمانکار")
 	setStyle(f, cell("A", row), cell("B", row), st.sectionHdr)
@@ -525,6 +674,9 @@ func writeFinancialSummary(f *excelize.File, d *reportData, st styles, row int)
 	return row
 }
 
+// signatureLabels head the signature boxes, one per column from A.
+var signatureLabels = []string{"مدیر عامل", "مدیر مالی", "مدیر حقوقی", "مدیر فنی", "پیمانکار"}
+
 func writeSignatures(f *excelize.File, st styles, row int) {
 	f.SetRowHeight(sheetName, row, 20)
 	mergeRange(f, cell("A", row), cell("E", row))
@@ -534,9 +686,8 @@ func writeSignatures(f *excelize.File, st styles, row int) {
 
 	// Row 1: labels
 	f.SetRowHeight(sheetName, row, 18)
-	labels := []string{"مدیر عامل", "مدیر مالی", "مدیر حقوقی", "مدیر فنی", "پیمانکار"}
 	cols := []string{"A", "B", "C", "D", "E"}
-	for i, lbl := range labels {
+	for i, lbl := range signatureLabels {
 		setValue(f, cell(cols[i], row), lbl)
 		setStyle(f, cell(cols[i], row), cell(cols[i], row), st.signLabel)
 	}
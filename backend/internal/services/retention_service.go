@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetentionService manages the retention ledger (سپرده حسن انجام کار) of a
+// contract. Withholdings are booked automatically when an InterimStatement is
+// approved (see recordRetention); releases happen at provisional and final
+// acceptance and are audited through ApprovalEvent.
+type RetentionService struct{ db *gorm.DB }
+
+func NewRetentionService(db *gorm.DB) *RetentionService { return &RetentionService{db: db} }
+
+type ReleaseRetentionReq struct {
+	RetentionType string `json:"retention_type"`
+	Stage         string `json:"stage"`
+	Amount        string `json:"amount"`       // empty = release the whole remaining balance
+	ReleaseDate   string `json:"release_date"` // "2006-01-02"; defaults to today
+	Comment       string `json:"comment"`
+}
+
+// RetentionBalance is the withheld / released / outstanding total of one type.
+type RetentionBalance struct {
+	RetentionType model.RetentionType `json:"retention_type"`
+	Deducted      decimal.Decimal     `json:"deducted"`
+	Released      decimal.Decimal     `json:"released"`
+	Balance       decimal.Decimal     `json:"balance"`
+}
+
+// RetentionLedger is the full retention history of a contract plus per-type totals.
+type RetentionLedger struct {
+	Records  []model.RetentionRecord `json:"records"`
+	Balances []RetentionBalance      `json:"balances"`
+	Deducted decimal.Decimal         `json:"deducted"`
+	Released decimal.Decimal         `json:"released"`
+	Balance  decimal.Decimal         `json:"balance"`
+}
+
+// Release status labels written to ApprovalEvent for retention records.
+const (
+	retentionHeld              = "held"
+	retentionPartiallyReleased = "partially_released"
+	retentionReleased          = "released"
+)
+
+func retentionStatus(r *model.RetentionRecord) string {
+	switch {
+	case !r.ReleasedAmount.IsPositive():
+		return retentionHeld
+	case r.ReleasedAmount.GreaterThanOrEqual(r.DeductedAmount):
+		return retentionReleased
+	default:
+		return retentionPartiallyReleased
+	}
+}
+
+func (s *RetentionService) Ledger(ctx context.Context, contractID string) (*RetentionLedger, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	return retentionLedger(s.db.WithContext(ctx), cid)
+}
+
+// retentionLedger loads the retention records of a contract and totals them per type.
+func retentionLedger(tx *gorm.DB, contractID uuid.UUID) (*RetentionLedger, error) {
+	var records []model.RetentionRecord
+	if err := tx.Where("contract_id = ?", contractID).
+		Order("created_at ASC").
+		Find(&records).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+
+	ledger := &RetentionLedger{Records: records}
+	for _, t := range []model.RetentionType{model.RetentionPerformanceBond, model.RetentionDefectLiability} {
+		b := RetentionBalance{RetentionType: t}
+		for i := range records {
+			if records[i].RetentionType != t {
+				continue
+			}
+			b.Deducted = b.Deducted.Add(records[i].DeductedAmount)
+			b.Released = b.Released.Add(records[i].ReleasedAmount)
+		}
+		b.Balance = b.Deducted.Sub(b.Released)
+		ledger.Balances = append(ledger.Balances, b)
+		ledger.Deducted = ledger.Deducted.Add(b.Deducted)
+		ledger.Released = ledger.Released.Add(b.Released)
+	}
+	ledger.Balance = ledger.Deducted.Sub(ledger.Released)
+	return ledger, nil
+}
+
+// Release pays back withheld retention of one type, oldest statements first.
+// Every record touched gets its own ApprovalEvent (held → partially_released →
+// released) so the audit trail shows exactly which withholdings were returned.
+func (s *RetentionService) Release(ctx context.Context, contractID string, req ReleaseRetentionReq, actorID uuid.UUID) ([]model.RetentionRecord, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	rtype := model.RetentionType(req.RetentionType)
+	if !rtype.Valid() {
+		return nil, &ServiceError{Message: "retention_type must be performance_bond or defect_liability", Code: 400}
+	}
+	stage := model.RetentionReleaseStage(req.Stage)
+	if !stage.Valid() {
+		return nil, &ServiceError{Message: "stage must be provisional_acceptance or final_acceptance", Code: 400}
+	}
+	var amount decimal.Decimal
+	if req.Amount != "" {
+		amount, err = decimal.NewFromString(req.Amount)
+		if err != nil || !amount.IsPositive() {
+			return nil, &ServiceError{Message: "amount must be a positive number", Code: 400}
+		}
+	}
+	releaseDate := time.Now()
+	if req.ReleaseDate != "" {
+		t := parseDate(req.ReleaseDate)
+		if t == nil {
+			return nil, &ServiceError{Message: "Invalid release_date (expected YYYY-MM-DD)", Code: 400}
+		}
+		releaseDate = *t
+	}
+
+	var touched []model.RetentionRecord
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ct model.Contract
+		if err := tx.First(&ct, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+
+		records, err := heldRetention(tx, cid, rtype)
+		if err != nil {
+			return err
+		}
+		balance := decimal.Zero
+		for i := range records {
+			balance = balance.Add(records[i].DeductedAmount.Sub(records[i].ReleasedAmount))
+		}
+		if !balance.IsPositive() {
+			return &ServiceError{Message: "No retention balance to release", Code: 409}
+		}
+		if req.Amount == "" {
+			amount = balance
+		}
+		if amount.GreaterThan(balance) {
+			return &ServiceError{Message: "amount exceeds the retention balance of " + balance.String(), Code: 422}
+		}
+		touched, err = releaseRetention(tx, records, amount, stage, releaseDate, req.Comment, actorID)
+		return err
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return touched, nil
+}
+
+// heldRetention locks the records of one type that still hold a balance,
+// oldest first.
+func heldRetention(tx *gorm.DB, contractID uuid.UUID, rtype model.RetentionType) ([]model.RetentionRecord, error) {
+	var records []model.RetentionRecord
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("contract_id = ? AND retention_type = ? AND released_amount < deducted_amount", contractID, rtype).
+		Order("created_at ASC").
+		Find(&records).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return records, nil
+}
+
+// releaseRetention releases amount across records in order and writes one
+// ApprovalEvent per record touched. The caller checks amount against the balance.
+func releaseRetention(tx *gorm.DB, records []model.RetentionRecord, amount decimal.Decimal, stage model.RetentionReleaseStage, releaseDate time.Time, note string, actorID uuid.UUID) ([]model.RetentionRecord, error) {
+	var touched []model.RetentionRecord
+	if len(records) == 0 {
+		return touched, nil
+	}
+	var companyID uuid.UUID
+	if err := tx.Model(&model.Contract{}).Where("id = ?", records[0].ContractID).
+		Select("company_id").Scan(&companyID).Error; err != nil {
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	remaining := amount
+	for i := range records {
+		if !remaining.IsPositive() {
+			break
+		}
+		r := &records[i]
+		from := retentionStatus(r)
+		portion := decimal.Min(r.DeductedAmount.Sub(r.ReleasedAmount), remaining)
+		remaining = remaining.Sub(portion)
+
+		r.ReleasedAmount = r.ReleasedAmount.Add(portion)
+		r.ReleaseDate = &releaseDate
+		r.ReleaseStage = stage
+		if err := tx.Model(r).Updates(map[string]any{
+			"released_amount": r.ReleasedAmount,
+			"release_date":    r.ReleaseDate,
+			"release_stage":   r.ReleaseStage,
+		}).Error; err != nil {
+			return nil, &ServiceError{Message: "Failed to release retention", Code: 500}
+		}
+
+		comment := string(stage) + ": " + portion.String() + " " + r.CurrencyCode
+		if note != "" {
+			comment += " — " + note
+		}
+		evt := model.ApprovalEvent{
+			EntityType: "retention_record",
+			EntityID:   r.ID,
+			ActorID:    actorID,
+			FromStatus: from,
+			ToStatus:   retentionStatus(r),
+			Comment:    comment,
+			CreatedAt:  time.Now(),
+		}
+		if err := recordApproval(tx, companyID, &evt); err != nil {
+			return nil, err
+		}
+		touched = append(touched, *r)
+	}
+	return touched, nil
+}
+
+// recordRetention splits the retention withheld on an approved statement into
+// one RetentionRecord per RetentionType. The performance-bond share follows
+// Contract.PerformanceBondPctBps (capped at RetentionPctBps); the remainder is
+// defect-liability retention, so the two rows always sum to RetentionAmount.
+func recordRetention(tx *gorm.DB, stmt *model.InterimStatement, ct *model.Contract) error {
+	if !stmt.RetentionAmount.IsPositive() || ct.RetentionPctBps <= 0 {
+		return nil
+	}
+	pbBps := min(ct.PerformanceBondPctBps, ct.RetentionPctBps)
+	pbAmount := stmt.RetentionAmount.
+		Mul(decimal.NewFromInt(int64(pbBps))).
+		Div(decimal.NewFromInt(int64(ct.RetentionPctBps)))
+
+	records := []model.RetentionRecord{
+		{
+			InterimStatementID: stmt.ID,
+			ContractID:         ct.ID,
+			RetentionType:      model.RetentionPerformanceBond,
+			PctBps:             pbBps,
+			DeductedAmount:     pbAmount,
+			CurrencyCode:       stmt.Currency,
+		},
+		{
+			InterimStatementID: stmt.ID,
+			ContractID:         ct.ID,
+			RetentionType:      model.RetentionDefectLiability,
+			PctBps:             ct.RetentionPctBps - pbBps,
+			DeductedAmount:     stmt.RetentionAmount.Sub(pbAmount),
+			CurrencyCode:       stmt.Currency,
+		},
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return dbErr(err)
+	}
+	return nil
+}
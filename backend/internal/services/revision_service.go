@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+)
+
+// RevisionService exposes the submission snapshots of a statement and diffs
+// them, so reviewers can see what changed after a rejection.
+type RevisionService struct{ db *gorm.DB }
+
+func NewRevisionService(db *gorm.DB) *RevisionService { return &RevisionService{db: db} }
+
+// RevisionSnapshot is what a statement looked like when it was submitted.
+type RevisionSnapshot struct {
+	GrossAmount          decimal.Decimal                `json:"gross_amount"`
+	ExtraAmount          decimal.Decimal                `json:"extra_amount"`
+	DeductionAmount      decimal.Decimal                `json:"deduction_amount"`
+	RetentionAmount      decimal.Decimal                `json:"retention_amount"`
+	AdvanceRecovered     decimal.Decimal                `json:"advance_recovered"`
+	VatAmount            decimal.Decimal                `json:"vat_amount"`
+	SocialSecurityAmount decimal.Decimal                `json:"social_security_amount"`
+	LdAmount             decimal.Decimal                `json:"ld_amount"`
+	EscalationAmount     decimal.Decimal                `json:"escalation_amount"`
+	NetAmount            decimal.Decimal                `json:"net_amount"`
+	WorkDoneItems        []model.WorkDoneItem           `json:"work_done_items"`
+	ExtraWorkItems       []model.ExtraWorkItem          `json:"extra_work_items"`
+	DeductionItems       []model.StatementDeductionItem `json:"deduction_items"`
+}
+
+// StatementRevisionView is a StatementRevision with its snapshot decoded.
+type StatementRevisionView struct {
+	Revision      int              `json:"revision"`
+	SubmittedByID uuid.UUID        `json:"submitted_by_id"`
+	SubmittedAt   time.Time        `json:"submitted_at"`
+	Snapshot      RevisionSnapshot `json:"snapshot"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// LineChange is one added, removed or changed line. Work-done lines are keyed
+// by their BOQ line item, extra works and deductions by line number.
+type LineChange struct {
+	Section     string        `json:"section"` // work_done | extra_work | deduction
+	Key         string        `json:"key"`
+	Change      string        `json:"change"` // added | removed | changed
+	Description string        `json:"description"`
+	Fields      []FieldChange `json:"fields,omitempty"`
+}
+
+type RevisionDiff struct {
+	StatementID uuid.UUID     `json:"statement_id"`
+	From        int           `json:"from"`
+	To          int           `json:"to"`
+	Totals      []FieldChange `json:"totals"`
+	Lines       []LineChange  `json:"lines"`
+}
+
+func (s *RevisionService) List(ctx context.Context, statementID string) ([]StatementRevisionView, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	var revs []model.StatementRevision
+	if err := s.db.WithContext(ctx).Where("statement_id = ?", sid).
+		Order("revision ASC").
+		Find(&revs).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	views := make([]StatementRevisionView, 0, len(revs))
+	for i := range revs {
+		v, err := revisionView(&revs[i])
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, *v)
+	}
+	return views, nil
+}
+
+// Diff compares two submitted revisions. Both default to the last two
+// submissions (from = to − 1); a negative argument means "default".
+func (s *RevisionService) Diff(ctx context.Context, statementID string, from, to int) (*RevisionDiff, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	if to < 0 {
+		var latest *int
+		if err := db.Model(&model.StatementRevision{}).Where("statement_id = ?", sid).
+			Select("MAX(revision)").Scan(&latest).Error; err != nil {
+			return nil, &ServiceError{Message: "Query failed", Code: 500}
+		}
+		if latest == nil {
+			return nil, &ServiceError{Message: "Statement has not been submitted yet", Code: 404}
+		}
+		to = *latest
+	}
+	if from < 0 {
+		from = to - 1
+	}
+	if from < 0 || from >= to {
+		return nil, &ServiceError{Message: "from must be lower than to (at least two revisions are needed)", Code: 400}
+	}
+
+	var revs []model.StatementRevision
+	if err := db.Where("statement_id = ? AND revision IN ?", sid, []int{from, to}).
+		Order("revision ASC").
+		Find(&revs).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	if len(revs) != 2 {
+		return nil, &ServiceError{Message: fmt.Sprintf("Revision %d or %d not found", from, to), Code: 404}
+	}
+	a, err := revisionView(&revs[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := revisionView(&revs[1])
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &RevisionDiff{StatementID: sid, From: from, To: to, Totals: []FieldChange{}, Lines: []LineChange{}}
+	diff.Totals = diffFields(totalFields(&a.Snapshot), totalFields(&b.Snapshot))
+	diff.Lines = append(diff.Lines, diffLines("work_done", workDoneRows(a.Snapshot.WorkDoneItems), workDoneRows(b.Snapshot.WorkDoneItems))...)
+	diff.Lines = append(diff.Lines, diffLines("extra_work", extraWorkRows(a.Snapshot.ExtraWorkItems), extraWorkRows(b.Snapshot.ExtraWorkItems))...)
+	diff.Lines = append(diff.Lines, diffLines("deduction", deductionRows(a.Snapshot.DeductionItems), deductionRows(b.Snapshot.DeductionItems))...)
+	return diff, nil
+}
+
+func revisionView(rev *model.StatementRevision) (*StatementRevisionView, error) {
+	v := &StatementRevisionView{Revision: rev.Revision, SubmittedByID: rev.SubmittedByID, SubmittedAt: rev.SubmittedAt}
+	if err := json.Unmarshal([]byte(rev.Snapshot), &v.Snapshot); err != nil {
+		return nil, &ServiceError{Message: "Corrupt revision snapshot", Code: 500}
+	}
+	return v, nil
+}
+
+// snapshotRevision stores stmt as submitted under its current Revision.
+func snapshotRevision(tx *gorm.DB, stmt *model.InterimStatement, actorID uuid.UUID) error {
+	var full model.InterimStatement
+	if err := tx.Preload("WorkDoneItems", func(db *gorm.DB) *gorm.DB { return db.Order("line_no ASC") }).
+		Preload("ExtraWorkItems", func(db *gorm.DB) *gorm.DB { return db.Order("line_no ASC") }).
+		Preload("DeductionItems", func(db *gorm.DB) *gorm.DB { return db.Order("line_no ASC") }).
+		First(&full, "id = ?", stmt.ID).Error; err != nil {
+		return &ServiceError{Message: "Database error", Code: 500}
+	}
+	snap := RevisionSnapshot{
+		GrossAmount:          full.GrossAmount,
+		ExtraAmount:          full.ExtraAmount,
+		DeductionAmount:      full.DeductionAmount,
+		RetentionAmount:      full.RetentionAmount,
+		AdvanceRecovered:     full.AdvanceRecovered,
+		VatAmount:            full.VatAmount,
+		SocialSecurityAmount: full.SocialSecurityAmount,
+		LdAmount:             full.LdAmount,
+		EscalationAmount:     full.EscalationAmount,
+		NetAmount:            full.NetAmount,
+		WorkDoneItems:        full.WorkDoneItems,
+		ExtraWorkItems:       full.ExtraWorkItems,
+		DeductionItems:       full.DeductionItems,
+	}
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		return &ServiceError{Message: "Failed to snapshot statement", Code: 500}
+	}
+	rev := model.StatementRevision{
+		StatementID:   stmt.ID,
+		Revision:      full.Revision,
+		SubmittedByID: actorID,
+		SubmittedAt:   time.Now(),
+		Snapshot:      string(raw),
+	}
+	if err := tx.Create(&rev).Error; err != nil {
+		return dbErr(err)
+	}
+	return nil
+}
+
+// diffRow is a snapshot line flattened to comparable fields.
+type diffRow struct {
+	key, description string
+	fields           []FieldChange // only Field and To are used
+}
+
+func field(name string, v any) FieldChange {
+	switch x := v.(type) {
+	case decimal.Decimal:
+		return FieldChange{Field: name, To: x.String()}
+	case bool:
+		return FieldChange{Field: name, To: strconv.FormatBool(x)}
+	default:
+		return FieldChange{Field: name, To: fmt.Sprint(x)}
+	}
+}
+
+func totalFields(s *RevisionSnapshot) []FieldChange {
+	return []FieldChange{
+		field("gross_amount", s.GrossAmount),
+		field("extra_amount", s.ExtraAmount),
+		field("deduction_amount", s.DeductionAmount),
+		field("retention_amount", s.RetentionAmount),
+		field("advance_recovered", s.AdvanceRecovered),
+		field("vat_amount", s.VatAmount),
+		field("social_security_amount", s.SocialSecurityAmount),
+		field("ld_amount", s.LdAmount),
+		field("escalation_amount", s.EscalationAmount),
+		field("net_amount", s.NetAmount),
+	}
+}
+
+func workDoneRows(items []model.WorkDoneItem) []diffRow {
+	rows := make([]diffRow, 0, len(items))
+	for _, wd := range items {
+		key := "line:" + strconv.Itoa(wd.LineNo)
+		if wd.LineItemID != nil {
+			key = wd.LineItemID.String()
+		}
+		rows = append(rows, diffRow{key: key, description: wd.Description, fields: []FieldChange{
+			field("quantity", wd.Quantity),
+			field("cumulative_quantity", wd.CumulativeQuantity),
+			field("unit_price", wd.UnitPrice),
+			field("amount", wd.Amount),
+			field("correction_reason", wd.CorrectionReason),
+			field("variation_ref", wd.VariationRef),
+		}})
+	}
+	return rows
+}
+
+func extraWorkRows(items []model.ExtraWorkItem) []diffRow {
+	rows := make([]diffRow, 0, len(items))
+	for _, ew := range items {
+		rows = append(rows, diffRow{key: strconv.Itoa(ew.LineNo), description: ew.Description, fields: []FieldChange{
+			field("description", ew.Description),
+			field("unit", ew.Unit),
+			field("quantity", ew.Quantity),
+			field("unit_price", ew.UnitPrice),
+			field("amount", ew.Amount),
+			field("reason", ew.Reason),
+			field("variation_ref", ew.VariationRef),
+			field("approved_by_client", ew.ApprovedByClient),
+		}})
+	}
+	return rows
+}
+
+func deductionRows(items []model.StatementDeductionItem) []diffRow {
+	rows := make([]diffRow, 0, len(items))
+	for _, d := range items {
+		rows = append(rows, diffRow{key: strconv.Itoa(d.LineNo), description: d.Description, fields: []FieldChange{
+			field("description", d.Description),
+			field("unit", d.Unit),
+			field("quantity", d.Quantity),
+			field("unit_price", d.UnitPrice),
+			field("amount", d.Amount),
+		}})
+	}
+	return rows
+}
+
+// diffFields returns the fields whose value differs, by position.
+func diffFields(from, to []FieldChange) []FieldChange {
+	changes := []FieldChange{}
+	for i := range to {
+		if from[i].To != to[i].To {
+			changes = append(changes, FieldChange{Field: to[i].Field, From: from[i].To, To: to[i].To})
+		}
+	}
+	return changes
+}
+
+// diffLines matches rows by key: lines in to come first in their order,
+// followed by the lines that were removed.
+func diffLines(section string, from, to []diffRow) []LineChange {
+	old := make(map[string]*diffRow, len(from))
+	for i := range from {
+		old[from[i].key] = &from[i]
+	}
+	var out []LineChange
+	seen := make(map[string]bool, len(to))
+	for _, r := range to {
+		seen[r.key] = true
+		prev, ok := old[r.key]
+		if !ok {
+			out = append(out, LineChange{Section: section, Key: r.key, Change: "added", Description: r.description, Fields: r.fields})
+			continue
+		}
+		if fields := diffFields(prev.fields, r.fields); len(fields) > 0 {
+			out = append(out, LineChange{Section: section, Key: r.key, Change: "changed", Description: r.description, Fields: fields})
+		}
+	}
+	for _, r := range from {
+		if !seen[r.key] {
+			out = append(out, LineChange{Section: section, Key: r.key, Change: "removed", Description: r.description})
+		}
+	}
+	return out
+}
+
+// requireOpenContract returns 409 when the contract is closed or cancelled.
+func requireOpenContract(tx *gorm.DB, contractID uuid.UUID) error {
+	var ct model.Contract
+	if err := tx.Select("id, status").First(&ct, "id = ?", contractID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &ServiceError{Message: "Contract not found", Code: 404}
+		}
+		return &ServiceError{Message: "Database error", Code: 500}
+	}
+	if ct.Status == model.ContractClosed || ct.Status == model.ContractCancelled {
+		return &ServiceError{Message: "Cannot reopen a statement of a closed/cancelled contract", Code: 409}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import "strings"
+
+// ─── Persian text layout for the PDF report ──────────────────────────────────
+//
+// PDF has no text shaping: glyphs are drawn one after another from left to
+// right. Persian text is therefore converted to Arabic presentation forms
+// (contextual letter shapes) and reordered into visual order before drawing.
+
+// joiningForms holds the isolated, final, initial and medial presentation
+// forms of a letter. Letters that only join to the previous letter have no
+// initial or medial form.
+type joiningForms [4]rune
+
+const (
+	formIsolated = iota
+	formFinal
+	formInitial
+	formMedial
+)
+
+var arabicForms = map[rune]joiningForms{
+	'ء': {0xFE80},
+	'آ': {0xFE81, 0xFE82},
+	'أ': {0xFE83, 0xFE84},
+	'ؤ': {0xFE85, 0xFE86},
+	'إ': {0xFE87, 0xFE88},
+	'ئ': {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	'ا': {0xFE8D, 0xFE8E},
+	'ب': {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	'ة': {0xFE93, 0xFE94},
+	'ت': {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	'ث': {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	'ج': {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	'ح': {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	'خ': {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	'د': {0xFEA9, 0xFEAA},
+	'ذ': {0xFEAB, 0xFEAC},
+	'ر': {0xFEAD, 0xFEAE},
+	'ز': {0xFEAF, 0xFEB0},
+	'س': {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	'ش': {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	'ص': {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	'ض': {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	'ط': {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	'ظ': {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	'ع': {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	'غ': {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	'ـ': {'ـ', 'ـ', 'ـ', 'ـ'},
+	'ف': {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	'ق': {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	'ك': {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	'ل': {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	'م': {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	'ن': {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	'ه': {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	'و': {0xFEED, 0xFEEE},
+	'ى': {0xFEEF, 0xFEF0},
+	'ي': {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+	'پ': {0xFB56, 0xFB57, 0xFB58, 0xFB59},
+	'چ': {0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D},
+	'ژ': {0xFB8A, 0xFB8B},
+	'ک': {0xFB8E, 0xFB8F, 0xFB90, 0xFB91},
+	'گ': {0xFB92, 0xFB93, 0xFB94, 0xFB95},
+	'ی': {0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF},
+	// The embedded font has no presentation forms for heh with yeh above.
+	'ۀ': {'ۀ', 'ۀ'},
+}
+
+// lamAlef maps an alef following lam to the isolated and final ligature.
+var lamAlef = map[rune][2]rune{
+	'آ': {0xFEF5, 0xFEF6},
+	'أ': {0xFEF7, 0xFEF8},
+	'إ': {0xFEF9, 0xFEFA},
+	'ا': {0xFEFB, 0xFEFC},
+}
+
+const (
+	zwnj = '\u200c'
+	zwj  = '\u200d'
+)
+
+// isTransparent reports whether r is a combining mark that does not affect
+// the joining of its neighbours.
+func isTransparent(r rune) bool {
+	return (r >= 0x064B && r <= 0x065F) || r == 0x0670
+}
+
+// joinsPrev reports whether r connects to the letter before it.
+func joinsPrev(r rune) bool {
+	if r == zwj {
+		return true
+	}
+	f, ok := arabicForms[r]
+	return ok && f[formFinal] != 0
+}
+
+// joinsNext reports whether r connects to the letter after it.
+func joinsNext(r rune) bool {
+	if r == zwj {
+		return true
+	}
+	f, ok := arabicForms[r]
+	return ok && f[formInitial] != 0
+}
+
+// shapeArabic replaces the letters of s with their contextual presentation
+// forms and lam-alef ligatures. s stays in logical order.
+func shapeArabic(s string) string {
+	rs := []rune(s)
+	neighbour := func(i, step int) rune {
+		for j := i + step; j >= 0 && j < len(rs); j += step {
+			if !isTransparent(rs[j]) {
+				return rs[j]
+			}
+		}
+		return 0
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if r == zwnj || r == zwj {
+			continue
+		}
+		forms, ok := arabicForms[r]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		prev := joinsNext(neighbour(i, -1)) && joinsPrev(r)
+
+		if r == 'ل' && i+1 < len(rs) {
+			if lig, ok := lamAlef[rs[i+1]]; ok {
+				if prev {
+					b.WriteRune(lig[formFinal])
+				} else {
+					b.WriteRune(lig[formIsolated])
+				}
+				i++
+				continue
+			}
+		}
+
+		next := joinsNext(r) && joinsPrev(neighbour(i, 1))
+		switch {
+		case prev && next:
+			b.WriteRune(forms[formMedial])
+		case prev:
+			b.WriteRune(forms[formFinal])
+		case next:
+			b.WriteRune(forms[formInitial])
+		default:
+			b.WriteRune(forms[formIsolated])
+		}
+	}
+	return b.String()
+}
+
+// ─── Bidi ─────────────────────────────────────────────────────────────────────
+
+func isDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= '۰' && r <= '۹') || (r >= '٠' && r <= '٩')
+}
+
+func isLatin(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= 0x00C0 && r <= 0x024F)
+}
+
+// isNumberSeparator reports whether r may sit between two digits of one
+// number, as in ۱۲٬۳۴۵ or ۱۴۰۳/۰۵/۱۲.
+func isNumberSeparator(r rune) bool {
+	return strings.ContainsRune(",.٬٫/:", r)
+}
+
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+}
+
+// visualRTL reorders a line of a right-to-left paragraph into the left to
+// right order it is drawn in. Numbers and Latin text keep their reading
+// order; everything else is reversed, with brackets mirrored. This covers
+// the report's content rather than the full Unicode bidi algorithm.
+func visualRTL(s string) string {
+	rs := []rune(s)
+	n := len(rs)
+	ltr := make([]bool, n)
+
+	for i, r := range rs {
+		ltr[i] = isDigit(r) || isLatin(r)
+	}
+	for i := 1; i+1 < n; i++ {
+		if isNumberSeparator(rs[i]) && isDigit(rs[i-1]) && isDigit(rs[i+1]) {
+			ltr[i] = true
+		}
+	}
+	for i, r := range rs {
+		switch {
+		case (r == '%' || r == '٪') && i > 0 && isDigit(rs[i-1]):
+			ltr[i] = true
+		case (r == '-' || r == '+') && i+1 < n && isDigit(rs[i+1]) && (i == 0 || !ltr[i-1]):
+			ltr[i] = true
+		}
+	}
+	// Neutrals between Latin text and another left-to-right run join them,
+	// so "CN-1403" reads as written.
+	last := -1
+	for i := 0; i < n; i++ {
+		if !ltr[i] {
+			continue
+		}
+		if last >= 0 && last < i-1 && (isLatin(rs[last]) || isLatin(rs[i])) {
+			neutral := true
+			for j := last + 1; j < i; j++ {
+				if isArabic(rs[j]) {
+					neutral = false
+					break
+				}
+			}
+			if neutral {
+				for j := last + 1; j < i; j++ {
+					ltr[j] = true
+				}
+			}
+		}
+		last = i
+	}
+
+	out := make([]rune, 0, n)
+	for i := n - 1; i >= 0; {
+		if !ltr[i] {
+			r := rs[i]
+			if m, ok := mirrored[r]; ok {
+				r = m
+			}
+			out = append(out, r)
+			i--
+			continue
+		}
+		j := i
+		for j >= 0 && ltr[j] {
+			j--
+		}
+		out = append(out, rs[j+1:i+1]...)
+		i = j
+	}
+	return string(out)
+}
+
+// isArabic reports whether r is a right-to-left letter or presentation form.
+func isArabic(r rune) bool {
+	return (r >= 0x0600 && r <= 0x06FF) || (r >= 0xFB50 && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFF)
+}
+
+// rtlVisual shapes a logical line of Persian text and returns it in drawing
+// order.
+func rtlVisual(s string) string {
+	return visualRTL(shapeArabic(s))
+}
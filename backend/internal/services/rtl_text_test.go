@@ -0,0 +1,43 @@
+package services
+
+import "testing"
+
+func TestShapeArabic(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"lam-alef isolated", "لا", "ﻻ"},
+		{"lam-alef final", "سلام", "ﺳﻼﻡ"},
+		{"zwnj breaks joining", "می‌خواهم", "ﻣﯽﺧﻮﺍﻫﻢ"},
+		{"digits and latin untouched", "CN-1403", "CN-1403"},
+	}
+	for _, c := range cases {
+		if got := shapeArabic(c.in); got != c.want {
+			t.Errorf("%s: shapeArabic(%q) = %q, want %q", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestVisualRTL(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"letters reversed", "abc سلام", "مالس abc"},
+		{"latin, digits and brackets", "قرارداد CN-1403/12 (ریال)", "(لایر) CN-1403/12 دادرارق"},
+		{"persian digits keep order", "۱۲ / ۳۴", "۳۴ / ۱۲"},
+		{"signed number", "-۱٬۲۳۴", "-۱٬۲۳۴"},
+		{"percent", "۲۵% کسر", "رسک ۲۵%"},
+	}
+	for _, c := range cases {
+		if got := visualRTL(c.in); got != c.want {
+			t.Errorf("%s: visualRTL(%q) = %q, want %q", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestRTLVisual(t *testing.T) {
+	want := "(ﻝﺎﯾﺭ) CN-1403/12 ﺩﺍﺩﺭﺍﺮﻗ"
+	if got := rtlVisual("قرارداد CN-1403/12 (ریال)"); got != want {
+		t.Fatalf("rtlVisual = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SLAService stores per-stage approval SLAs, escalates documents that
+// overstay a stage, and reports how long stages take.
+type SLAService struct{ db *gorm.DB }
+
+func NewSLAService(db *gorm.DB) *SLAService { return &SLAService{db: db} }
+
+type UpsertSLAReq struct {
+	EntityType    string `json:"entity_type"`
+	Stage         string `json:"stage"`
+	DurationHours int    `json:"duration_hours"`
+	Action        string `json:"action"`      // flag (default), notify or delegate
+	EscalateTo    string `json:"escalate_to"` // position; defaults to manager
+	DelegateID    string `json:"delegate_id"` // required for delegate
+}
+
+// StageDuration summarises how long documents stayed in one stage, from the
+// ApprovalEvent that entered it to the one that left it.
+type StageDuration struct {
+	EntityType string  `json:"entity_type"`
+	Stage      string  `json:"stage"`
+	Count      int64   `json:"count"`
+	AvgHours   float64 `json:"avg_hours"`
+	P50Hours   float64 `json:"p50_hours"`
+	P90Hours   float64 `json:"p90_hours"`
+	P95Hours   float64 `json:"p95_hours"`
+	MaxHours   float64 `json:"max_hours"`
+}
+
+// slaStage reports whether documents can wait in status for an approver.
+func slaStage(entityType, status string) bool {
+	switch entityType {
+	case model.WorkflowInterimStatement:
+		s := model.StatementStatus(status)
+		return s.Valid() && s != model.StatementDraft && s != model.StatementApproved && s != model.StatementRejected
+	case model.WorkflowContract:
+		s := model.ContractStatus(status)
+		return s.Valid() && s != model.ContractDraft && s != model.ContractActive &&
+			s != model.ContractClosed && s != model.ContractCancelled
+	case model.WorkflowVariationOrder:
+		s := model.VariationStatus(status)
+		return s.Valid() && s != model.VariationDraft && s != model.VariationApproved && s != model.VariationRejected
+	case model.WorkflowExtensionOfTime:
+		s := model.ExtensionStatus(status)
+		return s.Valid() && s != model.ExtensionDraft && s != model.ExtensionApproved && s != model.ExtensionRejected
+	}
+	return false
+}
+
+func (s *SLAService) List(ctx context.Context, companyID, entityType string) ([]model.ApprovalSLA, error) {
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	q := s.db.WithContext(ctx).Where("company_id = ?", cid)
+	if entityType != "" {
+		q = q.Where("entity_type = ?", entityType)
+	}
+	var out []model.ApprovalSLA
+	if err := q.Order("entity_type ASC, stage ASC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+// Upsert creates or replaces the SLA of one stage of the caller's company.
+func (s *SLAService) Upsert(ctx context.Context, req UpsertSLAReq, callerCompanyID string, actorID uuid.UUID) (*model.ApprovalSLA, error) {
+	cid, err := uuid.Parse(callerCompanyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	if _, ok := workflowGoal[req.EntityType]; !ok {
+		return nil, &ServiceError{Message: errWorkflowEntityType, Code: 400}
+	}
+	if !slaStage(req.EntityType, req.Stage) {
+		return nil, &ServiceError{Message: fmt.Sprintf("%q is not an approval stage of %s", req.Stage, req.EntityType), Code: 400}
+	}
+	if req.DurationHours <= 0 {
+		return nil, &ServiceError{Message: "duration_hours must be positive", Code: 400}
+	}
+	action := model.EscalationAction(req.Action)
+	if req.Action == "" {
+		action = model.EscalationFlag
+	}
+	if !action.Valid() {
+		return nil, &ServiceError{Message: "action must be flag, notify or delegate", Code: 400}
+	}
+	escalateTo := model.ApproverPosition(req.EscalateTo)
+	if req.EscalateTo == "" {
+		escalateTo = model.PositionManager
+	}
+	if !escalateTo.Valid() {
+		return nil, &ServiceError{Message: "Invalid escalate_to position", Code: 400}
+	}
+
+	db := s.db.WithContext(ctx)
+	var delegateID *uuid.UUID
+	if action == model.EscalationDelegate {
+		did, err := uuid.Parse(req.DelegateID)
+		if err != nil {
+			return nil, &ServiceError{Message: "delegate_id is required for the delegate action", Code: 400}
+		}
+		var emp model.Employee
+		if err := db.Select("id").First(&emp, "id = ? AND company_id = ? AND active", did, cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, &ServiceError{Message: "Delegate must be an active employee of the company", Code: 422}
+			}
+			return nil, &ServiceError{Message: "Database error", Code: 500}
+		}
+		delegateID = &did
+	}
+
+	var sla model.ApprovalSLA
+	err = db.Where("company_id = ? AND entity_type = ? AND stage = ?", cid, req.EntityType, req.Stage).First(&sla).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		sla = model.ApprovalSLA{CompanyID: cid, EntityType: req.EntityType, Stage: req.Stage}
+	case err != nil:
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	sla.DurationHours = req.DurationHours
+	sla.Action = action
+	sla.EscalateTo = escalateTo
+	sla.DelegateID = delegateID
+	sla.CreatedByID = actorID
+	if err := db.Save(&sla).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return &sla, nil
+}
+
+// Delete removes one of the caller's company's SLAs.
+func (s *SLAService) Delete(ctx context.Context, id, callerCompanyID string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return &ServiceError{Message: "Invalid SLA ID", Code: 400}
+	}
+	cid, err := uuid.Parse(callerCompanyID)
+	if err != nil {
+		return &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	result := s.db.WithContext(ctx).Unscoped().Where("id = ? AND company_id = ?", uid, cid).Delete(&model.ApprovalSLA{})
+	if result.Error != nil {
+		return &ServiceError{Message: "Delete failed", Code: 500}
+	}
+	if result.RowsAffected == 0 {
+		return &ServiceError{Message: "SLA not found", Code: 404}
+	}
+	return nil
+}
+
+// ListEscalations returns a company's escalations, newest first; openOnly
+// keeps those whose document is still waiting in the stage.
+func (s *SLAService) ListEscalations(ctx context.Context, companyID string, openOnly bool) ([]model.ApprovalEscalation, error) {
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	q := s.db.WithContext(ctx).Where("company_id = ?", cid)
+	if openOnly {
+		q = q.Where("resolved_at IS NULL")
+	}
+	var out []model.ApprovalEscalation
+	if err := q.Order("due_at DESC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+// StageDurations reports average and percentile stage durations of a
+// company's documents. from/to ("2006-01-02") bound when the stage was entered.
+func (s *SLAService) StageDurations(ctx context.Context, companyID, entityType, from, to string) ([]StageDuration, error) {
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	args := map[string]any{"company": cid}
+	var filters []string
+	if entityType != "" {
+		filters = append(filters, "entity_type = @entity")
+		args["entity"] = entityType
+	}
+	if from != "" {
+		t := parseDate(from)
+		if t == nil {
+			return nil, &ServiceError{Message: "Invalid from (expected YYYY-MM-DD)", Code: 400}
+		}
+		filters = append(filters, "entered_at >= @from")
+		args["from"] = *t
+	}
+	if to != "" {
+		t := parseDate(to)
+		if t == nil {
+			return nil, &ServiceError{Message: "Invalid to (expected YYYY-MM-DD)", Code: 400}
+		}
+		filters = append(filters, "entered_at < @to")
+		args["to"] = t.AddDate(0, 0, 1)
+	}
+	where := ""
+	if len(filters) > 0 {
+		where = "AND " + strings.Join(filters, " AND ")
+	}
+
+	query := `
+WITH spans AS (
+	SELECT e.entity_type, e.to_status AS stage, e.created_at AS entered_at,
+	       EXTRACT(EPOCH FROM LEAD(e.created_at) OVER (
+	           PARTITION BY e.entity_type, e.entity_id ORDER BY e.created_at, e.id
+	       ) - e.created_at) / 3600.0 AS hours
+	FROM approval_events e
+	WHERE (e.entity_type = 'interim_statement' AND e.entity_id IN (SELECT id FROM interim_statements WHERE company_id = @company))
+	   OR (e.entity_type = 'contract' AND e.entity_id IN (SELECT id FROM contracts WHERE company_id = @company))
+	   OR (e.entity_type = 'variation_order' AND e.entity_id IN (SELECT id FROM variation_orders WHERE company_id = @company))
+	   OR (e.entity_type = 'extension_of_time' AND e.entity_id IN (SELECT id FROM extensions_of_time WHERE company_id = @company))
+)
+SELECT entity_type, stage, COUNT(*) AS count,
+       AVG(hours) AS avg_hours,
+       percentile_cont(0.5) WITHIN GROUP (ORDER BY hours) AS p50_hours,
+       percentile_cont(0.9) WITHIN GROUP (ORDER BY hours) AS p90_hours,
+       percentile_cont(0.95) WITHIN GROUP (ORDER BY hours) AS p95_hours,
+       MAX(hours) AS max_hours
+FROM spans
+WHERE hours IS NOT NULL ` + where + `
+GROUP BY entity_type, stage
+ORDER BY entity_type, stage`
+
+	var out []StageDuration
+	if err := s.db.WithContext(ctx).Raw(query, args).Scan(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+// ------------------------------------------------------------
+// Scheduler
+// ------------------------------------------------------------
+
+// Run scans for overdue approvals every interval until ctx is cancelled.
+func (s *SLAService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.Scan(ctx); err != nil {
+			log.Printf("[sla] scan failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[sla] escalated %d overdue approval(s)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// overdueItem is a document that entered an SLA stage before its deadline.
+type overdueItem struct {
+	EntityID             uuid.UUID
+	ProjectID            uuid.UUID
+	WorkflowDefinitionID *uuid.UUID
+	EnteredAt            time.Time
+}
+
+// overdueQueries find documents of a company waiting in a stage since before
+// a cutoff. Entry time is the latest ApprovalEvent into the current status.
+var overdueQueries = map[string]string{
+	model.WorkflowInterimStatement: `
+SELECT d.id AS entity_id, c.project_id, d.workflow_definition_id, MAX(e.created_at) AS entered_at
+FROM interim_statements d
+JOIN contracts c ON c.id = d.contract_id
+JOIN approval_events e ON e.entity_type = 'interim_statement' AND e.entity_id = d.id AND e.to_status = d.status
+WHERE d.company_id = ? AND d.status = ? AND d.deleted_at IS NULL
+GROUP BY d.id, c.project_id, d.workflow_definition_id
+HAVING MAX(e.created_at) < ?`,
+	model.WorkflowContract: `
+SELECT d.id AS entity_id, d.project_id, d.workflow_definition_id, MAX(e.created_at) AS entered_at
+FROM contracts d
+JOIN approval_events e ON e.entity_type = 'contract' AND e.entity_id = d.id AND e.to_status = d.status
+WHERE d.company_id = ? AND d.status = ? AND d.deleted_at IS NULL
+GROUP BY d.id, d.project_id, d.workflow_definition_id
+HAVING MAX(e.created_at) < ?`,
+	model.WorkflowVariationOrder: `
+SELECT d.id AS entity_id, d.project_id, d.workflow_definition_id, MAX(e.created_at) AS entered_at
+FROM variation_orders d
+JOIN approval_events e ON e.entity_type = 'variation_order' AND e.entity_id = d.id AND e.to_status = d.status
+WHERE d.company_id = ? AND d.status = ? AND d.deleted_at IS NULL
+GROUP BY d.id, d.project_id, d.workflow_definition_id
+HAVING MAX(e.created_at) < ?`,
+	model.WorkflowExtensionOfTime: `
+SELECT d.id AS entity_id, d.project_id, d.workflow_definition_id, MAX(e.created_at) AS entered_at
+FROM extensions_of_time d
+JOIN approval_events e ON e.entity_type = 'extension_of_time' AND e.entity_id = d.id AND e.to_status = d.status
+WHERE d.company_id = ? AND d.status = ? AND d.deleted_at IS NULL
+GROUP BY d.id, d.project_id, d.workflow_definition_id
+HAVING MAX(e.created_at) < ?`,
+}
+
+// Scan resolves escalations whose document has moved on, then escalates
+// every document that overstayed an SLA stage. It returns the number of new
+// escalations.
+func (s *SLAService) Scan(ctx context.Context) (int, error) {
+	db := s.db.WithContext(ctx)
+	if err := resolveEscalations(db); err != nil {
+		return 0, err
+	}
+
+	var slas []model.ApprovalSLA
+	if err := db.Find(&slas).Error; err != nil {
+		return 0, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	now := time.Now()
+	n := 0
+	for i := range slas {
+		sla := &slas[i]
+		due := time.Duration(sla.DurationHours) * time.Hour
+		var items []overdueItem
+		if err := db.Raw(overdueQueries[sla.EntityType], sla.CompanyID, sla.Stage, now.Add(-due)).
+			Scan(&items).Error; err != nil {
+			return n, &ServiceError{Message: "Query failed", Code: 500}
+		}
+		for _, it := range items {
+			created := false
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				created, err = escalate(tx, sla, it, due)
+				return err
+			}); err != nil {
+				return n, err
+			}
+			if created {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// escalate flags one overdue document and, depending on the SLA action,
+// notes the next-level head and routes the stage to the SLA delegate. It is
+// a no-op (false) when this stage entry was already escalated.
+func escalate(tx *gorm.DB, sla *model.ApprovalSLA, it overdueItem, due time.Duration) (bool, error) {
+	esc := model.ApprovalEscalation{
+		CompanyID:  sla.CompanyID,
+		EntityType: sla.EntityType,
+		EntityID:   it.EntityID,
+		Stage:      sla.Stage,
+		EnteredAt:  it.EnteredAt,
+		DueAt:      it.EnteredAt.Add(due),
+		Action:     sla.Action,
+	}
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&esc)
+	if res.Error != nil {
+		return false, dbErr(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	cols := map[string]any{}
+	if sla.Action != model.EscalationFlag {
+		head, err := positionHolder(tx, sla.CompanyID, &it.ProjectID, sla.EscalateTo)
+		if err != nil {
+			return false, err
+		}
+		if head != nil {
+			cols["escalated_to_id"] = *head
+			esc.EscalatedToID = head
+		}
+	}
+
+	if sla.Action == model.EscalationDelegate && sla.DelegateID != nil {
+		def, err := workflowFor(tx, sla.CompanyID, sla.EntityType, sla.Stage, it.WorkflowDefinitionID)
+		if err != nil {
+			return false, err
+		}
+		holder, err := stageApprover(tx, def.Steps, sla.Stage, sla.CompanyID, &it.ProjectID)
+		if err != nil {
+			return false, err
+		}
+		if holder != nil && *holder != *sla.DelegateID {
+			today := time.Now().Truncate(24 * time.Hour)
+			days := (sla.DurationHours + 23) / 24
+			entityID, projectID := it.EntityID, it.ProjectID
+			d := model.ApprovalDelegation{
+				CompanyID:   sla.CompanyID,
+				DelegatorID: *holder,
+				DelegateID:  *sla.DelegateID,
+				EntityType:  sla.EntityType,
+				EntityID:    &entityID,
+				ProjectID:   &projectID,
+				StartsOn:    today,
+				EndsOn:      today.AddDate(0, 0, days),
+				Reason:      fmt.Sprintf("SLA escalation: %s overdue since %s", sla.Stage, esc.DueAt.Format(time.RFC3339)),
+				CreatedByID: sla.CreatedByID,
+			}
+			if err := tx.Create(&d).Error; err != nil {
+				return false, dbErr(err)
+			}
+			cols["delegation_id"] = d.ID
+			esc.DelegationID = &d.ID
+		}
+	}
+
+	if len(cols) > 0 {
+		if err := tx.Model(&esc).Updates(cols).Error; err != nil {
+			return false, &ServiceError{Message: "Update failed", Code: 500}
+		}
+	}
+	err := publish(tx, &DomainEvent{
+		Type:       EventApprovalEscalated,
+		CompanyID:  esc.CompanyID,
+		EntityType: esc.EntityType,
+		EntityID:   esc.EntityID,
+		Escalation: &esc,
+	})
+	return err == nil, err
+}
+
+// resolveEscalations closes escalations whose document has had a transition
+// since entering the stage, and revokes the delegations they created.
+func resolveEscalations(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var done []model.ApprovalEscalation
+		if err := tx.Where(`resolved_at IS NULL AND EXISTS (
+			SELECT 1 FROM approval_events e
+			WHERE e.entity_type = approval_escalations.entity_type
+			  AND e.entity_id = approval_escalations.entity_id
+			  AND e.created_at > approval_escalations.entered_at)`).
+			Find(&done).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+		if len(done) == 0 {
+			return nil
+		}
+		now := time.Now()
+		ids := make([]uuid.UUID, 0, len(done))
+		var delegations []uuid.UUID
+		for _, e := range done {
+			ids = append(ids, e.ID)
+			if e.DelegationID != nil {
+				delegations = append(delegations, *e.DelegationID)
+			}
+		}
+		if err := tx.Model(&model.ApprovalEscalation{}).Where("id IN ?", ids).
+			Update("resolved_at", now).Error; err != nil {
+			return &ServiceError{Message: "Update failed", Code: 500}
+		}
+		if len(delegations) > 0 {
+			if err := tx.Model(&model.ApprovalDelegation{}).
+				Where("id IN ? AND revoked_at IS NULL", delegations).
+				Update("revoked_at", now).Error; err != nil {
+				return &ServiceError{Message: "Update failed", Code: 500}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,491 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+)
+
+// StatementImportService reads interim statement sheets prepared on site:
+// works done keyed by BOQ item code or line item ID, extra works and
+// deductions.
+type StatementImportService struct {
+	db         *gorm.DB
+	statements *StatementService
+}
+
+func NewStatementImportService(db *gorm.DB) *StatementImportService {
+	return &StatementImportService{db: db, statements: NewStatementService(db)}
+}
+
+var worksDoneFields = []importField{
+	{"item_code", false, []string{"item_code", "item code", "code", "boq_item_code", "کد", "کد فهرست بها", "شماره ردیف", "شماره آیتم"}},
+	{"line_item_id", false, []string{"line_item_id", "line item id", "id", "شناسه"}},
+	{"quantity", true, []string{"quantity", "quantity_done", "qty", "مقدار", "مقدار کارکرد", "کارکرد"}},
+	{"correction_reason", false, []string{"correction_reason", "correction reason", "علت اصلاح"}},
+	{"variation_ref", false, []string{"variation_ref", "variation ref", "شماره دستور تغییر"}},
+}
+
+var extraWorkFields = []importField{
+	{"description", true, []string{"description", "شرح", "شرح کار", "شرح عملیات"}},
+	{"unit", false, []string{"unit", "واحد"}},
+	{"quantity", true, []string{"quantity", "qty", "مقدار"}},
+	{"unit_price", true, []string{"unit_price", "unit price", "rate", "بهای واحد", "فی", "قیمت واحد"}},
+	{"reason", false, []string{"reason", "علت", "دلیل"}},
+	{"variation_ref", false, []string{"variation_ref", "variation ref", "شماره دستور تغییر"}},
+	{"approval_ref", false, []string{"approval_ref", "approval ref", "شماره تأیید", "شماره تایید"}},
+	{"approved_by_client", false, []string{"approved_by_client", "approved by client", "تأیید کارفرما", "تایید کارفرما"}},
+}
+
+var deductionFields = []importField{
+	{"description", true, []string{"description", "شرح", "شرح کسورات"}},
+	{"unit", false, []string{"unit", "واحد"}},
+	{"quantity", true, []string{"quantity", "qty", "مقدار"}},
+	{"unit_price", true, []string{"unit_price", "unit price", "rate", "بهای واحد", "فی", "قیمت واحد", "مبلغ واحد"}},
+}
+
+// StatementImportOptions controls how a statement sheet is read.
+type StatementImportOptions struct {
+	Format    string            // "xlsx" or "csv"
+	Sheet     string            // xlsx only; defaults to the first sheet
+	HeaderRow int               // 1-based; defaults to 1
+	Mapping   map[string]string // field → column letter or header text
+	DryRun    bool
+}
+
+// WorksDoneMatch is a sheet row matched to a contract line item.
+type WorksDoneMatch struct {
+	Line             int             `json:"line"`
+	LineItemID       uuid.UUID       `json:"line_item_id"`
+	ItemCode         string          `json:"item_code,omitempty"`
+	Description      string          `json:"description"`
+	Unit             string          `json:"unit"`
+	Quantity         decimal.Decimal `json:"quantity"`
+	CorrectionReason string          `json:"correction_reason,omitempty"`
+	VariationRef     string          `json:"variation_ref,omitempty"`
+}
+
+// WorksDoneImportResult is the match report. Overruns are those SetWorksDone
+// would report; blocked ones fail the import. Statement is set once applied.
+type WorksDoneImportResult struct {
+	DryRun    bool                    `json:"dry_run"`
+	Columns   map[string]string       `json:"columns"`
+	Rows      int                     `json:"rows"`
+	Skipped   int                     `json:"skipped"`
+	Matched   []WorksDoneMatch        `json:"matched"`
+	Errors    []ImportRowError        `json:"errors,omitempty"`
+	Overruns  []QuantityOverrun       `json:"overruns,omitempty"`
+	Statement *model.InterimStatement `json:"statement,omitempty"`
+}
+
+// ImportWorksDone matches each row to a line item of the statement's
+// contract, by line_item_id when the row has one, otherwise by item_code.
+// Rows without a quantity are skipped. A dry run returns the match report;
+// otherwise the matched rows replace the statement's works done through
+// SetWorksDone, and any row error fails the whole import.
+func (s *StatementImportService) ImportWorksDone(ctx context.Context, statementID string, r io.Reader, opts StatementImportOptions) (*WorksDoneImportResult, error) {
+	stmt, err := s.draftStatement(s.db.WithContext(ctx), statementID)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := readImportSheet(r, opts.Format, opts.Sheet, opts.HeaderRow, opts.Mapping, worksDoneFields)
+	if err != nil {
+		return nil, err
+	}
+	_, byCode := sheet.cols["item_code"]
+	_, byID := sheet.cols["line_item_id"]
+	if !byCode && !byID {
+		return nil, &ServiceError{Message: "Map an item_code or line_item_id column", Code: 400}
+	}
+
+	var ct model.Contract
+	if err := s.db.WithContext(ctx).First(&ct, "id = ?", stmt.ContractID).Error; err != nil {
+		return nil, &ServiceError{Message: "Contract not found", Code: 500}
+	}
+	var lines []model.ContractLineItem
+	if err := s.db.WithContext(ctx).Where("contract_id = ?", ct.ID).Find(&lines).Error; err != nil {
+		return nil, &ServiceError{Message: "Failed to load line items", Code: 500}
+	}
+	lineByID := make(map[uuid.UUID]*model.ContractLineItem, len(lines))
+	lineByCode := make(map[string]*model.ContractLineItem, len(lines))
+	for i := range lines {
+		lineByID[lines[i].ID] = &lines[i]
+		if lines[i].ItemCode != "" {
+			lineByCode[lines[i].ItemCode] = &lines[i]
+		}
+	}
+	prevCum, err := approvedCumulative(s.db.WithContext(ctx), ct.ID, stmt.SequenceNo)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WorksDoneImportResult{DryRun: opts.DryRun, Columns: sheet.columns, Matched: []WorksDoneMatch{}}
+	seen := make(map[uuid.UUID]int) // line item → sheet line it first appeared on
+	var preview []model.WorkDoneItem
+	for i := range sheet.rows {
+		if sheet.blank(i) {
+			continue
+		}
+		result.Rows++
+		line := sheet.line(i)
+		qtyRaw := sheet.cell(i, "quantity")
+		if qtyRaw == "" {
+			result.Skipped++
+			continue
+		}
+
+		var li *model.ContractLineItem
+		rawID, code := sheet.cell(i, "line_item_id"), normalizeDigits(sheet.cell(i, "item_code"))
+		switch {
+		case rawID != "":
+			if id, err := uuid.Parse(rawID); err != nil {
+				result.Errors = append(result.Errors, ImportRowError{Line: line, Message: fmt.Sprintf("invalid line_item_id %q", rawID)})
+				continue
+			} else if li = lineByID[id]; li == nil {
+				result.Errors = append(result.Errors, ImportRowError{Line: line, Message: fmt.Sprintf("line_item_id %s is not on this contract", id)})
+				continue
+			}
+		case code != "":
+			if li = lineByCode[code]; li == nil {
+				result.Errors = append(result.Errors, ImportRowError{Line: line, Message: fmt.Sprintf("item_code %s is not on this contract", code)})
+				continue
+			}
+		default:
+			result.Errors = append(result.Errors, ImportRowError{Line: line, Message: "no item_code or line_item_id"})
+			continue
+		}
+
+		var msgs []string
+		if li.IsGroup {
+			msgs = append(msgs, fmt.Sprintf("%s is a group line", li.ItemCode))
+		}
+		if first, dup := seen[li.ID]; dup {
+			msgs = append(msgs, fmt.Sprintf("line item repeats line %d", first))
+		} else {
+			seen[li.ID] = line
+		}
+		qty, err := parseImportDecimal(qtyRaw)
+		if err != nil || qty.IsNegative() {
+			msgs = append(msgs, fmt.Sprintf("invalid quantity %q", qtyRaw))
+		}
+		m := WorksDoneMatch{
+			Line:             line,
+			LineItemID:       li.ID,
+			ItemCode:         li.ItemCode,
+			Description:      li.Description,
+			Unit:             li.Unit,
+			Quantity:         qty,
+			CorrectionReason: sheet.cell(i, "correction_reason"),
+			VariationRef:     sheet.cell(i, "variation_ref"),
+		}
+		wd := model.WorkDoneItem{
+			LineItemID:       &m.LineItemID,
+			Description:      li.Description,
+			UnitPrice:        contractRate(&ct, li.UnitRate),
+			CorrectionReason: m.CorrectionReason,
+			VariationRef:     m.VariationRef,
+		}
+		if ct.CumulativeStatements {
+			wd.CumulativeQuantity = qty
+		} else {
+			wd.Quantity = qty
+		}
+		if len(msgs) == 0 && !rebaseWorkDone(&wd, prevCum[li.ID], ct.CumulativeStatements) {
+			msgs = append(msgs, fmt.Sprintf("below the previously approved quantity %s; correction_reason required", prevCum[li.ID]))
+		}
+		if len(msgs) > 0 {
+			result.Errors = append(result.Errors, ImportRowError{Line: line, Message: strings.Join(msgs, "; ")})
+			continue
+		}
+		result.Matched = append(result.Matched, m)
+		preview = append(preview, wd)
+	}
+
+	// checkOverruns returns the overruns alongside its 422 when a line is blocked.
+	overruns, overrunErr := checkOverruns(s.db.WithContext(ctx), &ct, preview)
+	result.Overruns = overruns
+	if opts.DryRun {
+		return result, nil
+	}
+	if len(result.Errors) > 0 {
+		return nil, &ServiceError{
+			Message: fmt.Sprintf("%d invalid row(s); nothing imported", len(result.Errors)),
+			Code:    422,
+			Errors:  result.Errors,
+		}
+	}
+	if overrunErr != nil {
+		return nil, overrunErr
+	}
+	if len(result.Matched) == 0 {
+		return nil, &ServiceError{Message: "The file contains no quantities", Code: 400}
+	}
+
+	req := SetWorksDoneReq{Items: make([]WorksDoneItem, len(result.Matched))}
+	for i, m := range result.Matched {
+		req.Items[i] = WorksDoneItem{
+			LineItemID:       m.LineItemID.String(),
+			QuantityDone:     m.Quantity.String(),
+			CorrectionReason: m.CorrectionReason,
+			VariationRef:     m.VariationRef,
+		}
+	}
+	if result.Statement, result.Overruns, err = s.statements.SetWorksDone(ctx, statementID, req); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StatementLinesImportResult reports an extra works or deductions import.
+// Items are the rows to be appended (dry run) or appended.
+type StatementLinesImportResult struct {
+	DryRun   bool              `json:"dry_run"`
+	Columns  map[string]string `json:"columns"`
+	Rows     int               `json:"rows"`
+	Imported int               `json:"imported"`
+	Total    decimal.Decimal   `json:"total"`
+	Errors   []ImportRowError  `json:"errors,omitempty"`
+	Items    any               `json:"items"`
+}
+
+// ImportExtraWorks appends a sheet of extra works to a draft statement in one
+// transaction. A variation_ref naming an approved variation order of the
+// contract links the order and implies client approval, as in AddExtraWork.
+func (s *StatementImportService) ImportExtraWorks(ctx context.Context, statementID string, r io.Reader, opts StatementImportOptions) (*StatementLinesImportResult, error) {
+	sheet, err := readImportSheet(r, opts.Format, opts.Sheet, opts.HeaderRow, opts.Mapping, extraWorkFields)
+	if err != nil {
+		return nil, err
+	}
+	result := &StatementLinesImportResult{DryRun: opts.DryRun, Columns: sheet.columns, Total: decimal.Zero}
+	var items []model.ExtraWorkItem
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stmt, err := s.draftStatement(tx, statementID)
+		if err != nil {
+			return err
+		}
+		var vos []model.VariationOrder
+		if err := tx.Select("id, reference").
+			Where("contract_id = ? AND status = ?", stmt.ContractID, model.VariationApproved).
+			Find(&vos).Error; err != nil {
+			return &ServiceError{Message: "Failed to load variation orders", Code: 500}
+		}
+		voByRef := make(map[string]uuid.UUID, len(vos))
+		for _, vo := range vos {
+			voByRef[vo.Reference] = vo.ID
+		}
+
+		for i := range sheet.rows {
+			if sheet.blank(i) {
+				continue
+			}
+			result.Rows++
+			desc, unit, qty, price, msgs := statementLine(sheet, i)
+			approved, err := parseImportBool(sheet.cell(i, "approved_by_client"))
+			if err != nil {
+				msgs = append(msgs, err.Error())
+			}
+			if len(msgs) > 0 {
+				result.Errors = append(result.Errors, ImportRowError{Line: sheet.line(i), Message: strings.Join(msgs, "; ")})
+				continue
+			}
+			ew := model.ExtraWorkItem{
+				StatementID:      stmt.ID,
+				Description:      desc,
+				Unit:             unit,
+				Quantity:         qty,
+				UnitPrice:        price,
+				Amount:           qty.Mul(price),
+				Reason:           sheet.cell(i, "reason"),
+				VariationRef:     sheet.cell(i, "variation_ref"),
+				ApprovedByClient: approved,
+				ApprovalRef:      sheet.cell(i, "approval_ref"),
+			}
+			if id, ok := voByRef[ew.VariationRef]; ok && ew.VariationRef != "" {
+				ew.VariationOrderID = &id
+				ew.ApprovedByClient = true
+			}
+			items = append(items, ew)
+			result.Total = result.Total.Add(ew.Amount)
+		}
+
+		var maxLine int
+		tx.Model(&model.ExtraWorkItem{}).Where("statement_id = ?", stmt.ID).
+			Select("COALESCE(MAX(line_no), 0)").Scan(&maxLine)
+		for i := range items {
+			items[i].LineNo = maxLine + i + 1
+		}
+		if err := s.checkLinesImport(result, len(items), opts.DryRun); err != nil || opts.DryRun {
+			return err
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			return dbErr(err)
+		}
+		stmt.ExtraWorkItems = append(stmt.ExtraWorkItems, items...)
+		return s.recompute(tx, stmt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []model.ExtraWorkItem{}
+	}
+	result.Items = items
+	if !opts.DryRun {
+		result.Imported = len(items)
+	}
+	return result, nil
+}
+
+// ImportDeductions appends a sheet of deductions to a draft statement in one
+// transaction.
+func (s *StatementImportService) ImportDeductions(ctx context.Context, statementID string, r io.Reader, opts StatementImportOptions) (*StatementLinesImportResult, error) {
+	sheet, err := readImportSheet(r, opts.Format, opts.Sheet, opts.HeaderRow, opts.Mapping, deductionFields)
+	if err != nil {
+		return nil, err
+	}
+	result := &StatementLinesImportResult{DryRun: opts.DryRun, Columns: sheet.columns, Total: decimal.Zero}
+	var items []model.StatementDeductionItem
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stmt, err := s.draftStatement(tx, statementID)
+		if err != nil {
+			return err
+		}
+		for i := range sheet.rows {
+			if sheet.blank(i) {
+				continue
+			}
+			result.Rows++
+			desc, unit, qty, price, msgs := statementLine(sheet, i)
+			if len(msgs) > 0 {
+				result.Errors = append(result.Errors, ImportRowError{Line: sheet.line(i), Message: strings.Join(msgs, "; ")})
+				continue
+			}
+			d := model.StatementDeductionItem{
+				StatementID: stmt.ID,
+				Description: desc,
+				Unit:        unit,
+				Quantity:    qty,
+				UnitPrice:   price,
+				Amount:      qty.Mul(price),
+			}
+			items = append(items, d)
+			result.Total = result.Total.Add(d.Amount)
+		}
+
+		var maxLine int
+		tx.Model(&model.StatementDeductionItem{}).Where("statement_id = ?", stmt.ID).
+			Select("COALESCE(MAX(line_no), 0)").Scan(&maxLine)
+		for i := range items {
+			items[i].LineNo = maxLine + i + 1
+		}
+		if err := s.checkLinesImport(result, len(items), opts.DryRun); err != nil || opts.DryRun {
+			return err
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			return dbErr(err)
+		}
+		stmt.DeductionItems = append(stmt.DeductionItems, items...)
+		return s.recompute(tx, stmt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []model.StatementDeductionItem{}
+	}
+	result.Items = items
+	if !opts.DryRun {
+		result.Imported = len(items)
+	}
+	return result, nil
+}
+
+// draftStatement loads the statement with its lines and requires it to be a draft.
+func (s *StatementImportService) draftStatement(tx *gorm.DB, statementID string) (*model.InterimStatement, error) {
+	sid, err := uuid.Parse(statementID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+	}
+	var stmt model.InterimStatement
+	if err := tx.Preload("WorkDoneItems").Preload("ExtraWorkItems").Preload("DeductionItems").
+		First(&stmt, "id = ?", sid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Statement not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if stmt.Status != model.StatementDraft {
+		return nil, &ServiceError{Message: "Only draft statements can be edited", Code: 422}
+	}
+	return &stmt, nil
+}
+
+func (s *StatementImportService) recompute(tx *gorm.DB, stmt *model.InterimStatement) error {
+	var ct model.Contract
+	if err := tx.First(&ct, "id = ?", stmt.ContractID).Error; err != nil {
+		return &ServiceError{Message: "Contract not found", Code: 500}
+	}
+	return recompute(tx, stmt, &ct)
+}
+
+// checkLinesImport applies the all-or-nothing rule; a dry run reports errors
+// in the result instead.
+func (s *StatementImportService) checkLinesImport(result *StatementLinesImportResult, n int, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+	if len(result.Errors) > 0 {
+		return &ServiceError{
+			Message: fmt.Sprintf("%d invalid row(s); nothing imported", len(result.Errors)),
+			Code:    422,
+			Errors:  result.Errors,
+		}
+	}
+	if n == 0 {
+		return &ServiceError{Message: "The file contains no rows", Code: 400}
+	}
+	return nil
+}
+
+// statementLine reads the description, unit, quantity and unit price shared
+// by extra work and deduction rows.
+func statementLine(sheet *importSheet, i int) (desc, unit string, qty, price decimal.Decimal, msgs []string) {
+	desc, unit = sheet.cell(i, "description"), sheet.cell(i, "unit")
+	if desc == "" {
+		msgs = append(msgs, "description is required")
+	}
+	if len(unit) > 32 {
+		msgs = append(msgs, "unit is longer than 32 characters")
+	}
+	var err error
+	raw := sheet.cell(i, "quantity")
+	if qty, err = parseImportDecimal(raw); err != nil || qty.IsNegative() {
+		msgs = append(msgs, fmt.Sprintf("invalid quantity %q", raw))
+	}
+	raw = sheet.cell(i, "unit_price")
+	if price, err = parseImportDecimal(raw); err != nil || price.IsNegative() {
+		msgs = append(msgs, fmt.Sprintf("invalid unit_price %q", raw))
+	}
+	return
+}
+
+// parseImportBool accepts the yes/no spellings found in site sheets; an
+// empty cell is false.
+func parseImportBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(normalizeDigits(s))) {
+	case "":
+		return false, nil
+	case "1", "true", "yes", "y", "x", "✓", "بله", "بلی", "دارد":
+		return true, nil
+	case "0", "false", "no", "n", "خیر", "ندارد":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid approved_by_client %q", s)
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -35,8 +37,12 @@ type UpdateStatementReq struct {
 // WorksDoneItem — user supplies line_item_id (WBS ref) and accumulated quantity_done.
 // Description/unit/unit_price are copied from the ContractLineItem.
 type WorksDoneItem struct {
-	LineItemID   string `json:"line_item_id"`
-	QuantityDone string `json:"quantity_done"`
+	LineItemID string `json:"line_item_id"`
+	// QuantityDone is the period quantity, or the to-date quantity when the
+	// contract is in cumulative mode.
+	QuantityDone     string `json:"quantity_done"`
+	CorrectionReason string `json:"correction_reason"`
+	VariationRef     string `json:"variation_ref"`
 }
 
 type SetWorksDoneReq struct {
@@ -52,6 +58,9 @@ type CreateExtraWorkReq struct {
 	VariationRef     string `json:"variation_ref"`
 	ApprovedByClient bool   `json:"approved_by_client"`
 	ApprovalRef      string `json:"approval_ref"`
+	// VariationOrderID links an approved variation order of the contract;
+	// it implies client approval.
+	VariationOrderID string `json:"variation_order_id"`
 }
 
 type CreateDeductionReq struct {
@@ -102,6 +111,9 @@ func (s *StatementService) Create(ctx context.Context, contractID string, req Cr
 			}
 			return &ServiceError{Message: "Database error", Code: 500}
 		}
+		if ct.Status == model.ContractClosed || ct.Status == model.ContractCancelled {
+			return &ServiceError{Message: "Cannot add statements to a closed/cancelled contract", Code: 409}
+		}
 
 		var maxSeq int
 		tx.Model(&model.InterimStatement{}).
@@ -163,7 +175,7 @@ func (s *StatementService) GetByID(ctx context.Context, id string) (*model.Inter
 	return &stmt, nil
 }
 
-func (s *StatementService) ListByContract(ctx context.Context, contractID, status string, page, limit int) ([]model.InterimStatement, int64, error) {
+func (s *StatementService) ListByContract(ctx context.Context, contractID, status, paymentStatus string, page, limit int) ([]model.InterimStatement, int64, error) {
 	q := s.db.WithContext(ctx).Model(&model.InterimStatement{})
 	if contractID != "" {
 		if cid, err := uuid.Parse(contractID); err == nil {
@@ -173,6 +185,9 @@ func (s *StatementService) ListByContract(ctx context.Context, contractID, statu
 	if status != "" {
 		q = q.Where("status = ?", status)
 	}
+	if paymentStatus != "" {
+		q = q.Where("status = ? AND payment_status = ?", model.StatementApproved, paymentStatus)
+	}
 	var total int64
 	if err := q.Count(&total).Error; err != nil {
 		return nil, 0, &ServiceError{Message: "Count failed", Code: 500}
@@ -184,15 +199,65 @@ func (s *StatementService) ListByContract(ctx context.Context, contractID, statu
 	return items, total, nil
 }
 
+// --------------- Aggregates ---------------
+
+// aggregateCols returns the cached aggregate columns of stmt for persisting.
+func aggregateCols(stmt *model.InterimStatement) map[string]any {
+	return map[string]any{
+		"gross_amount":           stmt.GrossAmount,
+		"extra_amount":           stmt.ExtraAmount,
+		"deduction_amount":       stmt.DeductionAmount,
+		"retention_amount":       stmt.RetentionAmount,
+		"advance_recovered":      stmt.AdvanceRecovered,
+		"vat_amount":             stmt.VatAmount,
+		"social_security_amount": stmt.SocialSecurityAmount,
+		"ld_amount":              stmt.LdAmount,
+		"escalation_amount":      stmt.EscalationAmount,
+		"net_amount":             stmt.NetAmount,
+		"progress_pct":           stmt.ProgressPct,
+	}
+}
+
+// recompute refreshes the cached aggregates of stmt (children must be loaded)
+// against the contract terms, the live advance balance, the non-waived
+// liquidated damages and the price escalation, then persists them and
+// publishes statement.recomputed for live clients.
+func recompute(tx *gorm.DB, stmt *model.InterimStatement, ct *model.Contract) error {
+	stmt.LdAmount = ldTotal(tx, stmt.ID)
+	if err := computeEscalation(tx, stmt, ct); err != nil {
+		return err
+	}
+	stmt.Recompute(
+		ct.RetentionPctBps, ct.AdvancePctBps,
+		ct.VatPctBps, ct.SocialSecurityPctBps,
+		ct.ManagementFeePctBps,
+		advanceOutstanding(tx, ct.ID), ct.GrossBudget,
+	)
+	if err := tx.Model(stmt).Updates(aggregateCols(stmt)).Error; err != nil {
+		return &ServiceError{Message: "Failed to update aggregates", Code: 500}
+	}
+	return publish(tx, &DomainEvent{
+		Type:       EventStatementRecomputed,
+		CompanyID:  stmt.CompanyID,
+		EntityType: model.WorkflowInterimStatement,
+		EntityID:   stmt.ID,
+		Statement:  stmt,
+	})
+}
+
 // --------------- Works Done ---------------
 
-func (s *StatementService) SetWorksDone(ctx context.Context, statementID string, req SetWorksDoneReq) (*model.InterimStatement, error) {
+// SetWorksDone replaces the statement's works done. The returned overruns are
+// lines certified above their contracted quantity but within tolerance
+// (warnings); lines above tolerance fail the call with 422.
+func (s *StatementService) SetWorksDone(ctx context.Context, statementID string, req SetWorksDoneReq) (*model.InterimStatement, []QuantityOverrun, error) {
 	sid, err := uuid.Parse(statementID)
 	if err != nil {
-		return nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
+		return nil, nil, &ServiceError{Message: "Invalid statement ID", Code: 400}
 	}
 
 	var stmt model.InterimStatement
+	var overruns []QuantityOverrun
 	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.
 			Preload("WorkDoneItems").
@@ -217,7 +282,13 @@ func (s *StatementService) SetWorksDone(ctx context.Context, statementID string,
 			return &ServiceError{Message: "Failed to clear existing works done", Code: 500}
 		}
 
+		prevCum, err := approvedCumulative(tx, ct.ID, stmt.SequenceNo)
+		if err != nil {
+			return err
+		}
+
 		newItems := make([]model.WorkDoneItem, 0, len(req.Items))
+		var decreased []string
 		lineNo := 1
 		for _, item := range req.Items {
 			liID, err := uuid.Parse(item.LineItemID)
@@ -225,36 +296,53 @@ func (s *StatementService) SetWorksDone(ctx context.Context, statementID string,
 				continue
 			}
 			qty, err := decimal.NewFromString(item.QuantityDone)
-			if err != nil || qty.LessThanOrEqual(decimal.Zero) {
+			if err != nil || qty.IsNegative() {
+				continue
+			}
+			prev := prevCum[liID]
+			if qty.IsZero() && (!ct.CumulativeStatements || prev.IsZero()) {
 				continue
 			}
 
 			// Fetch WBS item to get description/unit/unit_rate.
 			var wbs model.ContractLineItem
-			if err := tx.First(&wbs, "id = ?", liID).Error; err != nil {
-				continue // skip invalid refs
+			if err := tx.First(&wbs, "id = ? AND is_group = false", liID).Error; err != nil {
+				continue // skip invalid refs and group lines
 			}
 
 			// For unit_rate contracts apply contract_coefficient so the effective price
 			// reflects the competitive adjustment from the tender (ضریب پیمان).
-			effectiveRate := wbs.UnitRate
-			if ct.Type == model.ContractUnitRate {
-				effectiveRate = effectiveRate.Mul(ct.ContractCoefficient)
-			}
-
-			newItems = append(newItems, model.WorkDoneItem{
-				StatementID: sid,
-				LineItemID:  &liID,
-				LineNo:      lineNo,
-				BoQItemCode: wbs.ID.String(),
-				Description: wbs.Description,
-				UnitCode:    wbs.Unit,
-				Quantity:    qty,
-				UnitPrice:   effectiveRate,
-				Amount:      qty.Mul(effectiveRate),
-			})
+			effectiveRate := contractRate(&ct, wbs.UnitRate)
+
+			wd := model.WorkDoneItem{
+				StatementID:      sid,
+				LineItemID:       &liID,
+				LineNo:           lineNo,
+				BoQItemCode:      wbs.ItemCode,
+				Description:      wbs.Description,
+				UnitCode:         wbs.Unit,
+				UnitPrice:        effectiveRate,
+				CorrectionReason: strings.TrimSpace(item.CorrectionReason),
+				VariationRef:     strings.TrimSpace(item.VariationRef),
+			}
+			if ct.CumulativeStatements {
+				wd.CumulativeQuantity = qty
+			} else {
+				wd.Quantity = qty
+			}
+			if !rebaseWorkDone(&wd, prev, ct.CumulativeStatements) {
+				decreased = append(decreased, wbs.Description)
+				continue
+			}
+			newItems = append(newItems, wd)
 			lineNo++
 		}
+		if len(decreased) > 0 {
+			return cumulativeDecreaseErr(decreased)
+		}
+		if overruns, err = checkOverruns(tx, &ct, newItems); err != nil {
+			return err
+		}
 		if len(newItems) > 0 {
 			if err := tx.Create(&newItems).Error; err != nil {
 				return dbErr(err)
@@ -262,41 +350,200 @@ func (s *StatementService) SetWorksDone(ctx context.Context, statementID string,
 		}
 
 		stmt.WorkDoneItems = newItems
-
-		var advanceOutstanding decimal.Decimal
-		tx.Model(&model.AdvancePaymentRecord{}).
-			Where("contract_id = ? AND record_type = 'advance'", ct.ID).
-			Select("COALESCE(SUM(outstanding_balance), 0)").
-			Scan(&advanceOutstanding)
-
-		grossBudget, _ := decimal.NewFromString(ct.GrossBudget.String())
-		stmt.Recompute(
-			ct.RetentionPctBps, ct.AdvancePctBps,
-			ct.VatPctBps, ct.SocialSecurityPctBps,
-			ct.ManagementFeePctBps,
-			advanceOutstanding, grossBudget,
-		)
-
-		updates := map[string]any{
-			"gross_amount":           stmt.GrossAmount,
-			"extra_amount":           stmt.ExtraAmount,
-			"deduction_amount":       stmt.DeductionAmount,
-			"retention_amount":       stmt.RetentionAmount,
-			"advance_recovered":      stmt.AdvanceRecovered,
-			"vat_amount":             stmt.VatAmount,
-			"social_security_amount": stmt.SocialSecurityAmount,
-			"net_amount":             stmt.NetAmount,
-			"progress_pct":           stmt.ProgressPct,
-		}
-		if err := tx.Model(&stmt).Updates(updates).Error; err != nil {
-			return &ServiceError{Message: "Failed to update aggregates", Code: 500}
-		}
-		return nil
+		return recompute(tx, &stmt, &ct)
 	})
 	if txErr != nil {
-		return nil, txErr
+		return nil, nil, txErr
 	}
-	return &stmt, nil
+	return &stmt, overruns, nil
+}
+
+// --------------- Cumulative quantities ---------------
+
+// approvedCumulative returns, per line item, the quantity certified to date by
+// the approved statements of a contract that precede sequence beforeSeq.
+// Work-done quantities are always period deltas, so the sum is the to-date
+// quantity in both statement modes.
+func approvedCumulative(tx *gorm.DB, contractID uuid.UUID, beforeSeq int) (map[uuid.UUID]decimal.Decimal, error) {
+	var rows []struct {
+		LineItemID uuid.UUID
+		Quantity   decimal.Decimal
+	}
+	if err := tx.Table("work_done_items AS w").
+		Select("w.line_item_id, COALESCE(SUM(w.quantity), 0) AS quantity").
+		Joins("JOIN interim_statements s ON s.id = w.statement_id AND s.deleted_at IS NULL").
+		Where("s.contract_id = ? AND s.status = ? AND s.sequence_no < ?", contractID, model.StatementApproved, beforeSeq).
+		Where("w.line_item_id IS NOT NULL AND w.deleted_at IS NULL").
+		Group("w.line_item_id").
+		Scan(&rows).Error; err != nil {
+		return nil, &ServiceError{Message: "Failed to load cumulative quantities", Code: 500}
+	}
+	cum := make(map[uuid.UUID]decimal.Decimal, len(rows))
+	for _, r := range rows {
+		cum[r.LineItemID] = r.Quantity
+	}
+	return cum, nil
+}
+
+// rebaseWorkDone fills the period and cumulative columns of wd against prev,
+// the to-date quantity before this statement. In cumulative mode
+// CumulativeQuantity is the input and the period quantity is derived;
+// otherwise Quantity is the input. It reports false when a cumulative
+// quantity goes down without a CorrectionReason.
+func rebaseWorkDone(wd *model.WorkDoneItem, prev decimal.Decimal, cumulative bool) bool {
+	wd.PrevCumulativeQuantity = prev
+	if cumulative {
+		wd.Quantity = wd.CumulativeQuantity.Sub(prev)
+		if wd.Quantity.IsNegative() && wd.CorrectionReason == "" {
+			return false
+		}
+	} else {
+		wd.CumulativeQuantity = prev.Add(wd.Quantity)
+	}
+	wd.Amount = wd.Quantity.Mul(wd.UnitPrice)
+	return true
+}
+
+func cumulativeDecreaseErr(lines []string) error {
+	return &ServiceError{
+		Message: "Cumulative quantity is below the previously approved quantity (correction_reason required): " + strings.Join(lines, "، "),
+		Code:    422,
+	}
+}
+
+// rebaseStatementWorkDone re-derives the period quantities of stmt against the
+// statements approved so far. Run at approval: an earlier statement may have
+// been approved after this one was drafted.
+func rebaseStatementWorkDone(tx *gorm.DB, stmt *model.InterimStatement, ct *model.Contract) error {
+	prevCum, err := approvedCumulative(tx, ct.ID, stmt.SequenceNo)
+	if err != nil {
+		return err
+	}
+	var decreased []string
+	for i := range stmt.WorkDoneItems {
+		wd := &stmt.WorkDoneItems[i]
+		if wd.LineItemID == nil {
+			continue
+		}
+		if !rebaseWorkDone(wd, prevCum[*wd.LineItemID], ct.CumulativeStatements) {
+			decreased = append(decreased, wd.Description)
+			continue
+		}
+		if err := tx.Model(wd).Updates(map[string]any{
+			"quantity":                 wd.Quantity,
+			"amount":                   wd.Amount,
+			"prev_cumulative_quantity": wd.PrevCumulativeQuantity,
+			"cumulative_quantity":      wd.CumulativeQuantity,
+		}).Error; err != nil {
+			return &ServiceError{Message: "Failed to update works done", Code: 500}
+		}
+	}
+	if len(decreased) > 0 {
+		return cumulativeDecreaseErr(decreased)
+	}
+	_, err = checkOverruns(tx, ct, stmt.WorkDoneItems)
+	return err
+}
+
+// --------------- Quantity overrun ---------------
+
+const (
+	defaultQuantityTolerancePctBps = 12500
+	errQuantityTolerance           = "quantity_tolerance_pct_bps must be at least 10000 (100%)"
+)
+
+// QuantityOverrun describes a BOQ line whose cumulative certified quantity is
+// above its contracted quantity. Blocked lines are above the contract's
+// tolerance without an approved variation.
+type QuantityOverrun struct {
+	LineItemID         uuid.UUID       `json:"line_item_id"`
+	Description        string          `json:"description"`
+	ContractQuantity   decimal.Decimal `json:"contract_quantity"`
+	CumulativeQuantity decimal.Decimal `json:"cumulative_quantity"`
+	PctOfContract      decimal.Decimal `json:"pct_of_contract"`
+	TolerancePct       decimal.Decimal `json:"tolerance_pct"`
+	VariationRef       string          `json:"variation_ref,omitempty"`
+	Blocked            bool            `json:"blocked"`
+}
+
+// checkOverruns compares each item's cumulative quantity with its contracted
+// quantity. It returns every overrun, and a 422 carrying them in Errors when
+// any line is blocked.
+func checkOverruns(tx *gorm.DB, ct *model.Contract, items []model.WorkDoneItem) ([]QuantityOverrun, error) {
+	ids := make([]uuid.UUID, 0, len(items))
+	for i := range items {
+		if items[i].LineItemID != nil {
+			ids = append(ids, *items[i].LineItemID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var lines []model.ContractLineItem
+	if err := tx.Where("id IN ?", ids).Find(&lines).Error; err != nil {
+		return nil, &ServiceError{Message: "Failed to load line items", Code: 500}
+	}
+	contracted := make(map[uuid.UUID]decimal.Decimal, len(lines))
+	for _, li := range lines {
+		contracted[li.ID] = li.Quantity
+	}
+
+	tolerance := ct.QuantityTolerancePctBps
+	if tolerance <= 0 {
+		tolerance = defaultQuantityTolerancePctBps
+	}
+	tolerancePct := decimal.NewFromInt(int64(tolerance)).Div(decimal.NewFromInt(100))
+	hundred := decimal.NewFromInt(100)
+
+	var overruns []QuantityOverrun
+	blocked := 0
+	for i := range items {
+		wd := &items[i]
+		if wd.LineItemID == nil {
+			continue
+		}
+		qty := contracted[*wd.LineItemID]
+		if !qty.IsPositive() || !wd.CumulativeQuantity.GreaterThan(qty) {
+			continue
+		}
+		pct := wd.CumulativeQuantity.Div(qty).Mul(hundred).Round(2)
+		o := QuantityOverrun{
+			LineItemID:         *wd.LineItemID,
+			Description:        wd.Description,
+			ContractQuantity:   qty,
+			CumulativeQuantity: wd.CumulativeQuantity,
+			PctOfContract:      pct,
+			TolerancePct:       tolerancePct,
+			VariationRef:       wd.VariationRef,
+		}
+		if pct.GreaterThan(tolerancePct) && !variationApproved(tx, ct.ID, wd.VariationRef) {
+			o.Blocked = true
+			blocked++
+		}
+		overruns = append(overruns, o)
+	}
+	if blocked > 0 {
+		return overruns, &ServiceError{
+			Message: fmt.Sprintf("%d line(s) exceed the %s%% quantity tolerance without an approved variation", blocked, tolerancePct.String()),
+			Code:    422,
+			Errors:  overruns,
+		}
+	}
+	return overruns, nil
+}
+
+// variationApproved reports whether ref names an approved variation order of
+// the contract. Extra work items do not count: their approved_by_client flag
+// is editable on any draft statement.
+func variationApproved(tx *gorm.DB, contractID uuid.UUID, ref string) bool {
+	if ref == "" {
+		return false
+	}
+	var n int64
+	tx.Model(&model.VariationOrder{}).
+		Where("contract_id = ? AND reference = ? AND status = ?", contractID, ref, model.VariationApproved).
+		Count(&n)
+	return n > 0
 }
 
 // --------------- Extra Works ---------------
@@ -330,6 +577,24 @@ func (s *StatementService) AddExtraWork(ctx context.Context, statementID string,
 			return &ServiceError{Message: "Only draft statements can be edited", Code: 422}
 		}
 
+		var vo *model.VariationOrder
+		if req.VariationOrderID != "" {
+			vid, err := uuid.Parse(req.VariationOrderID)
+			if err != nil {
+				return &ServiceError{Message: "Invalid variation_order_id", Code: 400}
+			}
+			vo = &model.VariationOrder{}
+			if err := tx.First(vo, "id = ? AND contract_id = ?", vid, stmt.ContractID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return &ServiceError{Message: "Variation order not found on this contract", Code: 404}
+				}
+				return &ServiceError{Message: "Database error", Code: 500}
+			}
+			if vo.Status != model.VariationApproved {
+				return &ServiceError{Message: "Variation order is not approved", Code: 422}
+			}
+		}
+
 		var maxLine int
 		tx.Model(&model.ExtraWorkItem{}).Where("statement_id = ?", sid).
 			Select("COALESCE(MAX(line_no), 0)").Scan(&maxLine)
@@ -347,6 +612,13 @@ func (s *StatementService) AddExtraWork(ctx context.Context, statementID string,
 			ApprovedByClient: req.ApprovedByClient,
 			ApprovalRef:      req.ApprovalRef,
 		}
+		if vo != nil {
+			ew.VariationOrderID = &vo.ID
+			ew.ApprovedByClient = true
+			if ew.VariationRef == "" {
+				ew.VariationRef = vo.Reference
+			}
+		}
 		if err := tx.Create(&ew).Error; err != nil {
 			return dbErr(err)
 		}
@@ -354,12 +626,9 @@ func (s *StatementService) AddExtraWork(ctx context.Context, statementID string,
 		stmt.ExtraWorkItems = append(stmt.ExtraWorkItems, ew)
 		var ct model.Contract
 		tx.First(&ct, "id = ?", stmt.ContractID)
-		grossBudget, _ := decimal.NewFromString(ct.GrossBudget.String())
-		stmt.Recompute(ct.RetentionPctBps, ct.AdvancePctBps, ct.VatPctBps, ct.SocialSecurityPctBps, ct.ManagementFeePctBps, decimal.Zero, grossBudget)
-		tx.Model(&stmt).Updates(map[string]any{
-			"extra_amount": stmt.ExtraAmount,
-			"net_amount":   stmt.NetAmount,
-		})
+		if err := recompute(tx, &stmt, &ct); err != nil {
+			return err
+		}
 		result = &ew
 		return nil
 	})
@@ -406,12 +675,9 @@ func (s *StatementService) DeleteExtraWork(ctx context.Context, statementID, ext
 			First(&full, "id = ?", sid)
 		var ct model.Contract
 		tx.First(&ct, "id = ?", full.ContractID)
-		grossBudget, _ := decimal.NewFromString(ct.GrossBudget.String())
-		full.Recompute(ct.RetentionPctBps, ct.AdvancePctBps, ct.VatPctBps, ct.SocialSecurityPctBps, ct.ManagementFeePctBps, decimal.Zero, grossBudget)
-		tx.Model(&full).Updates(map[string]any{
-			"extra_amount": full.ExtraAmount,
-			"net_amount":   full.NetAmount,
-		})
+		if err := recompute(tx, &full, &ct); err != nil {
+			return err
+		}
 		return nil
 	})
 }
@@ -466,12 +732,9 @@ func (s *StatementService) AddDeduction(ctx context.Context, statementID string,
 		stmt.DeductionItems = append(stmt.DeductionItems, d)
 		var ct model.Contract
 		tx.First(&ct, "id = ?", stmt.ContractID)
-		grossBudget, _ := decimal.NewFromString(ct.GrossBudget.String())
-		stmt.Recompute(ct.RetentionPctBps, ct.AdvancePctBps, ct.VatPctBps, ct.SocialSecurityPctBps, ct.ManagementFeePctBps, decimal.Zero, grossBudget)
-		tx.Model(&stmt).Updates(map[string]any{
-			"deduction_amount": stmt.DeductionAmount,
-			"net_amount":       stmt.NetAmount,
-		})
+		if err := recompute(tx, &stmt, &ct); err != nil {
+			return err
+		}
 		result = &d
 		return nil
 	})
@@ -540,12 +803,9 @@ func (s *StatementService) UpdateDeduction(ctx context.Context, statementID, ded
 			First(&full, "id = ?", sid)
 		var ct model.Contract
 		tx.First(&ct, "id = ?", full.ContractID)
-		grossBudget, _ := decimal.NewFromString(ct.GrossBudget.String())
-		full.Recompute(ct.RetentionPctBps, ct.AdvancePctBps, ct.VatPctBps, ct.SocialSecurityPctBps, ct.ManagementFeePctBps, decimal.Zero, grossBudget)
-		tx.Model(&full).Updates(map[string]any{
-			"deduction_amount": full.DeductionAmount,
-			"net_amount":       full.NetAmount,
-		})
+		if err := recompute(tx, &full, &ct); err != nil {
+			return err
+		}
 
 		result = &d
 		return nil
@@ -593,12 +853,9 @@ func (s *StatementService) DeleteDeduction(ctx context.Context, statementID, ded
 			First(&full, "id = ?", sid)
 		var ct model.Contract
 		tx.First(&ct, "id = ?", full.ContractID)
-		grossBudget, _ := decimal.NewFromString(ct.GrossBudget.String())
-		full.Recompute(ct.RetentionPctBps, ct.AdvancePctBps, ct.VatPctBps, ct.SocialSecurityPctBps, ct.ManagementFeePctBps, decimal.Zero, grossBudget)
-		tx.Model(&full).Updates(map[string]any{
-			"deduction_amount": full.DeductionAmount,
-			"net_amount":       full.NetAmount,
-		})
+		if err := recompute(tx, &full, &ct); err != nil {
+			return err
+		}
 		return nil
 	})
 }
@@ -617,14 +874,8 @@ func (s *StatementService) ListDeductions(ctx context.Context, statementID strin
 
 // --------------- Status Transitions ---------------
 
-var validTransitions = map[model.StatementStatus]map[model.StatementStatus][]string{
-	model.StatementDraft:          {model.StatementSubmitted: {"pm", "admin"}},
-	model.StatementSubmitted:      {model.StatementFinanceReview: {"finance", "admin"}, model.StatementRejected: {"finance", "admin"}},
-	model.StatementFinanceReview:  {model.StatementPMReview: {"pm", "admin"}, model.StatementRejected: {"pm", "admin"}},
-	model.StatementPMReview:       {model.StatementDirectorReview: {"director", "admin"}, model.StatementRejected: {"director", "admin"}},
-	model.StatementDirectorReview: {model.StatementApproved: {"director", "admin"}, model.StatementRejected: {"director", "admin"}},
-}
-
+// Transitions follow the company's workflow definition (see workflowFor);
+// the target status selects the step.
 func (s *StatementService) Transition(ctx context.Context, id string, req TransitionReq, callerID uuid.UUID, callerRoles []string) (*model.InterimStatement, error) {
 	uid, err := uuid.Parse(id)
 	if err != nil {
@@ -644,41 +895,92 @@ func (s *StatementService) Transition(ctx context.Context, id string, req Transi
 			return &ServiceError{Message: "Database error", Code: 500}
 		}
 
-		allowed, ok := validTransitions[stmt.Status]
-		if !ok {
+		def, err := workflowFor(tx, stmt.CompanyID, model.WorkflowInterimStatement, string(stmt.Status), stmt.WorkflowDefinitionID)
+		if err != nil {
+			return err
+		}
+		if !hasStepsFrom(def.Steps, string(stmt.Status)) {
 			return &ServiceError{Message: "No transitions available from " + string(stmt.Status), Code: 422}
 		}
-		requiredRoles, ok := allowed[newStatus]
-		if !ok {
+		step := findStep(def.Steps, string(stmt.Status), func(st *model.WorkflowStep) bool { return st.To == string(newStatus) })
+		if step == nil {
 			return &ServiceError{
 				Message: "Invalid status transition from " + string(stmt.Status) + " to " + string(newStatus),
 				Code:    422,
 			}
 		}
 
-		if !hasAnyRole(callerRoles, requiredRoles) {
-			return &ServiceError{Message: "Insufficient role for this transition", Code: 403}
+		var projectID uuid.UUID
+		if err := tx.Model(&model.Contract{}).Where("id = ?", stmt.ContractID).Select("project_id").Scan(&projectID).Error; err != nil {
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		scope := approvalScope{EntityType: model.WorkflowInterimStatement, EntityID: stmt.ID, CompanyID: stmt.CompanyID, ProjectID: &projectID}
+		actor, err := resolveApprover(tx, step, scope, callerID, callerRoles)
+		if err != nil {
+			return err
+		}
+		if actor == nil {
+			return &ServiceError{Message: "This transition requires one of: " + stepRequirement(step), Code: 403}
 		}
 
-		if newStatus == model.StatementRejected && req.Comment == "" {
-			return &ServiceError{Message: "Comment is required when rejecting", Code: 400}
+		if step.CommentRequired && req.Comment == "" {
+			return &ServiceError{Message: "Comment is required for this transition", Code: 400}
 		}
 
-		if err := tx.Model(&stmt).Updates(map[string]any{"status": newStatus}).Error; err != nil {
+		// Escalation is recomputed first so indices published since the last
+		// edit count; final approval does this in settleApproval.
+		if (step.Action == "submit" || step.Action == "approve") && newStatus != model.StatementApproved {
+			var ct model.Contract
+			if err := tx.First(&ct, "id = ?", stmt.ContractID).Error; err != nil {
+				return &ServiceError{Message: "Contract not found", Code: 500}
+			}
+			if err := reloadAndRecompute(tx, &stmt, &ct); err != nil {
+				return err
+			}
+			if err := requireEscalationIndices(tx, stmt.ID); err != nil {
+				return err
+			}
+		}
+
+		cols := map[string]any{"status": newStatus}
+		if stmt.Status == model.StatementDraft {
+			// Submission: snapshot this revision and pin the workflow.
+			if err := snapshotRevision(tx, &stmt, callerID); err != nil {
+				return err
+			}
+			stmt.WorkflowDefinitionID = workflowPin(def)
+			cols["workflow_definition_id"] = stmt.WorkflowDefinitionID
+		}
+		switch newStatus {
+		case model.StatementDraft:
+			if err := requireOpenContract(tx, stmt.ContractID); err != nil {
+				return err
+			}
+			cols["revision"] = stmt.Revision + 1
+		case model.StatementApproved:
+			if err := s.settleApproval(tx, &stmt); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&stmt).Updates(cols).Error; err != nil {
 			return &ServiceError{Message: "Transition failed", Code: 500}
 		}
 
 		evt := model.ApprovalEvent{
-			EntityType: "interim_statement",
-			EntityID:   stmt.ID,
-			ActorID:    callerID,
-			FromStatus: string(stmt.Status),
-			ToStatus:   string(newStatus),
-			Comment:    req.Comment,
-			CreatedAt:  time.Now(),
+			EntityType:    "interim_statement",
+			EntityID:      stmt.ID,
+			ActorID:       callerID,
+			FromStatus:    string(stmt.Status),
+			ToStatus:      string(newStatus),
+			Action:        step.Action,
+			Comment:       req.Comment,
+			CreatedAt:     time.Now(),
+			ActorPosition: actor.Position,
+			OnBehalfOfID:  actor.OnBehalfOf,
 		}
-		if err := tx.Create(&evt).Error; err != nil {
-			return &ServiceError{Message: "Failed to write approval event", Code: 500}
+		if err := recordApproval(tx, stmt.CompanyID, &evt); err != nil {
+			return err
 		}
 
 		stmt.Status = newStatus
@@ -690,15 +992,39 @@ func (s *StatementService) Transition(ctx context.Context, id string, req Transi
 	return &stmt, nil
 }
 
-func hasAnyRole(callerRoles, required []string) bool {
-	for _, cr := range callerRoles {
-		for _, r := range required {
-			if cr == r {
-				return true
-			}
-		}
+// settleApproval books the ledger side effects of approving stmt. The contract
+// row is locked so concurrent approvals see each other's recoveries, and the
+// aggregates are recomputed against the balance at approval time. The FX rate
+// in effect on IssuedOn is locked first; a missing rate or price index
+// blocks approval.
+func (s *StatementService) settleApproval(tx *gorm.DB, stmt *model.InterimStatement) error {
+	var ct model.Contract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ct, "id = ?", stmt.ContractID).Error; err != nil {
+		return &ServiceError{Message: "Contract not found", Code: 500}
+	}
+	if err := lockFxRate(tx, stmt); err != nil {
+		return err
+	}
+	if err := tx.Preload("WorkDoneItems").Preload("ExtraWorkItems").Preload("DeductionItems").
+		First(stmt, "id = ?", stmt.ID).Error; err != nil {
+		return &ServiceError{Message: "Database error", Code: 500}
+	}
+	if err := rebaseStatementWorkDone(tx, stmt, &ct); err != nil {
+		return err
+	}
+	if err := recompute(tx, stmt, &ct); err != nil {
+		return err
+	}
+	if err := requireEscalationIndices(tx, stmt.ID); err != nil {
+		return err
+	}
+	if err := recordAdvanceRecovery(tx, stmt, &ct); err != nil {
+		return err
+	}
+	if err := recordRetention(tx, stmt, &ct); err != nil {
+		return err
 	}
-	return false
+	return syncPaidAmount(tx, stmt)
 }
 
 // --------------- Update header ---------------
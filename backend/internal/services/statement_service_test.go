@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+)
+
+func TestRebaseWorkDone(t *testing.T) {
+	d := decimal.RequireFromString
+	cases := []struct {
+		name             string
+		cumulative       bool
+		qty, cum, prev   string
+		reason           string
+		ok               bool
+		wantQty, wantCum string
+		wantAmount       string
+	}{
+		{"period input", false, "30", "0", "100", "", true, "30", "130", "75"},
+		{"period input, first statement", false, "12.5", "0", "0", "", true, "12.5", "12.5", "31.25"},
+		{"cumulative input", true, "0", "130", "100", "", true, "30", "130", "75"},
+		{"cumulative unchanged", true, "0", "100", "100", "", true, "0", "100", "0"},
+		{"cumulative decrease without reason", true, "0", "90", "100", "", false, "-10", "90", "0"},
+		{"cumulative decrease with reason", true, "0", "90", "100", "remeasured", true, "-10", "90", "-25"},
+	}
+	for _, c := range cases {
+		wd := model.WorkDoneItem{
+			Quantity:           d(c.qty),
+			CumulativeQuantity: d(c.cum),
+			UnitPrice:          d("2.5"),
+			CorrectionReason:   c.reason,
+		}
+		ok := rebaseWorkDone(&wd, d(c.prev), c.cumulative)
+		if ok != c.ok {
+			t.Errorf("%s: ok = %v, want %v", c.name, ok, c.ok)
+			continue
+		}
+		if !wd.PrevCumulativeQuantity.Equal(d(c.prev)) {
+			t.Errorf("%s: prev = %s, want %s", c.name, wd.PrevCumulativeQuantity, c.prev)
+		}
+		if !wd.Quantity.Equal(d(c.wantQty)) || !wd.CumulativeQuantity.Equal(d(c.wantCum)) {
+			t.Errorf("%s: quantity/cumulative = %s/%s, want %s/%s", c.name, wd.Quantity, wd.CumulativeQuantity, c.wantQty, c.wantCum)
+		}
+		if ok && !wd.Amount.Equal(d(c.wantAmount)) {
+			t.Errorf("%s: amount = %s, want %s", c.name, wd.Amount, c.wantAmount)
+		}
+	}
+}
@@ -26,6 +26,9 @@ type ServiceError struct {
 	Message string
 	Code    int
 	Details string
+	// Errors is surfaced to the client as APIResponse.Errors (e.g. row-level
+	// validation results).
+	Errors any
 }
 
 func (e *ServiceError) Error() string { return e.Message }
@@ -0,0 +1,576 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VariationService manages variation orders: contract amendments that add or
+// revise line items and move the contract budget once approved.
+type VariationService struct{ db *gorm.DB }
+
+func NewVariationService(db *gorm.DB) *VariationService { return &VariationService{db: db} }
+
+// VariationItemReq revises the line item LineItemID, or adds a new line when
+// it is empty. Quantity and UnitRate are the revised values, not deltas.
+type VariationItemReq struct {
+	LineItemID  string `json:"line_item_id"`
+	SortOrder   int    `json:"sort_order"`
+	Chapter     int    `json:"chapter"`
+	Description string `json:"description"`
+	Unit        string `json:"unit"`
+	Quantity    string `json:"quantity"`
+	UnitRate    string `json:"unit_rate"`
+}
+
+type CreateVariationReq struct {
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Reason      string             `json:"reason"`
+	Items       []VariationItemReq `json:"items"`
+}
+
+type UpdateVariationReq struct {
+	Title       *string             `json:"title"`
+	Description *string             `json:"description"`
+	Reason      *string             `json:"reason"`
+	Items       *[]VariationItemReq `json:"items"` // replaces all items when set
+}
+
+// ContractSum is a contract's value before and after its variation orders.
+type ContractSum struct {
+	ContractID         uuid.UUID       `json:"contract_id"`
+	OriginalSum        decimal.Decimal `json:"original_sum"`
+	ApprovedVariations decimal.Decimal `json:"approved_variations"`
+	ApprovedCount      int64           `json:"approved_count"`
+	PendingVariations  decimal.Decimal `json:"pending_variations"`
+	PendingCount       int64           `json:"pending_count"`
+	RevisedSum         decimal.Decimal `json:"revised_sum"`
+	LineItemsTotal     decimal.Decimal `json:"line_items_total"`
+}
+
+var pendingVariationStatuses = []model.VariationStatus{
+	model.VariationPendingEngineering, model.VariationPendingFinance, model.VariationPendingCEO,
+}
+
+func (s *VariationService) List(ctx context.Context, contractID, status string) ([]model.VariationOrder, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	q := s.db.WithContext(ctx).Where("contract_id = ?", cid)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var out []model.VariationOrder
+	if err := q.Order("number ASC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+func (s *VariationService) Get(ctx context.Context, id string) (*model.VariationOrder, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid variation order ID", Code: 400}
+	}
+	var vo model.VariationOrder
+	if err := s.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC, created_at ASC") }).
+		First(&vo, "id = ?", uid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Variation order not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &vo, nil
+}
+
+// Create drafts the next variation order of a signed or active contract.
+func (s *VariationService) Create(ctx context.Context, contractID string, req CreateVariationReq, actorID uuid.UUID) (*model.VariationOrder, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		return nil, &ServiceError{Message: "title is required", Code: 400}
+	}
+
+	var vo model.VariationOrder
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ct model.Contract
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, company_id, project_id, status, type, contract_coefficient").First(&ct, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if ct.Status != model.ContractSigned && ct.Status != model.ContractActive {
+			return &ServiceError{Message: "Variation orders can only be raised on signed or active contracts", Code: 409}
+		}
+		items, err := variationItems(tx, cid, req.Items)
+		if err != nil {
+			return err
+		}
+
+		var maxNo int
+		if err := tx.Unscoped().Model(&model.VariationOrder{}).Where("contract_id = ?", cid).
+			Select("COALESCE(MAX(number), 0)").Scan(&maxNo).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+		vo = model.VariationOrder{
+			CompanyID:   ct.CompanyID,
+			ContractID:  cid,
+			ProjectID:   ct.ProjectID,
+			Number:      maxNo + 1,
+			Reference:   fmt.Sprintf("VO-%d", maxNo+1),
+			Title:       req.Title,
+			Description: req.Description,
+			Reason:      req.Reason,
+			Status:      model.VariationDraft,
+			BudgetDelta: budgetDelta(&ct, items),
+			CreatedByID: actorID,
+			Items:       items,
+		}
+		if err := tx.Create(&vo).Error; err != nil {
+			return dbErr(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &vo, nil
+}
+
+// Update edits a draft variation order.
+func (s *VariationService) Update(ctx context.Context, id string, req UpdateVariationReq) (*model.VariationOrder, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid variation order ID", Code: 400}
+	}
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		vo, err := lockDraftVariation(tx, uid)
+		if err != nil {
+			return err
+		}
+		updates := make(map[string]any)
+		if req.Title != nil {
+			title := strings.TrimSpace(*req.Title)
+			if title == "" {
+				return &ServiceError{Message: "title is required", Code: 400}
+			}
+			updates["title"] = title
+		}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if req.Reason != nil {
+			updates["reason"] = *req.Reason
+		}
+		if req.Items != nil {
+			items, err := variationItems(tx, vo.ContractID, *req.Items)
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("variation_order_id = ?", vo.ID).Delete(&model.VariationOrderItem{}).Error; err != nil {
+				return &ServiceError{Message: "Update failed", Code: 500}
+			}
+			for i := range items {
+				items[i].VariationOrderID = vo.ID
+			}
+			if len(items) > 0 {
+				if err := tx.Create(&items).Error; err != nil {
+					return dbErr(err)
+				}
+			}
+			var ct model.Contract
+			if err := tx.Select("id, type, contract_coefficient").First(&ct, "id = ?", vo.ContractID).Error; err != nil {
+				return &ServiceError{Message: "Contract not found", Code: 404}
+			}
+			updates["budget_delta"] = budgetDelta(&ct, items)
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(vo).Updates(updates).Error; err != nil {
+				return &ServiceError{Message: "Update failed", Code: 500}
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete removes a draft variation order. Its number is not reused.
+func (s *VariationService) Delete(ctx context.Context, id string) error {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return &ServiceError{Message: "Invalid variation order ID", Code: 400}
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		vo, err := lockDraftVariation(tx, uid)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("variation_order_id = ?", vo.ID).Delete(&model.VariationOrderItem{}).Error; err != nil {
+			return &ServiceError{Message: "Delete failed", Code: 500}
+		}
+		if err := tx.Delete(vo).Error; err != nil {
+			return &ServiceError{Message: "Delete failed", Code: 500}
+		}
+		return nil
+	})
+}
+
+func lockDraftVariation(tx *gorm.DB, id uuid.UUID) (*model.VariationOrder, error) {
+	var vo model.VariationOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&vo, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Variation order not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	if vo.Status != model.VariationDraft {
+		return nil, &ServiceError{Message: "Only draft variation orders can be edited", Code: 422}
+	}
+	return &vo, nil
+}
+
+// variationItems validates reqs against the contract's line items. Prev
+// values and AmountDelta are a preview until approval re-reads the lines.
+func variationItems(tx *gorm.DB, contractID uuid.UUID, reqs []VariationItemReq) ([]model.VariationOrderItem, error) {
+	if len(reqs) == 0 {
+		return nil, &ServiceError{Message: "At least one item is required", Code: 400}
+	}
+	var errs []string
+	seen := make(map[uuid.UUID]bool)
+	out := make([]model.VariationOrderItem, 0, len(reqs))
+	for i, r := range reqs {
+		item := model.VariationOrderItem{
+			SortOrder:   r.SortOrder,
+			Chapter:     r.Chapter,
+			Description: strings.TrimSpace(r.Description),
+			Unit:        strings.TrimSpace(r.Unit),
+		}
+		var err error
+		if item.Quantity, err = decimal.NewFromString(r.Quantity); err != nil || item.Quantity.IsNegative() {
+			errs = append(errs, fmt.Sprintf("item %d: invalid quantity", i+1))
+			continue
+		}
+		if item.UnitRate, err = decimal.NewFromString(r.UnitRate); err != nil || item.UnitRate.IsNegative() {
+			errs = append(errs, fmt.Sprintf("item %d: invalid unit_rate", i+1))
+			continue
+		}
+
+		if r.LineItemID != "" {
+			lid, err := uuid.Parse(r.LineItemID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("item %d: invalid line_item_id", i+1))
+				continue
+			}
+			if seen[lid] {
+				errs = append(errs, fmt.Sprintf("item %d: line item is revised twice", i+1))
+				continue
+			}
+			seen[lid] = true
+			var line model.ContractLineItem
+			if err := tx.First(&line, "id = ? AND contract_id = ?", lid, contractID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					errs = append(errs, fmt.Sprintf("item %d: line item not found on this contract", i+1))
+					continue
+				}
+				return nil, &ServiceError{Message: "Database error", Code: 500}
+			}
+			if line.IsGroup {
+				errs = append(errs, fmt.Sprintf("item %d: group lines have no quantity to revise", i+1))
+				continue
+			}
+			item.LineItemID = &lid
+			if item.Description == "" {
+				item.Description = line.Description
+			}
+			if item.Unit == "" {
+				item.Unit = line.Unit
+			}
+			if r.Chapter == 0 {
+				item.Chapter = line.Chapter
+			}
+			item.PrevQuantity, item.PrevUnitRate = line.Quantity, line.UnitRate
+		} else if item.Description == "" || item.Unit == "" {
+			errs = append(errs, fmt.Sprintf("item %d: description and unit are required for a new line", i+1))
+			continue
+		}
+		item.AmountDelta = item.Quantity.Mul(item.UnitRate).Sub(item.PrevQuantity.Mul(item.PrevUnitRate))
+		out = append(out, item)
+	}
+	if len(errs) > 0 {
+		return nil, &ServiceError{Message: "Invalid variation items", Code: 422, Errors: errs}
+	}
+	return out, nil
+}
+
+// budgetDelta is what items change the contract sum by: their AmountDelta
+// at the contract rate, the rule the line item budget guard uses.
+func budgetDelta(ct *model.Contract, items []model.VariationOrderItem) decimal.Decimal {
+	sum := decimal.Zero
+	for _, it := range items {
+		sum = sum.Add(it.AmountDelta)
+	}
+	return contractRate(ct, sum)
+}
+
+// Transition applies action following the company's variation order
+// workflow (see workflowFor). Reaching approved applies the order to the
+// contract in the same transaction.
+func (s *VariationService) Transition(ctx context.Context, id string, actorID uuid.UUID, actorRoles []string, action, comment string) (*model.VariationOrder, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid variation order ID", Code: 400}
+	}
+
+	var vo model.VariationOrder
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&vo, "id = ?", uid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Variation order not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+
+		def, err := workflowFor(tx, vo.CompanyID, model.WorkflowVariationOrder, string(vo.Status), vo.WorkflowDefinitionID)
+		if err != nil {
+			return err
+		}
+		if !hasStepsFrom(def.Steps, string(vo.Status)) {
+			return &ServiceError{Message: fmt.Sprintf("No transitions available from status %q", vo.Status), Code: 409}
+		}
+		step := findStep(def.Steps, string(vo.Status), func(st *model.WorkflowStep) bool { return st.Action == action })
+		if step == nil {
+			return &ServiceError{Message: fmt.Sprintf("Action %q is not valid for status %q", action, vo.Status), Code: 409}
+		}
+		scope := approvalScope{EntityType: model.WorkflowVariationOrder, EntityID: vo.ID, CompanyID: vo.CompanyID, ProjectID: &vo.ProjectID}
+		actor, err := resolveApprover(tx, step, scope, actorID, actorRoles)
+		if err != nil {
+			return err
+		}
+		if actor == nil {
+			return &ServiceError{Message: fmt.Sprintf("Action %q requires one of: %s", action, stepRequirement(step)), Code: 403}
+		}
+		if step.CommentRequired && comment == "" {
+			return &ServiceError{Message: "Comment is required for this action", Code: 400}
+		}
+
+		next := model.VariationStatus(step.To)
+		cols := map[string]any{"status": next}
+		if vo.Status == model.VariationDraft {
+			vo.WorkflowDefinitionID = workflowPin(def)
+			cols["workflow_definition_id"] = vo.WorkflowDefinitionID
+		}
+		if next == model.VariationApproved {
+			if err := applyVariation(tx, &vo); err != nil {
+				return err
+			}
+			cols["budget_delta"] = vo.BudgetDelta
+			cols["approved_at"] = vo.ApprovedAt
+		}
+		if err := tx.Model(&model.VariationOrder{}).Where("id = ?", vo.ID).Updates(cols).Error; err != nil {
+			return &ServiceError{Message: "Transition failed", Code: 500}
+		}
+
+		evt := model.ApprovalEvent{
+			EntityType:    model.WorkflowVariationOrder,
+			EntityID:      vo.ID,
+			ActorID:       actorID,
+			FromStatus:    string(vo.Status),
+			ToStatus:      string(next),
+			Action:        step.Action,
+			Comment:       comment,
+			CreatedAt:     time.Now(),
+			ActorPosition: actor.Position,
+			OnBehalfOfID:  actor.OnBehalfOf,
+		}
+		if err := recordApproval(tx, vo.CompanyID, &evt); err != nil {
+			return err
+		}
+		vo.Status = next
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &vo, nil
+}
+
+// applyVariation writes an approved order's items to the contract's line
+// items and adds the resulting delta, at the contract rate, to the contract
+// budget. The contract
+// row is locked so concurrent approvals add up.
+func applyVariation(tx *gorm.DB, vo *model.VariationOrder) error {
+	var ct model.Contract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id, contractor_id, project_id, status, currency, gross_budget, type, contract_coefficient").
+		First(&ct, "id = ?", vo.ContractID).Error; err != nil {
+		return &ServiceError{Message: "Contract not found", Code: 404}
+	}
+	if ct.Status != model.ContractSigned && ct.Status != model.ContractActive {
+		return &ServiceError{Message: "The contract is no longer signed or active", Code: 409}
+	}
+	var items []model.VariationOrderItem
+	if err := tx.Where("variation_order_id = ?", vo.ID).Order("sort_order ASC, created_at ASC").Find(&items).Error; err != nil {
+		return &ServiceError{Message: "Query failed", Code: 500}
+	}
+	if len(items) == 0 {
+		return &ServiceError{Message: "Variation order has no items", Code: 422}
+	}
+
+	for i := range items {
+		it := &items[i]
+		if it.LineItemID != nil {
+			var line model.ContractLineItem
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				First(&line, "id = ? AND contract_id = ?", *it.LineItemID, ct.ID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return &ServiceError{Message: fmt.Sprintf("Line item of %q no longer exists", it.Description), Code: 409}
+				}
+				return &ServiceError{Message: "Database error", Code: 500}
+			}
+			it.PrevQuantity, it.PrevUnitRate = line.Quantity, line.UnitRate
+			if err := tx.Model(&line).Updates(map[string]any{
+				"description": it.Description,
+				"unit":        it.Unit,
+				"chapter":     it.Chapter,
+				"quantity":    it.Quantity,
+				"unit_rate":   it.UnitRate,
+			}).Error; err != nil {
+				return &ServiceError{Message: "Failed to revise line item", Code: 500}
+			}
+			it.AppliedLineItemID = &line.ID
+		} else {
+			currency := ct.Currency
+			if len(currency) != 3 {
+				currency = "IRR"
+			}
+			line := model.ContractLineItem{
+				ContractID:       ct.ID,
+				ContractorID:     &ct.ContractorID,
+				ProjectID:        &ct.ProjectID,
+				SortOrder:        it.SortOrder,
+				Chapter:          it.Chapter,
+				Description:      it.Description,
+				Unit:             it.Unit,
+				Quantity:         it.Quantity,
+				UnitRate:         it.UnitRate,
+				CurrencyCode:     currency,
+				VariationOrderID: &vo.ID,
+			}
+			if err := tx.Create(&line).Error; err != nil {
+				return dbErr(err)
+			}
+			it.PrevQuantity, it.PrevUnitRate = decimal.Zero, decimal.Zero
+			it.AppliedLineItemID = &line.ID
+		}
+		it.AmountDelta = it.Quantity.Mul(it.UnitRate).Sub(it.PrevQuantity.Mul(it.PrevUnitRate))
+		if err := tx.Model(it).Updates(map[string]any{
+			"prev_quantity":        it.PrevQuantity,
+			"prev_unit_rate":       it.PrevUnitRate,
+			"amount_delta":         it.AmountDelta,
+			"applied_line_item_id": it.AppliedLineItemID,
+		}).Error; err != nil {
+			return &ServiceError{Message: "Failed to record variation item", Code: 500}
+		}
+	}
+
+	delta := budgetDelta(&ct, items)
+	revised := ct.GrossBudget.Add(delta)
+	if revised.IsNegative() {
+		return &ServiceError{Message: "The variation would make the contract sum negative", Code: 422}
+	}
+	if err := tx.Model(&ct).Update("gross_budget", revised).Error; err != nil {
+		return &ServiceError{Message: "Failed to update contract budget", Code: 500}
+	}
+	now := time.Now()
+	vo.BudgetDelta, vo.ApprovedAt, vo.Items = delta, &now, items
+	return nil
+}
+
+func (s *VariationService) ListApprovals(ctx context.Context, id string) ([]model.ApprovalEvent, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid variation order ID", Code: 400}
+	}
+	var events []model.ApprovalEvent
+	if err := s.db.WithContext(ctx).
+		Where("entity_type = ? AND entity_id = ?", model.WorkflowVariationOrder, uid).
+		Order("created_at ASC").
+		Find(&events).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return events, nil
+}
+
+// ContractSum reports the original contract sum, the approved and pending
+// variations and the revised sum. GrossBudget already includes approved
+// variations, so the original sum is derived from it. The line items are
+// totalled at the contract rate so they compare with the budget.
+func (s *VariationService) ContractSum(ctx context.Context, contractID string) (*ContractSum, error) {
+	cid, err := uuid.Parse(contractID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid contract ID", Code: 400}
+	}
+	db := s.db.WithContext(ctx)
+	var ct model.Contract
+	if err := db.Select("id, gross_budget, type, contract_coefficient").First(&ct, "id = ?", cid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Contract not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+
+	var approved, pending struct {
+		Total decimal.Decimal
+		Count int64
+	}
+	if err := db.Model(&model.VariationOrder{}).
+		Select("COALESCE(SUM(budget_delta), 0) AS total, COUNT(*) AS count").
+		Where("contract_id = ? AND status = ?", cid, model.VariationApproved).
+		Scan(&approved).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	if err := db.Model(&model.VariationOrder{}).
+		Select("COALESCE(SUM(budget_delta), 0) AS total, COUNT(*) AS count").
+		Where("contract_id = ? AND status IN ?", cid, pendingVariationStatuses).
+		Scan(&pending).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	var lines decimal.Decimal
+	if err := db.Model(&model.ContractLineItem{}).
+		Select("COALESCE(SUM(quantity * unit_rate), 0)").
+		Where("contract_id = ?", cid).
+		Scan(&lines).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+
+	return &ContractSum{
+		ContractID:         cid,
+		OriginalSum:        ct.GrossBudget.Sub(approved.Total),
+		ApprovedVariations: approved.Total,
+		ApprovedCount:      approved.Count,
+		PendingVariations:  pending.Total,
+		PendingCount:       pending.Count,
+		RevisedSum:         ct.GrossBudget,
+		LineItemsTotal:     contractRate(&ct, lines),
+	}, nil
+}
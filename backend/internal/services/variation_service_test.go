@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+)
+
+// The budget moves by what the line item guard counts: contractRate of the
+// raw line totals.
+func TestBudgetDelta(t *testing.T) {
+	d := decimal.RequireFromString
+	items := []model.VariationOrderItem{
+		{AmountDelta: d("1000")},
+		{AmountDelta: d("-250")},
+	}
+	cases := []struct {
+		name string
+		ct   model.Contract
+		want string
+	}{
+		{"unit rate above 1", model.Contract{Type: model.ContractUnitRate, ContractCoefficient: d("1.15")}, "862.5"},
+		{"unit rate below 1", model.Contract{Type: model.ContractUnitRate, ContractCoefficient: d("0.9")}, "675"},
+		{"lump sum ignores the coefficient", model.Contract{Type: model.ContractLumpSum, ContractCoefficient: d("1.15")}, "750"},
+	}
+	for _, c := range cases {
+		got := budgetDelta(&c.ct, items)
+		if !got.Equal(d(c.want)) {
+			t.Errorf("%s: budgetDelta = %s, want %s", c.name, got, c.want)
+		}
+		if guard := contractRate(&c.ct, d("750")); !got.Equal(guard) {
+			t.Errorf("%s: budgetDelta = %s, guard counts %s", c.name, got, guard)
+		}
+	}
+	if got := budgetDelta(&model.Contract{Type: model.ContractUnitRate, ContractCoefficient: d("1.15")}, nil); !got.IsZero() {
+		t.Errorf("no items: budgetDelta = %s, want 0", got)
+	}
+}
@@ -0,0 +1,576 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	webhookBatchSize    = 20
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookTimeout      = 10 * time.Second
+	webhookLease        = 2 * time.Minute // a claimed delivery is retried after this if the worker dies
+	webhookResponseKeep = 2048
+	webhookMinSecretLen = 16
+)
+
+// Webhook request headers. The signature is hex HMAC-SHA256 over
+// "<timestamp>.<body>" keyed with the subscription secret.
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// WebhookService manages webhook subscriptions and posts the outbox to them.
+type WebhookService struct {
+	db     *gorm.DB
+	client *http.Client
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{db: db, client: newWebhookClient()}
+}
+
+var errWebhookAddress = errors.New("webhook address is not a public host")
+
+// publicIP reports whether ip may receive webhooks. Loopback, private,
+// link-local, unspecified and multicast addresses are internal to the
+// deployment and would let a subscription probe it.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// newWebhookClient returns a client that connects to public addresses only,
+// checked on the resolved IP at dial time so DNS cannot point a registered
+// host inward later, and that does not follow redirects.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errWebhookAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type CreateWebhookReq struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"` // generated when empty
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+}
+
+type UpdateWebhookReq struct {
+	URL         *string  `json:"url"`
+	EventTypes  []string `json:"event_types"`
+	Description *string  `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// validWebhookURL checks that raw is an absolute http(s) URL whose host
+// resolves to public addresses only.
+func validWebhookURL(ctx context.Context, raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", &ServiceError{Message: "url must be an absolute http(s) URL", Code: 400}
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return "", &ServiceError{Message: "url host cannot be resolved", Code: 400}
+	}
+	for _, a := range addrs {
+		if !publicIP(a.IP) {
+			return "", &ServiceError{Message: "url must point to a public host, not a loopback, private or link-local address", Code: 400}
+		}
+	}
+	return raw, nil
+}
+
+func validEventTypes(types []string) (pq.StringArray, error) {
+	if len(types) == 0 {
+		return nil, &ServiceError{Message: "event_types is required", Code: 400}
+	}
+	out := pq.StringArray{}
+	for _, t := range types {
+		if !slices.Contains(EventTypes, t) {
+			return nil, &ServiceError{Message: "Unknown event type: " + t, Code: 400}
+		}
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *WebhookService) List(ctx context.Context, companyID string) ([]model.WebhookSubscription, error) {
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	var out []model.WebhookSubscription
+	if err := s.db.WithContext(ctx).Where("company_id = ?", cid).Order("created_at ASC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	for i := range out {
+		out[i].Secret = ""
+	}
+	return out, nil
+}
+
+// Create adds a subscription. The secret is returned only here.
+func (s *WebhookService) Create(ctx context.Context, req CreateWebhookReq, companyID string, actorID uuid.UUID) (*model.WebhookSubscription, error) {
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	u, err := validWebhookURL(ctx, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	types, err := validEventTypes(req.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret := req.Secret
+	if secret == "" {
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, &ServiceError{Message: "Failed to generate secret", Code: 500}
+		}
+	} else if len(secret) < webhookMinSecretLen {
+		return nil, &ServiceError{Message: fmt.Sprintf("secret must be at least %d characters", webhookMinSecretLen), Code: 400}
+	}
+
+	sub := model.WebhookSubscription{
+		CompanyID:   cid,
+		URL:         u,
+		Secret:      secret,
+		EventTypes:  types,
+		Description: req.Description,
+		Active:      true,
+		CreatedByID: actorID,
+	}
+	if err := s.db.WithContext(ctx).Create(&sub).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	return &sub, nil
+}
+
+func (s *WebhookService) findSubscription(db *gorm.DB, companyID, id string) (*model.WebhookSubscription, error) {
+	sid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid webhook ID", Code: 400}
+	}
+	var sub model.WebhookSubscription
+	if err := db.First(&sub, "id = ? AND company_id = ?", sid, companyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Webhook not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &sub, nil
+}
+
+func (s *WebhookService) Update(ctx context.Context, companyID, id string, req UpdateWebhookReq) (*model.WebhookSubscription, error) {
+	db := s.db.WithContext(ctx)
+	sub, err := s.findSubscription(db, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL != nil {
+		if sub.URL, err = validWebhookURL(ctx, *req.URL); err != nil {
+			return nil, err
+		}
+	}
+	if req.EventTypes != nil {
+		if sub.EventTypes, err = validEventTypes(req.EventTypes); err != nil {
+			return nil, err
+		}
+	}
+	if req.Description != nil {
+		sub.Description = *req.Description
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+	if err := db.Save(sub).Error; err != nil {
+		return nil, dbErr(err)
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+// RotateSecret replaces the signing secret and returns the subscription with
+// the new secret.
+func (s *WebhookService) RotateSecret(ctx context.Context, companyID, id string) (*model.WebhookSubscription, error) {
+	db := s.db.WithContext(ctx)
+	sub, err := s.findSubscription(db, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, &ServiceError{Message: "Failed to generate secret", Code: 500}
+	}
+	if err := db.Model(sub).Update("secret", secret).Error; err != nil {
+		return nil, &ServiceError{Message: "Update failed", Code: 500}
+	}
+	sub.Secret = secret
+	return sub, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, companyID, id string) error {
+	db := s.db.WithContext(ctx)
+	sub, err := s.findSubscription(db, companyID, id)
+	if err != nil {
+		return err
+	}
+	if err := db.Delete(sub).Error; err != nil {
+		return &ServiceError{Message: "Delete failed", Code: 500}
+	}
+	return nil
+}
+
+// ListDeliveries lists the deliveries of one subscription, newest first.
+func (s *WebhookService) ListDeliveries(ctx context.Context, companyID, id, status string, page, limit int) ([]model.WebhookDelivery, int64, error) {
+	db := s.db.WithContext(ctx)
+	sub, err := s.findSubscription(db, companyID, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	q := db.Model(&model.WebhookDelivery{}).Where("subscription_id = ?", sub.ID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Count failed", Code: 500}
+	}
+	var out []model.WebhookDelivery
+	if err := q.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&out).Error; err != nil {
+		return nil, 0, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, total, nil
+}
+
+func (s *WebhookService) findDelivery(db *gorm.DB, companyID, deliveryID string) (*model.WebhookDelivery, error) {
+	did, err := uuid.Parse(deliveryID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid delivery ID", Code: 400}
+	}
+	var d model.WebhookDelivery
+	if err := db.Joins("JOIN webhook_subscriptions ws ON ws.id = webhook_deliveries.subscription_id").
+		Where("webhook_deliveries.id = ? AND ws.company_id = ? AND ws.deleted_at IS NULL", did, companyID).
+		Take(&d).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Delivery not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &d, nil
+}
+
+// ListAttempts returns every POST made for one delivery.
+func (s *WebhookService) ListAttempts(ctx context.Context, companyID, deliveryID string) ([]model.WebhookDeliveryAttempt, error) {
+	db := s.db.WithContext(ctx)
+	d, err := s.findDelivery(db, companyID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.WebhookDeliveryAttempt
+	if err := db.Where("delivery_id = ?", d.ID).Order("attempt ASC").Find(&out).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return out, nil
+}
+
+// Redeliver queues a delivery again for immediate sending with a fresh set
+// of attempts, whatever its status. The payload is unchanged.
+func (s *WebhookService) Redeliver(ctx context.Context, companyID, deliveryID string) (*model.WebhookDelivery, error) {
+	db := s.db.WithContext(ctx)
+	d, err := s.findDelivery(db, companyID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := db.Model(d).Updates(map[string]any{
+		"status":          model.WebhookPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+	}).Error; err != nil {
+		return nil, &ServiceError{Message: "Update failed", Code: 500}
+	}
+	d.Status, d.Attempts, d.NextAttemptAt = model.WebhookPending, 0, now
+	return d, nil
+}
+
+// Run delivers due webhooks every interval until ctx is cancelled.
+func (s *WebhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := s.DeliverDue(ctx)
+			if err != nil {
+				log.Printf("[webhook] delivery failed: %v", err)
+			} else if n > 0 {
+				log.Printf("[webhook] processed %d delivery(ies)", n)
+			}
+			if err != nil || n < webhookBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dueDelivery is a claimed delivery with what is needed to post it.
+type dueDelivery struct {
+	model.WebhookDelivery
+	URL     string
+	Secret  string
+	Payload string
+}
+
+// DeliverDue posts one batch of due deliveries of active subscriptions and
+// returns how many it tried. Deliveries are claimed with SKIP LOCKED and a lease on NextAttemptAt, so
+// the HTTP calls run outside the transaction and several API instances can
+// run the worker. A failed attempt is retried after 30s, 1m, 2m, ... until
+// webhookMaxAttempts, then the delivery is marked failed.
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	db := s.db.WithContext(ctx)
+	var batch []dueDelivery
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		if err := tx.Model(&model.WebhookDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.WebhookPending, time.Now()).
+			Where("subscription_id IN (SELECT id FROM webhook_subscriptions WHERE active AND deleted_at IS NULL)").
+			Order("next_attempt_at ASC").Limit(webhookBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(webhookLease)).Error; err != nil {
+			return err
+		}
+		return tx.Table("webhook_deliveries d").
+			Select("d.*, ws.url, ws.secret, we.payload").
+			Joins("JOIN webhook_subscriptions ws ON ws.id = d.subscription_id").
+			Joins("JOIN webhook_events we ON we.id = d.event_id").
+			Where("d.id IN ?", ids).
+			Scan(&batch).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i := range batch {
+		if err := s.deliver(ctx, db, &batch[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) deliver(ctx context.Context, db *gorm.DB, d *dueDelivery) error {
+	body := []byte(d.Payload)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	attempt := model.WebhookDeliveryAttempt{DeliveryID: d.ID, Attempt: d.Attempts + 1}
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "ContractLedger-Webhook/1")
+		req.Header.Set(WebhookEventHeader, d.EventType)
+		req.Header.Set(WebhookDeliveryHeader, d.ID.String())
+		req.Header.Set(WebhookTimestampHeader, ts)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhook(d.Secret, ts, body))
+		var resp *http.Response
+		if resp, err = s.client.Do(req); err == nil {
+			snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseKeep))
+			resp.Body.Close()
+			attempt.StatusCode = resp.StatusCode
+			attempt.ResponseBody = strings.ToValidUTF8(string(snippet), "")
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+		}
+	}
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	attempt.Success = err == nil
+
+	updates := map[string]any{"attempts": attempt.Attempt, "last_status_code": attempt.StatusCode}
+	if err == nil {
+		updates["status"] = model.WebhookDelivered
+		updates["delivered_at"] = time.Now()
+		updates["last_error"] = ""
+	} else {
+		attempt.Error = err.Error()
+		updates["last_error"] = attempt.Error
+		if attempt.Attempt >= webhookMaxAttempts {
+			updates["status"] = model.WebhookFailed
+		} else {
+			updates["next_attempt_at"] = time.Now().Add(webhookBaseBackoff << (attempt.Attempt - 1))
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("id = ?", d.ID).Updates(updates).Error
+	})
+}
+
+// ------------------------------------------------------------
+// Domain-event hook (transactional outbox)
+// ------------------------------------------------------------
+
+// webhookPayload is the JSON body posted to subscribers. Data is the
+// ApprovalEvent, Attachment, ApprovalEscalation or Payment behind the event.
+type webhookPayload struct {
+	ID         uuid.UUID  `json:"id"`
+	Type       string     `json:"type"`
+	OccurredAt time.Time  `json:"occurred_at"`
+	CompanyID  uuid.UUID  `json:"company_id"`
+	EntityType string     `json:"entity_type"`
+	EntityID   uuid.UUID  `json:"entity_id"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty"`
+	Data       any        `json:"data,omitempty"`
+}
+
+// queueWebhooks writes e to the outbox with a pending delivery per active
+// subscription of the company that listens to e.Type. It runs inside the
+// transaction that caused e, so nothing is posted for rolled-back changes.
+func queueWebhooks(tx *gorm.DB, e *DomainEvent) error {
+	var subs []uuid.UUID
+	if err := tx.Model(&model.WebhookSubscription{}).
+		Where("company_id = ? AND active AND ? = ANY(event_types)", e.CompanyID, e.Type).
+		Pluck("id", &subs).Error; err != nil {
+		return &ServiceError{Message: "Database error", Code: 500}
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	p := webhookPayload{
+		ID:         uuid.New(),
+		Type:       e.Type,
+		OccurredAt: e.OccurredAt,
+		CompanyID:  e.CompanyID,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+	}
+	if e.ActorID != uuid.Nil {
+		id := e.ActorID
+		p.ActorID = &id
+	}
+	switch {
+	case e.Approval != nil:
+		p.Data = e.Approval
+	case e.Attachment != nil:
+		p.Data = e.Attachment
+	case e.Escalation != nil:
+		p.Data = e.Escalation
+	case e.Payment != nil:
+		p.Data = e.Payment
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return &ServiceError{Message: "Failed to encode webhook payload", Code: 500}
+	}
+
+	evt := model.WebhookEvent{
+		CompanyID:  e.CompanyID,
+		Type:       e.Type,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Payload:    string(body),
+		OccurredAt: e.OccurredAt,
+	}
+	evt.ID = p.ID
+	if err := tx.Create(&evt).Error; err != nil {
+		return &ServiceError{Message: "Failed to write webhook outbox", Code: 500}
+	}
+	deliveries := make([]model.WebhookDelivery, 0, len(subs))
+	for _, sid := range subs {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			SubscriptionID: sid,
+			EventID:        evt.ID,
+			EventType:      e.Type,
+			Status:         model.WebhookPending,
+			NextAttemptAt:  time.Now(),
+		})
+	}
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return &ServiceError{Message: "Failed to write webhook outbox", Code: 500}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignWebhook(t *testing.T) {
+	got := signWebhook("topsecret", "1700000000", []byte(`{"event":"payment.recorded"}`))
+	want := "728159780948848933cd462b55fcf09060f9167bf3c74a7f937885c59c3970ae"
+	if got != want {
+		t.Fatalf("signWebhook = %s, want %s", got, want)
+	}
+	if signWebhook("othersecret", "1700000000", []byte(`{"event":"payment.recorded"}`)) == want {
+		t.Fatal("signature does not depend on the secret")
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.10":    false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+		"::ffff:10.0.0.1": false,
+		"224.0.0.1":       false,
+	}
+	for addr, want := range cases {
+		if got := publicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("publicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestValidWebhookURLRejectsInternalHosts(t *testing.T) {
+	for _, raw := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"https://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"ftp://example.com/hook",
+		"/relative",
+	} {
+		if _, err := validWebhookURL(context.Background(), raw); err == nil {
+			t.Errorf("validWebhookURL(%q) accepted", raw)
+		}
+	}
+	if _, err := validWebhookURL(context.Background(), "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("public address rejected: %v", err)
+	}
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	resp, err := newWebhookClient().Post(srv.URL, "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("client connected to a loopback address")
+	}
+}
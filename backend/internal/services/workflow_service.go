@@ -0,0 +1,533 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WorkflowService manages the per-company approval workflow definitions that
+// drive StatementService.Transition and ContractSvc.Transition.
+type WorkflowService struct{ db *gorm.DB }
+
+func NewWorkflowService(db *gorm.DB) *WorkflowService { return &WorkflowService{db: db} }
+
+type CreateWorkflowReq struct {
+	CompanyID  string               `json:"company_id"` // defaults to the caller's company
+	EntityType string               `json:"entity_type"`
+	Steps      []model.WorkflowStep `json:"steps"`
+	Notes      string               `json:"notes"`
+	Activate   *bool                `json:"activate"` // default true
+}
+
+var headRoleNames = []string{
+	string(model.RoleManager), string(model.RoleFinanceHead), string(model.RoleJuridicalHead),
+	string(model.RoleEngineeringHead), string(model.RoleSecurityHead),
+}
+
+// Approver seats of the built-in statement chain. The project manager stands
+// in for "pm" (the engineering head covers projects without one), the
+// financial head for "finance" and the company manager for "director".
+var (
+	projectLeads    = []model.ApproverPosition{model.PositionProjectManager, model.PositionEngineeringHead}
+	engineeringHead = []model.ApproverPosition{model.PositionEngineeringHead}
+	financeHead     = []model.ApproverPosition{model.PositionFinancialHead}
+	companyManager  = []model.ApproverPosition{model.PositionManager}
+	adminOnly       = []string{string(model.RoleAdmin)}
+)
+
+// defaultWorkflows are the built-in chains for companies without a definition
+// of their own, and for documents submitted before definitions existed.
+var defaultWorkflows = map[string]model.WorkflowSteps{
+	model.WorkflowInterimStatement: {
+		{From: "draft", Action: "submit", To: "submitted", Positions: projectLeads, Roles: adminOnly},
+		{From: "submitted", Action: "approve", To: "finance_review", Positions: financeHead, Roles: adminOnly},
+		{From: "submitted", Action: "reject", To: "rejected", Positions: financeHead, Roles: adminOnly, CommentRequired: true},
+		{From: "finance_review", Action: "approve", To: "pm_review", Positions: projectLeads, Roles: adminOnly},
+		{From: "finance_review", Action: "reject", To: "rejected", Positions: projectLeads, Roles: adminOnly, CommentRequired: true},
+		{From: "pm_review", Action: "approve", To: "director_review", Positions: companyManager, Roles: adminOnly},
+		{From: "pm_review", Action: "reject", To: "rejected", Positions: companyManager, Roles: adminOnly, CommentRequired: true},
+		{From: "director_review", Action: "approve", To: "approved", Positions: companyManager, Roles: adminOnly},
+		{From: "director_review", Action: "reject", To: "rejected", Positions: companyManager, Roles: adminOnly, CommentRequired: true},
+		// Reopen for revision: back to draft under the next revision number.
+		{From: "rejected", Action: "reopen", To: "draft", Positions: projectLeads, Roles: adminOnly},
+	},
+	model.WorkflowContract: {
+		{From: "draft", Action: "submit", To: "pending_engineering", Roles: headRoleNames},
+		{From: "pending_engineering", Action: "approve", To: "pending_finance", Roles: []string{"engineering_head"}},
+		{From: "pending_engineering", Action: "reject", To: "draft", Roles: []string{"engineering_head"}},
+		{From: "pending_finance", Action: "approve", To: "pending_legal", Roles: []string{"finance_head"}},
+		{From: "pending_finance", Action: "reject", To: "draft", Roles: []string{"finance_head"}},
+		{From: "pending_legal", Action: "approve", To: "pending_ceo", Roles: []string{"juridical_head"}},
+		{From: "pending_legal", Action: "reject", To: "draft", Roles: []string{"juridical_head"}},
+		{From: "pending_ceo", Action: "approve", To: "ready_to_print", Roles: []string{"manager"}},
+		{From: "pending_ceo", Action: "reject", To: "draft", Roles: []string{"manager"}},
+		{From: "ready_to_print", Action: "sign", To: "signed", Roles: []string{"manager"}},
+		{From: "signed", Action: "activate", To: "active", Roles: []string{"manager"}},
+	},
+	model.WorkflowVariationOrder: {
+		{From: "draft", Action: "submit", To: "pending_engineering", Positions: projectLeads, Roles: adminOnly},
+		{From: "pending_engineering", Action: "approve", To: "pending_finance", Positions: engineeringHead, Roles: adminOnly},
+		{From: "pending_engineering", Action: "reject", To: "rejected", Positions: engineeringHead, Roles: adminOnly, CommentRequired: true},
+		{From: "pending_finance", Action: "approve", To: "pending_ceo", Positions: financeHead, Roles: adminOnly},
+		{From: "pending_finance", Action: "reject", To: "rejected", Positions: financeHead, Roles: adminOnly, CommentRequired: true},
+		{From: "pending_ceo", Action: "approve", To: "approved", Positions: companyManager, Roles: adminOnly},
+		{From: "pending_ceo", Action: "reject", To: "rejected", Positions: companyManager, Roles: adminOnly, CommentRequired: true},
+		{From: "rejected", Action: "reopen", To: "draft", Positions: projectLeads, Roles: adminOnly},
+	},
+	model.WorkflowExtensionOfTime: {
+		{From: "draft", Action: "submit", To: "pending_engineering", Positions: projectLeads, Roles: adminOnly},
+		{From: "pending_engineering", Action: "approve", To: "pending_ceo", Positions: engineeringHead, Roles: adminOnly},
+		{From: "pending_engineering", Action: "reject", To: "rejected", Positions: engineeringHead, Roles: adminOnly, CommentRequired: true},
+		{From: "pending_ceo", Action: "approve", To: "approved", Positions: companyManager, Roles: adminOnly},
+		{From: "pending_ceo", Action: "reject", To: "rejected", Positions: companyManager, Roles: adminOnly, CommentRequired: true},
+		{From: "rejected", Action: "reopen", To: "draft", Positions: projectLeads, Roles: adminOnly},
+	},
+}
+
+const errWorkflowEntityType = "entity_type must be interim_statement, contract, variation_order or extension_of_time"
+
+// workflowGoal is the status every workflow of an entity type must reach from draft.
+var workflowGoal = map[string]string{
+	model.WorkflowInterimStatement: string(model.StatementApproved),
+	model.WorkflowContract:         string(model.ContractActive),
+	model.WorkflowVariationOrder:   string(model.VariationApproved),
+	model.WorkflowExtensionOfTime:  string(model.ExtensionApproved),
+}
+
+func (s *WorkflowService) List(ctx context.Context, companyID, entityType string) ([]model.WorkflowDefinition, error) {
+	q := s.db.WithContext(ctx).Model(&model.WorkflowDefinition{})
+	if companyID != "" {
+		cid, err := uuid.Parse(companyID)
+		if err != nil {
+			return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+		}
+		q = q.Where("company_id = ?", cid)
+	}
+	if entityType != "" {
+		q = q.Where("entity_type = ?", entityType)
+	}
+	var defs []model.WorkflowDefinition
+	if err := q.Order("company_id ASC, entity_type ASC, version DESC").Find(&defs).Error; err != nil {
+		return nil, &ServiceError{Message: "Query failed", Code: 500}
+	}
+	return defs, nil
+}
+
+func (s *WorkflowService) Get(ctx context.Context, id string) (*model.WorkflowDefinition, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid workflow ID", Code: 400}
+	}
+	var def model.WorkflowDefinition
+	if err := s.db.WithContext(ctx).First(&def, "id = ?", uid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Workflow not found", Code: 404}
+		}
+		return nil, &ServiceError{Message: "Database error", Code: 500}
+	}
+	return &def, nil
+}
+
+// Builtin returns the built-in workflow of an entity type.
+func (s *WorkflowService) Builtin(entityType string) (model.WorkflowSteps, error) {
+	steps, ok := defaultWorkflows[entityType]
+	if !ok {
+		return nil, &ServiceError{Message: errWorkflowEntityType, Code: 400}
+	}
+	return steps, nil
+}
+
+// Create stores the next version of a company's workflow for an entity type
+// and, unless activate is false, makes it the active one.
+func (s *WorkflowService) Create(ctx context.Context, req CreateWorkflowReq, callerCompanyID string, actorID uuid.UUID) (*model.WorkflowDefinition, error) {
+	companyID := req.CompanyID
+	if companyID == "" {
+		companyID = callerCompanyID
+	}
+	cid, err := uuid.Parse(companyID)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid company ID", Code: 400}
+	}
+	steps, err := normalizeWorkflow(req.EntityType, req.Steps)
+	if err != nil {
+		return nil, &ServiceError{Message: err.Error(), Code: 422}
+	}
+	activate := req.Activate == nil || *req.Activate
+
+	var def model.WorkflowDefinition
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var company model.Company
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&company, "id = ?", cid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Company not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		var maxVersion int
+		if err := tx.Model(&model.WorkflowDefinition{}).
+			Where("company_id = ? AND entity_type = ?", cid, req.EntityType).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&maxVersion).Error; err != nil {
+			return &ServiceError{Message: "Query failed", Code: 500}
+		}
+		if activate {
+			if err := deactivateWorkflows(tx, cid, req.EntityType); err != nil {
+				return err
+			}
+		}
+		def = model.WorkflowDefinition{
+			CompanyID:   cid,
+			EntityType:  req.EntityType,
+			Version:     maxVersion + 1,
+			Active:      activate,
+			Steps:       steps,
+			Notes:       req.Notes,
+			CreatedByID: actorID,
+		}
+		if err := tx.Create(&def).Error; err != nil {
+			return dbErr(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &def, nil
+}
+
+// SetActive activates a version (deactivating the others of its company and
+// entity type) or deactivates it, which falls back to the built-in workflow.
+// Documents already submitted keep their pinned version either way.
+func (s *WorkflowService) SetActive(ctx context.Context, id string, active bool) (*model.WorkflowDefinition, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, &ServiceError{Message: "Invalid workflow ID", Code: 400}
+	}
+	var def model.WorkflowDefinition
+	txErr := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&def, "id = ?", uid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{Message: "Workflow not found", Code: 404}
+			}
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		var company model.Company
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&company, "id = ?", def.CompanyID).Error; err != nil {
+			return &ServiceError{Message: "Database error", Code: 500}
+		}
+		if active {
+			if err := deactivateWorkflows(tx, def.CompanyID, def.EntityType); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&def).Update("active", active).Error; err != nil {
+			return &ServiceError{Message: "Update failed", Code: 500}
+		}
+		def.Active = active
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &def, nil
+}
+
+func deactivateWorkflows(tx *gorm.DB, companyID uuid.UUID, entityType string) error {
+	if err := tx.Model(&model.WorkflowDefinition{}).
+		Where("company_id = ? AND entity_type = ? AND active", companyID, entityType).
+		Update("active", false).Error; err != nil {
+		return &ServiceError{Message: "Update failed", Code: 500}
+	}
+	return nil
+}
+
+// normalizeWorkflow validates steps for entityType and fills defaults: a
+// statement step without an action is named after its target status.
+func normalizeWorkflow(entityType string, steps []model.WorkflowStep) (model.WorkflowSteps, error) {
+	goal, ok := workflowGoal[entityType]
+	if !ok {
+		return nil, errors.New(errWorkflowEntityType)
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("steps must not be empty")
+	}
+	validStatus := func(st string) bool {
+		switch entityType {
+		case model.WorkflowContract:
+			// cancel and closeout have their own rules.
+			s := model.ContractStatus(st)
+			return s.Valid() && s != model.ContractCancelled && s != model.ContractClosed
+		case model.WorkflowVariationOrder:
+			return model.VariationStatus(st).Valid()
+		case model.WorkflowExtensionOfTime:
+			return model.ExtensionStatus(st).Valid()
+		}
+		return model.StatementStatus(st).Valid()
+	}
+
+	out := make(model.WorkflowSteps, 0, len(steps))
+	byAction := make(map[string]bool)
+	byTarget := make(map[string]bool)
+	for i, st := range steps {
+		st.From, st.To, st.Action = strings.TrimSpace(st.From), strings.TrimSpace(st.To), strings.TrimSpace(st.Action)
+		if !validStatus(st.From) || !validStatus(st.To) || st.From == st.To {
+			return nil, fmt.Errorf("step %d: invalid from/to status", i+1)
+		}
+		// Leaving the goal would let a document be approved, and book its
+		// side effects, a second time.
+		if st.From == goal {
+			return nil, fmt.Errorf("step %d: %s is final and cannot be left", i+1, goal)
+		}
+		if st.Action == "" {
+			if entityType != model.WorkflowInterimStatement {
+				return nil, fmt.Errorf("step %d: action is required", i+1)
+			}
+			st.Action = st.To
+		}
+		if st.Action == "cancel" || st.Action == "close" {
+			return nil, fmt.Errorf("step %d: action %q is reserved", i+1, st.Action)
+		}
+		if len(st.Roles) == 0 && len(st.Positions) == 0 {
+			return nil, fmt.Errorf("step %d: at least one position or role is required", i+1)
+		}
+		for _, p := range st.Positions {
+			if !p.Valid() {
+				return nil, fmt.Errorf("step %d: unknown position %q", i+1, p)
+			}
+		}
+		for _, r := range st.Roles {
+			if !model.Role(r).Valid() {
+				return nil, fmt.Errorf("step %d: unknown role %q", i+1, r)
+			}
+		}
+		if byAction[st.From+"|"+st.Action] {
+			return nil, fmt.Errorf("step %d: duplicate action %q from %q", i+1, st.Action, st.From)
+		}
+		// Statement transitions are requested by target status.
+		if entityType == model.WorkflowInterimStatement && byTarget[st.From+"|"+st.To] {
+			return nil, fmt.Errorf("step %d: duplicate transition %s → %s", i+1, st.From, st.To)
+		}
+		byAction[st.From+"|"+st.Action], byTarget[st.From+"|"+st.To] = true, true
+		out = append(out, st)
+	}
+
+	// draft must reach the goal status.
+	seen := map[string]bool{"draft": true}
+	queue := []string{"draft"}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, st := range out {
+			if st.From == cur && !seen[st.To] {
+				seen[st.To] = true
+				queue = append(queue, st.To)
+			}
+		}
+	}
+	if !seen[goal] {
+		return nil, fmt.Errorf("%s is not reachable from draft", goal)
+	}
+	return out, nil
+}
+
+// workflowFor returns the definition governing a document. Drafts follow the
+// company's active definition (pinned when they are submitted); submitted
+// documents follow their pinned one. The built-in workflow has a nil ID.
+// Documents at the goal status get no steps, even from definitions saved
+// before normalizeWorkflow refused them: approval side effects run once.
+func workflowFor(tx *gorm.DB, companyID uuid.UUID, entityType, status string, pinned *uuid.UUID) (*model.WorkflowDefinition, error) {
+	if status == workflowGoal[entityType] {
+		return &model.WorkflowDefinition{EntityType: entityType}, nil
+	}
+	var def model.WorkflowDefinition
+	var err error
+	switch {
+	case status == "draft":
+		err = tx.Where("company_id = ? AND entity_type = ? AND active", companyID, entityType).First(&def).Error
+	case pinned != nil:
+		err = tx.First(&def, "id = ?", *pinned).Error
+	default:
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &model.WorkflowDefinition{EntityType: entityType, Steps: defaultWorkflows[entityType]}, nil
+	}
+	if err != nil {
+		return nil, &ServiceError{Message: "Failed to load workflow", Code: 500}
+	}
+	return &def, nil
+}
+
+// workflowPin is the value stored on a document when it leaves draft.
+func workflowPin(def *model.WorkflowDefinition) *uuid.UUID {
+	if def.ID == uuid.Nil {
+		return nil
+	}
+	id := def.ID
+	return &id
+}
+
+// findStep returns the first step from status that satisfies match.
+func findStep(steps model.WorkflowSteps, from string, match func(*model.WorkflowStep) bool) *model.WorkflowStep {
+	for i := range steps {
+		if steps[i].From == from && match(&steps[i]) {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+func hasStepsFrom(steps model.WorkflowSteps, from string) bool {
+	return findStep(steps, from, func(*model.WorkflowStep) bool { return true }) != nil
+}
+
+// approverPositions returns the positions actorID holds for a document of the
+// given company and (optionally) project, read from the company heads and the
+// project manager assignment.
+func approverPositions(tx *gorm.DB, companyID uuid.UUID, projectID *uuid.UUID, actorID uuid.UUID) ([]model.ApproverPosition, error) {
+	var company model.Company
+	if err := tx.Select("id, manager_id, engineering_head_id, financial_head_id, juridical_head_id, security_head_id").
+		First(&company, "id = ?", companyID).Error; err != nil {
+		return nil, &ServiceError{Message: "Failed to load company approvers", Code: 500}
+	}
+	is := func(id *uuid.UUID) bool { return id != nil && *id == actorID }
+
+	var out []model.ApproverPosition
+	if is(company.ManagerID) {
+		out = append(out, model.PositionManager)
+	}
+	if is(company.FinancialHeadID) {
+		out = append(out, model.PositionFinancialHead)
+	}
+	if is(company.EngineeringHeadID) {
+		out = append(out, model.PositionEngineeringHead)
+	}
+	if is(company.JuridicalHeadID) {
+		out = append(out, model.PositionJuridicalHead)
+	}
+	if is(company.SecurityHeadID) {
+		out = append(out, model.PositionSecurityHead)
+	}
+	if projectID != nil {
+		var project model.Project
+		err := tx.Select("id, manager_id").First(&project, "id = ?", *projectID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{Message: "Failed to load project manager", Code: 500}
+		}
+		if is(project.ManagerID) {
+			out = append(out, model.PositionProjectManager)
+		}
+	}
+	return out, nil
+}
+
+// authorizeStep reports the capacity in which the actor may take step: the
+// first of step.Positions they hold, otherwise the first of step.Roles in
+// their token. An empty result means they may not.
+func authorizeStep(step *model.WorkflowStep, positions []model.ApproverPosition, roles []string) string {
+	for _, want := range step.Positions {
+		for _, p := range positions {
+			if p == want {
+				return string(p)
+			}
+		}
+	}
+	for _, want := range step.Roles {
+		for _, r := range roles {
+			if r == want {
+				return r
+			}
+		}
+	}
+	return ""
+}
+
+// stepRequirement describes who may take step, for 403 messages.
+func stepRequirement(step *model.WorkflowStep) string {
+	parts := make([]string, 0, len(step.Positions)+len(step.Roles))
+	for _, p := range step.Positions {
+		parts = append(parts, string(p))
+	}
+	parts = append(parts, step.Roles...)
+	return strings.Join(parts, ", ")
+}
+
+// headRolePositions maps the head roles used by role-only steps (the built-in
+// contract chain) to the position they stand for.
+var headRolePositions = map[string]model.ApproverPosition{
+	string(model.RoleManager):         model.PositionManager,
+	string(model.RoleFinanceHead):     model.PositionFinancialHead,
+	string(model.RoleEngineeringHead): model.PositionEngineeringHead,
+	string(model.RoleJuridicalHead):   model.PositionJuridicalHead,
+	string(model.RoleSecurityHead):    model.PositionSecurityHead,
+}
+
+// positionHolder returns the employee holding position for a document of the
+// given company and project, or nil when the seat is vacant.
+func positionHolder(tx *gorm.DB, companyID uuid.UUID, projectID *uuid.UUID, position model.ApproverPosition) (*uuid.UUID, error) {
+	if position == model.PositionProjectManager {
+		if projectID == nil {
+			return nil, nil
+		}
+		var project model.Project
+		if err := tx.Select("id, manager_id").First(&project, "id = ?", *projectID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, &ServiceError{Message: "Failed to load project manager", Code: 500}
+		}
+		return project.ManagerID, nil
+	}
+	var company model.Company
+	if err := tx.Select("id, manager_id, engineering_head_id, financial_head_id, juridical_head_id, security_head_id").
+		First(&company, "id = ?", companyID).Error; err != nil {
+		return nil, &ServiceError{Message: "Failed to load company approvers", Code: 500}
+	}
+	switch position {
+	case model.PositionManager:
+		return company.ManagerID, nil
+	case model.PositionFinancialHead:
+		return company.FinancialHeadID, nil
+	case model.PositionEngineeringHead:
+		return company.EngineeringHeadID, nil
+	case model.PositionJuridicalHead:
+		return company.JuridicalHeadID, nil
+	case model.PositionSecurityHead:
+		return company.SecurityHeadID, nil
+	}
+	return nil, nil
+}
+
+// stageApprover returns the employee expected to act on a document waiting
+// in stage: the first filled seat among the positions (or head roles) of the
+// stage's steps.
+func stageApprover(tx *gorm.DB, steps model.WorkflowSteps, stage string, companyID uuid.UUID, projectID *uuid.UUID) (*uuid.UUID, error) {
+	for _, st := range steps {
+		if st.From != stage {
+			continue
+		}
+		seats := append([]model.ApproverPosition(nil), st.Positions...)
+		for _, r := range st.Roles {
+			if p, ok := headRolePositions[r]; ok {
+				seats = append(seats, p)
+			}
+		}
+		for _, p := range seats {
+			id, err := positionHolder(tx, companyID, projectID, p)
+			if err != nil {
+				return nil, err
+			}
+			if id != nil {
+				return id, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	model "github.com/sobhan-yasami/docs-db-panel/internal/models"
+)
+
+func TestNormalizeWorkflowAcceptsDefaults(t *testing.T) {
+	for entityType, steps := range defaultWorkflows {
+		if _, err := normalizeWorkflow(entityType, steps); err != nil {
+			t.Errorf("%s: built-in workflow rejected: %v", entityType, err)
+		}
+	}
+}
+
+func TestNormalizeWorkflowDefaults(t *testing.T) {
+	steps := []model.WorkflowStep{
+		{From: " draft ", To: "submitted", Roles: adminOnly},
+		{From: "submitted", Action: " approve ", To: "approved", Roles: adminOnly},
+	}
+	out, err := normalizeWorkflow(model.WorkflowInterimStatement, steps)
+	if err != nil {
+		t.Fatalf("normalizeWorkflow: %v", err)
+	}
+	if out[0].From != "draft" || out[0].Action != "submitted" {
+		t.Errorf("step 1 = %s/%s, want draft/submitted", out[0].From, out[0].Action)
+	}
+	if out[1].Action != "approve" {
+		t.Errorf("step 2 action = %q, want approve", out[1].Action)
+	}
+}
+
+func TestNormalizeWorkflowRejects(t *testing.T) {
+	approve := model.WorkflowStep{From: "submitted", Action: "approve", To: "approved", Roles: adminOnly}
+	submit := model.WorkflowStep{From: "draft", Action: "submit", To: "submitted", Roles: adminOnly}
+	cases := []struct {
+		name, entityType string
+		steps            []model.WorkflowStep
+		want             string
+	}{
+		{"unknown entity", "invoice", []model.WorkflowStep{submit}, "entity_type"},
+		{"empty", model.WorkflowInterimStatement, nil, "must not be empty"},
+		{"bad status", model.WorkflowInterimStatement, []model.WorkflowStep{{From: "draft", Action: "submit", To: "nowhere", Roles: adminOnly}}, "invalid from/to"},
+		{"self loop", model.WorkflowInterimStatement, []model.WorkflowStep{{From: "draft", Action: "submit", To: "draft", Roles: adminOnly}}, "invalid from/to"},
+		{"missing action", model.WorkflowVariationOrder, []model.WorkflowStep{{From: "draft", To: "pending_engineering", Roles: adminOnly}}, "action is required"},
+		{"reserved action", model.WorkflowInterimStatement, []model.WorkflowStep{{From: "draft", Action: "cancel", To: "rejected", Roles: adminOnly}}, "reserved"},
+		{"no approver", model.WorkflowInterimStatement, []model.WorkflowStep{{From: "draft", Action: "submit", To: "submitted"}}, "position or role"},
+		{"unknown role", model.WorkflowInterimStatement, []model.WorkflowStep{{From: "draft", Action: "submit", To: "submitted", Roles: []string{"intern"}}}, "unknown role"},
+		{"unknown position", model.WorkflowInterimStatement, []model.WorkflowStep{{From: "draft", Action: "submit", To: "submitted", Positions: []model.ApproverPosition{"janitor"}}}, "unknown position"},
+		{"duplicate action", model.WorkflowInterimStatement, []model.WorkflowStep{submit, approve, {From: "submitted", Action: "approve", To: "rejected", Roles: adminOnly}}, "duplicate action"},
+		{"duplicate transition", model.WorkflowInterimStatement, []model.WorkflowStep{submit, approve, {From: "submitted", Action: "sign", To: "approved", Roles: adminOnly}}, "duplicate transition"},
+		{"goal unreachable", model.WorkflowInterimStatement, []model.WorkflowStep{submit}, "approved is not reachable from draft"},
+		{"leaves approved statement", model.WorkflowInterimStatement, []model.WorkflowStep{submit, approve, {From: "approved", Action: "reopen", To: "draft", Roles: adminOnly}}, "approved is final"},
+		{"leaves approved variation", model.WorkflowVariationOrder, []model.WorkflowStep{
+			{From: "draft", Action: "submit", To: "pending_ceo", Roles: adminOnly},
+			{From: "pending_ceo", Action: "approve", To: "approved", Roles: adminOnly},
+			{From: "approved", Action: "revise", To: "pending_ceo", Roles: adminOnly},
+		}, "approved is final"},
+		{"leaves approved extension", model.WorkflowExtensionOfTime, []model.WorkflowStep{
+			{From: "draft", Action: "submit", To: "approved", Roles: adminOnly},
+			{From: "approved", Action: "reopen", To: "draft", Roles: adminOnly},
+		}, "approved is final"},
+		{"leaves active contract", model.WorkflowContract, []model.WorkflowStep{
+			{From: "draft", Action: "sign", To: "active", Roles: adminOnly},
+			{From: "active", Action: "suspend", To: "signed", Roles: adminOnly},
+		}, "active is final"},
+		{"goal only from elsewhere", model.WorkflowInterimStatement, []model.WorkflowStep{submit, {From: "rejected", Action: "approve", To: "approved", Roles: adminOnly}}, "not reachable"},
+	}
+	for _, c := range cases {
+		_, err := normalizeWorkflow(c.entityType, c.steps)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want it to mention %q", c.name, err, c.want)
+		}
+	}
+}
+
+// Definitions saved before the goal was final may still hold steps leaving
+// it; documents at the goal must not follow them.
+func TestWorkflowForGoalHasNoSteps(t *testing.T) {
+	for entityType, goal := range workflowGoal {
+		def, err := workflowFor(nil, uuid.Nil, entityType, goal, nil)
+		if err != nil {
+			t.Fatalf("%s: workflowFor: %v", entityType, err)
+		}
+		if hasStepsFrom(def.Steps, goal) {
+			t.Errorf("%s: steps available from %s", entityType, goal)
+		}
+	}
+}